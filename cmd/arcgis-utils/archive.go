@@ -0,0 +1,274 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressionExtension returns the file extension added to outputs by a compression
+// method, or an error for an unknown method.
+func compressionExtension(compression string) (string, error) {
+	switch compression {
+	case "":
+		return "", nil
+	case CompressGzip:
+		return ".gz", nil
+	case CompressZstd:
+		return ".zst", nil
+	}
+	return "", fmt.Errorf("invalid compression %s, expected gzip or zstd", compression)
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	n int64
+}
+
+// Write counts p.
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// nopWriteCloser adds a no-op Close to a writer.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close does nothing.
+func (nopWriteCloser) Close() error { return nil }
+
+// writeCompressed streams an encoder's output to w through the compression method, if
+// any, so that the output is compressed as it is encoded.
+//
+// Parameters:
+//   - w: Destination of the output, such as a file or standard output
+//   - encode: Encoder writing the output
+//   - compression: Compression method (gzip or zstd), or empty to write the output as is
+//
+// Returns:
+//   - int64: Number of bytes written to w
+//   - string: Hex SHA-256 checksum of the bytes written to w
+//   - error: Any error that occurred while compressing or writing
+func writeCompressed(w io.Writer, encode layerEncoder, compression string) (int64, string, error) {
+	hash := sha256.New()
+	counter := &countingWriter{}
+	dest := io.MultiWriter(w, hash, counter)
+
+	var compressor io.WriteCloser
+	switch compression {
+	case CompressGzip:
+		compressor = gzip.NewWriter(dest)
+	case CompressZstd:
+		encoder, err := zstd.NewWriter(dest)
+		if err != nil {
+			return 0, "", fmt.Errorf("failed to create zstd encoder: %v", err)
+		}
+		compressor = encoder
+	default:
+		compressor = nopWriteCloser{dest}
+	}
+
+	// Buffer small writes from the encoders before they reach the compressor and the file.
+	buffered := bufio.NewWriterSize(compressor, OutputChunkSize)
+	if err := encode(buffered); err != nil {
+		compressor.Close()
+		return counter.n, "", err
+	}
+	if err := buffered.Flush(); err != nil {
+		compressor.Close()
+		return counter.n, "", err
+	}
+	if err := compressor.Close(); err != nil {
+		return counter.n, "", fmt.Errorf("failed to finish %s stream: %v", compression, err)
+	}
+	return counter.n, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// runArchive collects the files written in a run, so that they are bundled into one
+// zip archive once the run is complete. A nil archive collects nothing.
+type runArchive struct {
+	path  string // Archive file
+	mu    sync.Mutex
+	files map[string]bool // Files and directories to bundle
+}
+
+// add records files or directories written in the run.
+func (a *runArchive) add(paths ...string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.files == nil {
+		a.files = make(map[string]bool)
+	}
+	for _, path := range paths {
+		a.files[path] = true
+	}
+}
+
+// archiveFile is a file to bundle and its name in the archive.
+type archiveFile struct {
+	path string
+	name string
+}
+
+// write bundles the collected files into the zip archive, copying each file from disk so
+// that no output is held in memory. The bundled files are kept, so that tools reading the
+// manifest or symbols still find them. Entries are named relative to the output directory
+// and listed in name order.
+//
+// Parameters:
+//   - outputDir: Output directory of the run
+//
+// Returns:
+//   - int: Number of files bundled
+//   - error: Any error that occurred while writing the archive
+func (a *runArchive) write(outputDir string) (int, error) {
+	files, err := a.collect(outputDir)
+	if err != nil {
+		return 0, err
+	}
+
+	zipFile, err := os.OpenFile(a.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, FilePerm)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create archive %s: %v", a.path, err)
+	}
+	zipWriter := zip.NewWriter(zipFile)
+	for _, file := range files {
+		if err := addToZip(zipWriter, file); err != nil {
+			zipWriter.Close()
+			zipFile.Close()
+			return 0, err
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		zipFile.Close()
+		return 0, fmt.Errorf("failed to finish archive %s: %v", a.path, err)
+	}
+	if err := zipFile.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close archive %s: %v", a.path, err)
+	}
+	return len(files), nil
+}
+
+// isWithin reports whether path is below dir.
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// collect lists the collected files, expanding directories, with their archive names.
+func (a *runArchive) collect(outputDir string) ([]archiveFile, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var files []archiveFile
+	seen := make(map[string]bool)
+	addFile := func(path string) {
+		// Files outside the output directory, such as a manifest at an absolute path, go at the top.
+		name := filepath.Base(path)
+		if isWithin(outputDir, path) {
+			name, _ = filepath.Rel(outputDir, path)
+		}
+		name = filepath.ToSlash(name)
+		if !seen[name] {
+			seen[name] = true
+			files = append(files, archiveFile{path: path, name: name})
+		}
+	}
+	for path := range a.files {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		if !info.IsDir() {
+			addFile(path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				addFile(p)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+	return files, nil
+}
+
+// addToZip copies a file into a zip archive. Files that are already compressed are
+// stored rather than deflated again.
+func addToZip(zipWriter *zip.Writer, file archiveFile) error {
+	source, err := os.Open(file.path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", file.path, err)
+	}
+	defer source.Close()
+	info, err := source.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", file.path, err)
+	}
+
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %v", file.path, err)
+	}
+	header.Name = file.name
+	header.Method = zip.Deflate
+	if storedExtensions[strings.ToLower(filepath.Ext(file.name))] {
+		header.Method = zip.Store
+	}
+	entry, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %v", file.path, err)
+	}
+	if _, err := io.Copy(entry, source); err != nil {
+		return fmt.Errorf("failed to archive %s: %v", file.path, err)
+	}
+	return nil
+}
+
+// storedExtensions are the extensions of compressed files, which are stored in archives as is.
+var storedExtensions = map[string]bool{
+	".gz": true, ".zst": true, ".kmz": true, ".zip": true, ".png": true, ".jpg": true, ".gif": true, ".pmtiles": true,
+}
+
+// finishRun writes the run manifest and archive of a configuration, if any. A failure to
+// write the archive is returned, so that it fails the run.
+func finishRun(config layerProcessConfig) error {
+	writeManifest(config)
+	if config.archive == nil {
+		return nil
+	}
+	count, err := config.archive.write(config.outputDir)
+	if err != nil {
+		printError(fmt.Sprintf("Error writing archive: %v", err))
+		return err
+	}
+	printInfo(fmt.Sprintf("Bundled %d file(s) into %s", count, config.archive.path))
+	return nil
+}
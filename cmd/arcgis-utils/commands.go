@@ -0,0 +1,452 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/arcgis"
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/convert"
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/export"
+)
+
+// layerListEntry is a layer as printed by the list command.
+type layerListEntry struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Type         string   `json:"type,omitempty"`
+	GeometryType string   `json:"geometryType,omitempty"`
+	Path         []string `json:"path,omitempty"`
+	URL          string   `json:"url"`
+}
+
+// layerInfoEntry is a layer as printed by the info command.
+type layerInfoEntry struct {
+	URL         string       `json:"url"`
+	Path        []string     `json:"path,omitempty"`
+	RecordCount *int         `json:"recordCount,omitempty"`
+	Metadata    arcgis.Layer `json:"metadata"`
+}
+
+// resultWriter sends progress output to stderr for the rest of the command and returns
+// stdout, so that a command's result can be piped or parsed on its own.
+func resultWriter() io.Writer {
+	consoleOutput = os.Stderr
+	arcgis.Output = os.Stderr
+	export.Output = os.Stderr
+	return os.Stdout
+}
+
+// runList implements the list command: it prints the layers found at a URL as a table,
+// or as JSON with -json.
+func runList(args []string) {
+	fs := flag.NewFlagSet(CommandList, flag.ExitOnError)
+	urlPtr := fs.String("url", "", "ArcGIS Feature Layer, Feature Server, Map Server, or ArcGIS Online Item URL")
+	jsonPtr := fs.Bool("json", false, "Print the layers as JSON")
+	noColorPtr := fs.Bool("no-color", false, "Disable colored output")
+	timeoutPtr := fs.Int("timeout", DefaultTimeoutSeconds, "HTTP request timeout in seconds")
+	fs.Parse(args)
+
+	useColor = !*noColorPtr
+	inputURL := parseURLFlag(fs, *urlPtr)
+	out := resultWriter()
+	client := arcgis.NewClient(time.Duration(*timeoutPtr) * time.Second)
+
+	layers, single, err := discoverLayers(client, inputURL)
+	if err != nil {
+		printError(fmt.Sprintf("Error identifying or fetching initial data: %v", err))
+		os.Exit(1)
+	}
+	// A layer URL only gives the layer ID, so its name and type come from its metadata.
+	if single {
+		for i := range layers {
+			if metadata, err := fetchLayerMetadata(client, layers[i]); err == nil {
+				layers[i].Name = metadata.Name
+				layers[i].Type = metadata.Type
+				layers[i].GeometryType = metadata.GeometryType
+			} else {
+				printWarning(fmt.Sprintf("  Warning: %v", err))
+			}
+		}
+	}
+
+	if err := writeLayerList(out, layers, *jsonPtr); err != nil {
+		printError(fmt.Sprintf("Failed to print layers: %v", err))
+		os.Exit(1)
+	}
+}
+
+// writeLayerList writes layers as an aligned table or as a JSON array.
+func writeLayerList(w io.Writer, layers []arcgis.AvailableLayerInfo, asJSON bool) error {
+	entries := make([]layerListEntry, len(layers))
+	for i, layer := range layers {
+		entries[i] = layerListEntry{
+			ID:           layer.ID,
+			Name:         layer.Name,
+			Type:         layer.Type,
+			GeometryType: layer.GeometryType,
+			Path:         layer.ParentPath,
+			URL:          fmt.Sprintf(LayerKeyFormat, layer.ServiceURL, layer.ID),
+		}
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(entries, "", JSONIndent)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tTYPE\tGEOMETRY\tPATH\tURL")
+	for _, entry := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", entry.ID, entry.Name, entry.Type, entry.GeometryType, strings.Join(entry.Path, PathSeparatorSpace), entry.URL)
+	}
+	return tw.Flush()
+}
+
+// runInfo implements the info command: it prints the metadata of every layer found at a
+// URL, including fields, extent, record count, renderer and labels.
+func runInfo(args []string) {
+	fs := flag.NewFlagSet(CommandInfo, flag.ExitOnError)
+	urlPtr := fs.String("url", "", "ArcGIS Feature Layer, Feature Server, Map Server, or ArcGIS Online Item URL")
+	jsonPtr := fs.Bool("json", false, "Print the layer metadata as JSON")
+	noColorPtr := fs.Bool("no-color", false, "Disable colored output")
+	timeoutPtr := fs.Int("timeout", DefaultTimeoutSeconds, "HTTP request timeout in seconds")
+	fs.Parse(args)
+
+	useColor = !*noColorPtr
+	inputURL := parseURLFlag(fs, *urlPtr)
+	out := resultWriter()
+	client := arcgis.NewClient(time.Duration(*timeoutPtr) * time.Second)
+
+	layers, _, err := discoverLayers(client, inputURL)
+	if err != nil {
+		printError(fmt.Sprintf("Error identifying or fetching initial data: %v", err))
+		os.Exit(1)
+	}
+
+	var entries []layerInfoEntry
+	failed := false
+	for _, layer := range layers {
+		metadata, err := fetchLayerMetadata(client, layer)
+		if err != nil {
+			printError(fmt.Sprintf("  Error fetching layer %s: %v", layer.Name, err))
+			failed = true
+			continue
+		}
+		entry := layerInfoEntry{
+			URL:      fmt.Sprintf(LayerKeyFormat, layer.ServiceURL, layer.ID),
+			Path:     layer.ParentPath,
+			Metadata: metadata,
+		}
+		if count, err := client.FetchFeatureCount(layer.ServiceURL, layer.ID); err == nil {
+			entry.RecordCount = &count
+		} else {
+			printWarning(fmt.Sprintf("  Warning: Record count unavailable for layer %s: %v", layer.Name, err))
+		}
+		entries = append(entries, entry)
+	}
+
+	if *jsonPtr {
+		data, err := json.MarshalIndent(entries, "", JSONIndent)
+		if err != nil {
+			printError(fmt.Sprintf("Failed to encode layer metadata: %v", err))
+			os.Exit(1)
+		}
+		fmt.Fprintln(out, string(data))
+	} else {
+		for i, entry := range entries {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			writeLayerInfo(out, entry)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// writeLayerInfo writes a readable summary of a layer's metadata.
+func writeLayerInfo(w io.Writer, entry layerInfoEntry) {
+	layer := entry.Metadata
+	fmt.Fprintf(w, "Layer: %s\n", layer.Name)
+	fmt.Fprintf(w, "URL: %s\n", entry.URL)
+	if len(entry.Path) > 0 {
+		fmt.Fprintf(w, "Path: %s\n", strings.Join(entry.Path, PathSeparatorSpace))
+	}
+	fmt.Fprintf(w, "Type: %s\n", layer.Type)
+	fmt.Fprintf(w, "Geometry: %s\n", layer.GeometryType)
+	if entry.RecordCount != nil {
+		fmt.Fprintf(w, "Records: %d\n", *entry.RecordCount)
+	} else {
+		fmt.Fprintln(w, "Records: unknown")
+	}
+	if extent := layer.Extent; extent != nil {
+		fmt.Fprintf(w, "Extent: %s, %s, %s, %s", convert.FormatValue(extent.XMin), convert.FormatValue(extent.YMin), convert.FormatValue(extent.XMax), convert.FormatValue(extent.YMax))
+		if sr := extent.SpatialReference; sr != nil && spatialReferenceWKID(sr) != 0 {
+			fmt.Fprintf(w, " (WKID %d)", spatialReferenceWKID(sr))
+		}
+		fmt.Fprintln(w)
+	}
+	if name := layer.ObjectIDFieldName(); name != "" {
+		fmt.Fprintf(w, "Object ID Field: %s\n", name)
+	}
+	if layer.DisplayField != "" {
+		fmt.Fprintf(w, "Display Field: %s\n", layer.DisplayField)
+	}
+	if layer.TimeInfo != nil && layer.TimeInfo.StartTimeField != "" {
+		fmt.Fprintf(w, "Time: %s", layer.TimeInfo.StartTimeField)
+		if layer.TimeInfo.EndTimeField != "" {
+			fmt.Fprintf(w, " to %s", layer.TimeInfo.EndTimeField)
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(layer.Fields) > 0 {
+		fmt.Fprintln(w, "Fields:")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, field := range layer.Fields {
+			domain := ""
+			if field.Domain != nil {
+				switch {
+				case len(field.Domain.CodedValues) > 0:
+					domain = fmt.Sprintf("%d coded values", len(field.Domain.CodedValues))
+				case len(field.Domain.Range) == 2:
+					domain = fmt.Sprintf("range %s to %s", convert.FormatValue(field.Domain.Range[0]), convert.FormatValue(field.Domain.Range[1]))
+				}
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", field.Name, field.Type, field.Alias, domain)
+		}
+		tw.Flush()
+	}
+
+	if layer.DrawingInfo == nil {
+		return
+	}
+	if renderer := layer.DrawingInfo.Renderer; renderer != nil {
+		var fields []string
+		for _, field := range []string{renderer.Field1, renderer.Field2, renderer.Field3, renderer.Field} {
+			if field != "" {
+				fields = append(fields, field)
+			}
+		}
+		fmt.Fprintf(w, "Renderer: %s", renderer.Type)
+		if len(fields) > 0 {
+			fmt.Fprintf(w, " (%s)", strings.Join(fields, ", "))
+		}
+		fmt.Fprintln(w)
+		for _, label := range rendererClassLabels(renderer) {
+			fmt.Fprintf(w, "  %s\n", label)
+		}
+		for _, variable := range renderer.VisualVariables {
+			fmt.Fprintf(w, "  Visual variable: %s %s\n", variable.Type, variable.Field)
+		}
+	}
+	if labelClass := arcgis.DefaultLabelClass(layer.DrawingInfo.LabelingInfo); labelClass != nil {
+		fmt.Fprintf(w, "Labels: %s\n", labelClass)
+	}
+}
+
+// rendererClassLabels returns the labels of a renderer's classes in drawing order, ending
+// with the default label when the renderer has one.
+func rendererClassLabels(renderer *arcgis.Renderer) []string {
+	var labels []string
+	for _, info := range renderer.ClassBreakInfos {
+		labels = append(labels, info.Label)
+	}
+	for _, group := range renderer.UniqueValueGroups {
+		for _, class := range group.Classes {
+			labels = append(labels, class.Label)
+		}
+	}
+	for _, info := range renderer.UniqueValueInfos {
+		labels = append(labels, info.Label)
+	}
+	if renderer.DefaultLabel != "" {
+		labels = append(labels, renderer.DefaultLabel+" (default)")
+	}
+	return labels
+}
+
+// runConvert implements the convert command: it exports local Esri JSON or GeoJSON files
+// to an output format without network access.
+func runConvert(args []string) {
+	fs := flag.NewFlagSet(CommandConvert, flag.ExitOnError)
+	metadataPtr := fs.String("metadata", "", "Layer metadata JSON file, as served by the layer's REST endpoint, providing fields and renderer")
+	noColorPtr := fs.Bool("no-color", false, "Disable colored output")
+	output := addOutputFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: arcgis-utils convert [flags] file...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	useColor = !*noColorPtr
+	if fs.NArg() == 0 {
+		printError("At least one input file is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+	config, err := output.config()
+	if err != nil {
+		printError(fmt.Sprintf("Invalid options: %v", err))
+		os.Exit(1)
+	}
+
+	if output.toStdout() {
+		// Progress messages move to stderr so that the output can be piped.
+		config.stdout = resultWriter()
+	}
+	if err := checkSingleOutput(config, fs.NArg()); err != nil {
+		printError(fmt.Sprintf("Invalid options: %v", err))
+		os.Exit(1)
+	}
+
+	if config.mergeLayers {
+		config.merger = &layerMerger{}
+	}
+	var counts layerCounts
+	for _, path := range fs.Args() {
+		printInfo(fmt.Sprintf("Converting %s", path))
+		start := time.Now()
+		var report layerReport
+		layerInfo, layer, features, err := readLocalLayer(path, *metadataPtr, config.format)
+		if err == nil {
+			report, err = exportLayer(layerInfo, layer, features, config)
+		}
+		config.manifest.add(arcgis.AvailableLayerInfo{ID: layerInfo.ID, Name: layerInfo.Name, ServiceURL: path}, config.format, report, err, time.Since(start))
+		counts.record(path, err)
+	}
+	if config.merger != nil {
+		finishMerge(config, &counts)
+	}
+	if err := finishRun(config); err != nil {
+		counts.errors.Add(1)
+	}
+	counts.summarize()
+}
+
+// readLocalLayer reads a local Esri JSON FeatureSet or GeoJSON file as a layer named after
+// the file. A metadata file, such as the layer's JSON from its REST endpoint, supplies the
+// layer's name, fields and renderer. Projected Esri JSON is rejected for formats defined
+// on WGS84, as its coordinates are written unchanged.
+func readLocalLayer(path, metadataPath, format string) (arcgis.AvailableLayerInfo, arcgis.Layer, []arcgis.Feature, error) {
+	name := filepath.Base(path)
+	for _, ext := range []string{".esri.json", ".esrijson", ".geojson", ".json"} {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			name = name[:len(name)-len(ext)]
+			break
+		}
+	}
+	layerInfo := arcgis.AvailableLayerInfo{Name: name, ServiceURL: path, IsFeatureLayer: true}
+	var layer arcgis.Layer
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return layerInfo, layer, nil, fmt.Errorf("failed to read input file %s: %v", path, err)
+	}
+	var probe struct {
+		Type     string          `json:"type"`
+		Features json.RawMessage `json:"features"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return layerInfo, layer, nil, fmt.Errorf("failed to parse input file %s: %v", path, err)
+	}
+
+	var features []arcgis.Feature
+	switch {
+	case probe.Type == "FeatureCollection" || probe.Type == "Feature":
+		geoJSONFeatures, err := convert.FromGeoJSON(data)
+		if err != nil {
+			return layerInfo, layer, nil, err
+		}
+		for _, f := range geoJSONFeatures {
+			features = append(features, arcgis.Feature{Attributes: f.Attributes, Geometry: f.Geometry})
+		}
+	case probe.Features != nil:
+		var featureSet export.EsriFeatureSet
+		if err := json.Unmarshal(data, &featureSet); err != nil {
+			return layerInfo, layer, nil, fmt.Errorf("failed to parse Esri JSON file %s: %v", path, err)
+		}
+		if sr := featureSet.SpatialReference; sr != nil {
+			if wkid := spatialReferenceWKID(sr); wkid != 0 && wkid != export.EsriWKIDWGS84 {
+				if requiresWGS84(format) {
+					return layerInfo, layer, nil, fmt.Errorf("%s uses spatial reference %d, but format %s requires WGS84 coordinates; use gml, esrijson, json, csv or txt", path, wkid, format)
+				}
+				printWarning(fmt.Sprintf("  Warning: %s uses spatial reference %d; coordinates are written unchanged.", path, wkid))
+			}
+		}
+		layer = arcgis.Layer{
+			GeometryType:  featureSet.GeometryType,
+			Fields:        featureSet.Fields,
+			ObjectIDField: featureSet.ObjectIDFieldName,
+			DisplayField:  featureSet.DisplayFieldName,
+		}
+		for _, f := range featureSet.Features {
+			features = append(features, arcgis.Feature{Attributes: f.Attributes, Geometry: f.Geometry})
+		}
+	default:
+		return layerInfo, layer, nil, fmt.Errorf("%s is neither GeoJSON nor an Esri JSON FeatureSet", path)
+	}
+
+	if metadataPath != "" {
+		metadataData, err := os.ReadFile(metadataPath)
+		if err != nil {
+			return layerInfo, layer, nil, fmt.Errorf("failed to read metadata file %s: %v", metadataPath, err)
+		}
+		var metadata arcgis.Layer
+		if err := json.Unmarshal(metadataData, &metadata); err != nil {
+			return layerInfo, layer, nil, fmt.Errorf("failed to parse metadata file %s: %v", metadataPath, err)
+		}
+		if len(metadata.Fields) == 0 {
+			metadata.Fields = layer.Fields
+		}
+		if metadata.ObjectIDField == "" {
+			metadata.ObjectIDField = layer.ObjectIDField
+		}
+		if metadata.GeometryType == "" {
+			metadata.GeometryType = layer.GeometryType
+		}
+		layer = metadata
+		layerInfo.ID = idString(metadata.ID)
+	}
+
+	if len(features) == 0 {
+		return layerInfo, layer, nil, errNoFeatures
+	}
+	return layerInfo, layer, features, nil
+}
+
+// spatialReferenceWKID returns the latest well-known ID of a spatial reference, or 0 if it has none.
+func spatialReferenceWKID(sr *arcgis.SpatialReference) int {
+	if sr.LatestWKID != 0 {
+		return sr.LatestWKID
+	}
+	return sr.WKID
+}
+
+// idString formats a layer ID from JSON metadata, which is usually a number.
+func idString(id interface{}) string {
+	switch v := id.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -21,3 +21,82 @@ const (
 	JSONIndent             = "  "
 )
 
+
+// KML folder constants
+const (
+	KMLFolderByClass     = "class"
+	KMLFolderByPath      = "path"
+	KMLFolderFieldPrefix = "field:"
+	KMLFolderNull        = "<Null>"
+)
+
+// Command constants
+const (
+	CommandList     = "list"
+	CommandInfo     = "info"
+	CommandDownload = "download"
+	CommandConvert  = "convert"
+)
+
+// Layer selector constants
+const (
+	SelectGeometryPoint    = "point"
+	SelectGeometryPolyline = "polyline"
+	SelectGeometryPolygon  = "polygon"
+)
+
+// Output template constants
+const (
+	OutputPlaceholderService   = "{service}"
+	OutputPlaceholderLayerID   = "{layerId}"
+	OutputPlaceholderLayerName = "{layerName}"
+	OutputPlaceholderPath      = "{path}"
+	OutputPlaceholderDate      = "{date}"
+	OutputPlaceholderFormat    = "{format}"
+)
+
+// Job file constants
+const (
+	CRSPrefixEPSG = "EPSG:"
+)
+
+// URL list constants
+const (
+	URLListStdin = "-"
+)
+
+// Run manifest constants
+const (
+	ManifestFileName = "manifest.json"
+	StatusSuccess    = "success"
+	StatusSkipped    = "skipped"
+	StatusFailed     = "failed"
+)
+
+// Merge constants
+const (
+	MergedLayerName   = "merged"
+	MergeFieldLayer   = "layer"
+	MergeFieldLayerID = "layer_id"
+	MergeFieldSource  = "layer_source"
+)
+
+// Split constants
+const (
+	SplitNullValue      = "null"
+	SplitEmptyValueName = "empty"
+)
+
+// Standard output constants
+const (
+	OutputStdout = "-"
+)
+
+// Compression and archive constants
+const (
+	CompressGzip      = "gzip"
+	CompressZstd      = "zstd"
+	ArchiveZip        = "zip"
+	ArchiveNameFormat = "export_%s.zip"
+	OutputChunkSize   = 32 * 1024 // Size of the writes of an encoded output
+)
@@ -0,0 +1,340 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/arcgis"
+	"gopkg.in/yaml.v3"
+)
+
+// jobFile describes a repeatable export job: the sources to download and the defaults
+// they share. Settings missing from the file are taken from the command-line flags.
+type jobFile struct {
+	Output  string      `json:"output" yaml:"output"`
+	Format  string      `json:"format" yaml:"format"`
+	Timeout int         `json:"timeout" yaml:"timeout"`
+	Sources []jobSource `json:"sources" yaml:"sources"`
+}
+
+// jobSource describes one URL of a job, the layers to select from it, the query to run
+// and how to write the result.
+type jobSource struct {
+	URL          string      `json:"url" yaml:"url"`
+	Layers       jobLayerIDs `json:"layers" yaml:"layers"`
+	LayerName    string      `json:"layer-name" yaml:"layer-name"`
+	GeometryType string      `json:"geometry-type" yaml:"geometry-type"`
+	Where        string      `json:"where" yaml:"where"`
+	Fields       []string    `json:"fields" yaml:"fields"`
+	Format       string      `json:"format" yaml:"format"`
+	OutputPath   string      `json:"output-path" yaml:"output-path"`
+	CRS          jobCRS      `json:"crs" yaml:"crs"`
+}
+
+// jobLayerIDs is a list of layer IDs, written as a list ([0, 3, 7]) or as a
+// comma-separated string ("0,3,7").
+type jobLayerIDs []string
+
+// UnmarshalJSON decodes layer IDs from a JSON list of numbers or strings, or a string.
+func (ids *jobLayerIDs) UnmarshalJSON(data []byte) error {
+	var list []json.Number
+	if err := json.Unmarshal(data, &list); err == nil {
+		for _, id := range list {
+			*ids = append(*ids, id.String())
+		}
+		return nil
+	}
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("layers must be a list of IDs or a comma-separated string")
+	}
+	*ids = splitList(value)
+	return nil
+}
+
+// UnmarshalYAML decodes layer IDs from a YAML sequence or a scalar.
+func (ids *jobLayerIDs) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		var list []string
+		if err := node.Decode(&list); err != nil {
+			return err
+		}
+		*ids = list
+		return nil
+	}
+	*ids = splitList(node.Value)
+	return nil
+}
+
+// jobCRS is the well-known ID of an output spatial reference, written as a number (3857)
+// or a string ("EPSG:3857").
+type jobCRS int
+
+// UnmarshalJSON decodes a CRS from a JSON number or string.
+func (crs *jobCRS) UnmarshalJSON(data []byte) error {
+	var wkid int
+	if err := json.Unmarshal(data, &wkid); err == nil {
+		return crs.parse(strconv.Itoa(wkid))
+	}
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid crs %s, expected a well-known ID such as 3857 or EPSG:3857", data)
+	}
+	return crs.parse(value)
+}
+
+// UnmarshalYAML decodes a CRS from a YAML scalar.
+func (crs *jobCRS) UnmarshalYAML(node *yaml.Node) error {
+	return crs.parse(node.Value)
+}
+
+// parse sets the CRS from a well-known ID with an optional EPSG: prefix.
+func (crs *jobCRS) parse(value string) error {
+	value = strings.TrimSpace(value)
+	if len(value) >= len(CRSPrefixEPSG) && strings.EqualFold(value[:len(CRSPrefixEPSG)], CRSPrefixEPSG) {
+		value = value[len(CRSPrefixEPSG):]
+	}
+	wkid, err := strconv.Atoi(value)
+	if err != nil || wkid <= 0 {
+		return fmt.Errorf("invalid crs %q, expected a well-known ID such as 3857 or EPSG:3857", value)
+	}
+	*crs = jobCRS(wkid)
+	return nil
+}
+
+// loadJob reads a job file, decoding it as JSON when it has a .json extension and as
+// YAML otherwise.
+func loadJob(path string) (*jobFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job file %s: %v", path, err)
+	}
+
+	var job jobFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &job)
+	} else {
+		err = yaml.Unmarshal(data, &job)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse job file %s: %v", path, err)
+	}
+	if len(job.Sources) == 0 {
+		return nil, fmt.Errorf("job file %s has no sources", path)
+	}
+	return &job, nil
+}
+
+// config returns the processing configuration and layer selector of a source, starting
+// from the job's defaults.
+func (s jobSource) config(defaults layerProcessConfig) (layerProcessConfig, layerSelector, error) {
+	config := defaults
+	if s.Format != "" {
+		config.format = s.Format
+	}
+	if s.OutputPath != "" {
+		if err := validateOutputTemplate(s.OutputPath); err != nil {
+			return config, layerSelector{}, err
+		}
+		config.outputTemplate = s.OutputPath
+	}
+	config.query = arcgis.QueryOptions{Where: s.Where, OutFields: s.Fields, OutSR: int(s.CRS)}
+
+	// Formats defined on WGS84 cannot carry projected coordinates.
+	if config.query.OutSR != 0 && config.query.OutSR != arcgis.WGS84WKID && requiresWGS84(config.format) {
+		return config, layerSelector{}, fmt.Errorf("format %s requires WGS84 coordinates, crs %d is only supported by gml, esrijson, json, csv and txt", config.format, config.query.OutSR)
+	}
+
+	selector, err := newLayerSelector(strings.Join(s.Layers, ","), s.LayerName, s.GeometryType)
+	return config, selector, err
+}
+
+// requiresWGS84 reports whether a format is defined on WGS84 longitude and latitude, so
+// that it cannot carry projected coordinates.
+func requiresWGS84(format string) bool {
+	switch strings.ToLower(format) {
+	case FormatGeoJSON, "topojson", "kml", "kmz", "gpx", "mbtiles", "pmtiles":
+		return true
+	}
+	return false
+}
+
+// runJob implements download -config: it runs every source of a job file through the
+// download pipeline in order, selecting layers without prompting, and prints a summary
+// of each source followed by the totals.
+func runJob(path string, config layerProcessConfig, timeoutSeconds int) {
+	job, err := loadJob(path)
+	if err != nil {
+		printError(fmt.Sprintf("Invalid job: %v", err))
+		os.Exit(1)
+	}
+	if job.Output != "" {
+		config.outputDir = job.Output
+		if config.archive != nil {
+			config.archive.path = filepath.Join(job.Output, filepath.Base(config.archive.path))
+		}
+	}
+	if job.Format != "" {
+		config.format = job.Format
+	}
+	if job.Timeout > 0 {
+		timeoutSeconds = job.Timeout
+	}
+
+	// Validate every source before downloading anything.
+	sourceConfigs := make([]layerProcessConfig, len(job.Sources))
+	selectors := make([]layerSelector, len(job.Sources))
+	for i, source := range job.Sources {
+		if !arcgis.IsValidHTTPURL(arcgis.NormalizeArcGISURL(source.URL)) {
+			err = fmt.Errorf("invalid url %q", source.URL)
+		} else {
+			sourceConfigs[i], selectors[i], err = source.config(config)
+		}
+		if err != nil {
+			printError(fmt.Sprintf("Invalid job: source %d: %v", i+1, err))
+			os.Exit(1)
+		}
+	}
+
+	client := arcgis.NewClient(time.Duration(timeoutSeconds) * time.Second)
+	results := make([]*sourceResult, len(job.Sources))
+	for i, source := range job.Sources {
+		printInfo(fmt.Sprintf("\nSource %d/%d: %s", i+1, len(job.Sources), source.URL))
+		results[i] = runSource(client, arcgis.NormalizeArcGISURL(source.URL), selectors[i], sourceConfigs[i])
+	}
+
+	if err := finishRun(config); err != nil {
+		results = append(results, &sourceResult{url: config.archive.path, err: err})
+	}
+	summarizeSources(results)
+}
+
+// runURLList implements download -url-file and -url -: it runs every URL of a list
+// through the download pipeline in order, selecting all layers of each URL unless
+// narrowed by the selectors, and prints a summary of each URL followed by the totals.
+func runURLList(client *arcgis.Client, listPath string, selector layerSelector, config layerProcessConfig) {
+	var r io.Reader = os.Stdin
+	if listPath != URLListStdin {
+		file, err := os.Open(listPath)
+		if err != nil {
+			printError(fmt.Sprintf("Failed to open URL list: %v", err))
+			os.Exit(1)
+		}
+		defer file.Close()
+		r = file
+	}
+	urls, err := readURLList(r)
+	if err != nil {
+		printError(fmt.Sprintf("Failed to read URL list: %v", err))
+		os.Exit(1)
+	}
+	if len(urls) == 0 {
+		printError("The URL list is empty.")
+		os.Exit(1)
+	}
+
+	results := make([]*sourceResult, len(urls))
+	for i, rawURL := range urls {
+		printInfo(fmt.Sprintf("\nURL %d/%d: %s", i+1, len(urls), rawURL))
+		sourceURL := arcgis.NormalizeArcGISURL(rawURL)
+		if !arcgis.IsValidHTTPURL(sourceURL) {
+			printError(fmt.Sprintf("  Error processing source %s: invalid URL", rawURL))
+			results[i] = &sourceResult{url: rawURL, err: fmt.Errorf("invalid URL")}
+			continue
+		}
+		results[i] = runSource(client, sourceURL, selector, config)
+	}
+
+	if err := finishRun(config); err != nil {
+		results = append(results, &sourceResult{url: config.archive.path, err: err})
+	}
+	summarizeSources(results)
+}
+
+// readURLList reads one URL per line, skipping blank lines and comments. A comment starts
+// with # at the beginning of a line or after whitespace, so URL fragments are kept.
+func readURLList(r io.Reader) ([]string, error) {
+	var urls []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if index := strings.Index(line, " #"); index >= 0 {
+			line = line[:index]
+		}
+		if index := strings.Index(line, "\t#"); index >= 0 {
+			line = line[:index]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read URL list: %v", err)
+	}
+	return urls, nil
+}
+
+// sourceResult is the outcome of processing the layers of one source URL.
+type sourceResult struct {
+	url    string
+	counts layerCounts
+	err    error // Error finding or selecting the source's layers
+}
+
+// runSource finds the layers of a source URL, selects them without prompting and
+// processes them.
+func runSource(client *arcgis.Client, sourceURL string, selector layerSelector, config layerProcessConfig) *sourceResult {
+	result := &sourceResult{url: sourceURL}
+	layers, single, err := discoverLayers(client, sourceURL)
+	if err == nil && selector.active() {
+		if single {
+			layers = describeLayers(client, layers)
+		}
+		layers, err = selector.filter(layers)
+	}
+	if err == nil && len(layers) == 0 {
+		err = fmt.Errorf("no layers found")
+	}
+	if err != nil {
+		printError(fmt.Sprintf("  Error processing source %s: %v", sourceURL, err))
+		result.err = err
+		return result
+	}
+
+	selected := make(map[string]arcgis.AvailableLayerInfo, len(layers))
+	for _, layer := range layers {
+		selected[fmt.Sprintf(LayerKeyFormat, layer.ServiceURL, layer.ID)] = layer
+	}
+	processLayers(client, selected, config, &result.counts)
+	return result
+}
+
+// summarizeSources prints the outcome of each source and the totals, exiting with
+// status 1 when any source or layer failed.
+func summarizeSources(results []*sourceResult) {
+	var total layerCounts
+	fmt.Fprintln(consoleOutput, "\nSources:")
+	for i, result := range results {
+		if result.err != nil {
+			fmt.Fprintf(consoleOutput, "  %d. %s: failed: %v\n", i+1, result.url, result.err)
+			total.errors.Add(1)
+			continue
+		}
+		fmt.Fprintf(consoleOutput, "  %d. %s: %d succeeded, %d skipped, %d failed\n", i+1, result.url,
+			result.counts.success.Load(), result.counts.skipped.Load(), result.counts.errors.Load())
+		total.add(&result.counts)
+	}
+	total.summarize()
+}
@@ -6,36 +6,139 @@
 // Map Servers, and ArcGIS Online Items including Web Maps.
 //
 // The tool provides interactive layer selection, concurrent processing, and various output formats
-// including GeoJSON, KML, GPX, CSV, JSON, and Text. It also handles symbol information and
+// including GeoJSON, TopoJSON, KML, KMZ, GML, GPX, MBTiles and PMTiles vector tiles, CSV, JSON, Esri JSON, and Text. It also handles symbol information and
 // supports custom output naming and request timeouts.
 //
 // Usage:
 //
-//	arcgis-utils [-format format] [-output dir] [-select-all] [-overwrite] [-skip-existing]
-//	             [-prefix prefix] [-timeout seconds] [-exclude-symbols] [-save-symbols] -url <ARCGIS_URL>
+//	arcgis-utils <command> [flags]
+//
+// Commands:
+//
+//	list      Print the layers found at a URL as a table, or as JSON with -json
+//	info      Print layer metadata: fields, extent, record count, renderer and labels
+//	download  Download layers and convert them to an output format (default command)
+//	convert   Convert local Esri JSON or GeoJSON files to an output format, offline
+//
+// Without a command, arcgis-utils runs download, so existing invocations keep working:
+//
+//	arcgis-utils list [-json] [-timeout seconds] -url <ARCGIS_URL>
+//	arcgis-utils info [-json] [-timeout seconds] -url <ARCGIS_URL>
+//	arcgis-utils download [-format format] [-output dir] [-select-all] [-overwrite] [-skip-existing]
+//	             [-layers ids] [-layer-name regex] [-geometry-type point|polyline|polygon]
+//	             [-prefix prefix] [-output-template template] [-merge] [-split-by field]
+//	             [-compress gzip|zstd] [-archive zip] [-timeout seconds] [-exclude-symbols] [-save-symbols]
+//	             [-min-zoom z] [-max-zoom z] [-tile-attributes fields]
+//	             [-kml-folder-by class|path|field] [-kml-balloon file] [-gpx-routes]
+//	             [-gpx-polygons boundary|rings|centroid] [-gpx-extensions] [-gpx-sym class|field]
+//	             -url <ARCGIS_URL>
+//	arcgis-utils download -config job.yaml [output flags]
+//	arcgis-utils download [-url-file urls.txt | -url -] [selectors] [output flags]
+//	arcgis-utils convert [-metadata layer.json] [output flags] file...
+//
+// The convert command accepts the output flags of download, from -format to -gpx-sym.
+//
+// A job file describes repeatable downloads from several sources. Each source takes the
+// layer selectors of download, a where clause, the fields to keep, an output format, an
+// output path template (see -output-template) and the WKID of the output CRS. Settings
+// missing from the file come from the flags:
+//
+//	output: exports
+//	format: geojson
+//	sources:
+//	  - url: https://example.com/arcgis/rest/services/City/FeatureServer
+//	    layers: [0, 3]
+//	    where: STATUS = 'Open'
+//	    fields: [NAME, STATUS]
+//	  - url: https://example.com/arcgis/rest/services/Parcels/MapServer
+//	    layer-name: ^Parcels$
+//	    format: gml
+//	    output-path: parcels/{layerName}
+//	    crs: 3857
 //
 // Flags:
 //
 //	-url string
-//	      ArcGIS resource URL (required)
+//	      ArcGIS resource URL (required by list, info and download)
+//	-url-file string
+//	      File listing URLs to download, one per line, with # comments; "-url -" reads
+//	      the list from standard input. Every layer of each URL is processed unless
+//	      narrowed by -layers, -layer-name or -geometry-type
+//	-config string
+//	      YAML or JSON job file listing the sources to download, used instead of -url
+//	-json
+//	      Print list and info results as JSON
+//	-metadata string
+//	      Layer metadata JSON file giving convert the fields and renderer of its input
 //	-format string
-//	      Output format (geojson, kml, gpx, csv, json, text) (default "geojson")
+//	      Output format (geojson, topojson, kml, kmz, gml, gpx, mbtiles, pmtiles, csv, json, esrijson, txt) (default "geojson")
 //	-output string
-//	      Output directory (default: current directory)
+//	      Output directory (default: current directory). "-output -" writes a single
+//	      layer, or several with -merge, to standard output and progress to stderr, e.g.
+//	      arcgis-utils download -url ... -format geojson -output - | ogr2ogr ...
 //	-select-all
 //	      Process all layers without prompting
+//	-layers string
+//	      Comma-separated layer IDs to process, without prompting (e.g. 0,3,7)
+//	-layer-name string
+//	      Regular expression selecting layers by name or Web Map group path (e.g. "Utilities > Water")
+//	-geometry-type string
+//	      Select layers by geometry type: point, polyline or polygon (comma-separated)
 //	-overwrite
 //	      Overwrite existing output files
 //	-skip-existing
 //	      Skip processing if output file exists
 //	-prefix string
 //	      Add prefix to output filenames
+//	-merge
+//	      Write all selected layers to one output named after their service, with the
+//	      layer, layer_id and layer_source attributes; KML and KMZ get a folder per
+//	      layer and TopoJSON an object per layer
+//	-split-by string
+//	      Write one file per distinct value of a field, in a directory named after the
+//	      layer, e.g. -split-by COUNTY_NAME; the manifest lists each file's feature count
+//	-compress string
+//	      Compress each output as it is written, adding .gz (gzip) or .zst (zstd) to its
+//	      name; sidecar files such as GML schemas are left uncompressed
+//	-archive string
+//	      Bundle the outputs, saved symbols and manifest of the run into
+//	      export_YYYY-MM-DD.zip in the output directory (zip); the files are kept
+//	-output-template string
+//	      Output path relative to the output directory, without extension, built from
+//	      {service}, {layerId}, {layerName}, {path} (Web Map group path), {date} and
+//	      {format}, e.g. {service}/{path}/{layerName}_{date} (default: {layerName})
 //	-timeout int
 //	      HTTP request timeout in seconds (default 30)
+//	-manifest string
+//	      Run manifest listing each layer's source, feature count, output path, size,
+//	      SHA-256, duration, warnings and error, relative to the output directory;
+//	      empty to disable (default "manifest.json")
 //	-exclude-symbols
 //	      Exclude symbol information from output
 //	-save-symbols
 //	      Save symbology to separate folder
+//	-min-zoom int
+//	      Minimum zoom level for vector tile formats (default 0)
+//	-max-zoom int
+//	      Maximum zoom level for vector tile formats (default 12); all tiles are held in
+//	      memory, so large layers warn when the zoom range covers too many tiles
+//	-tile-attributes string
+//	      Comma-separated attributes to keep in vector tiles (default: all)
+//	-kml-folder-by string
+//	      Group KML/KMZ placemarks into folders by renderer class label (class),
+//	      Web Map group path (path), or attribute value (field name, or field:name)
+//	-kml-balloon string
+//	      HTML template file for KML/KMZ placemark balloons; $[FIELD] is replaced by the value
+//	-gpx-routes
+//	      Write GPX lines as routes instead of tracks
+//	-gpx-polygons string
+//	      Write GPX polygons as outer boundaries (boundary), all rings (rings),
+//	      or centroid waypoints (centroid) (default "boundary")
+//	-gpx-extensions
+//	      Carry attributes in GPX extensions
+//	-gpx-sym string
+//	      Set GPX waypoint symbols from the renderer class label (class) or an attribute
+//	      value (field name, or field:name)
 //	-no-color
 //	      Disable colored terminal output
 
@@ -45,11 +148,14 @@ import (
 	"bufio"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -74,6 +180,16 @@ const (
 // useColor controls whether colored output is enabled.
 var useColor = true
 
+// consoleOutput receives progress messages and prompts. It is stdout unless a command's
+// result is written there.
+var consoleOutput io.Writer = os.Stdout
+
+// Outcomes of processing a layer that count as skips rather than failures.
+var (
+	errSkippedExisting = errors.New("skipped existing file")
+	errNoFeatures      = errors.New("no features found")
+)
+
 // FeatureServerMetadata represents the metadata for an ArcGIS Feature Server.
 type FeatureServerMetadata struct {
 	CurrentVersion string  `json:"currentVersion"`
@@ -268,77 +384,291 @@ type layerProcessConfig struct {
 	prefix         string
 	excludeSymbols bool
 	saveSymbols    bool
+	tileOptions    export.TileOptions
+	kmlFolderBy    string
+	kmlBalloon     string
+	gpxOptions     export.GPXOptions
+	gpxSymBy       string
+	query          arcgis.QueryOptions
+	outputTemplate string
+	runDate        string // Date of the run for output templates, as YYYY-MM-DD
+	manifest       *runManifest
+	mergeLayers    bool
+	merger         *layerMerger // Collects the layers of a run in merge mode
+	splitBy        string       // Field whose distinct values are written to separate files
+	stdout         io.Writer    // Receives the output instead of a file with -output -
+	compress       string       // Compression method of outputs, gzip or zstd
+	archive        *runArchive  // Bundles the files of the run into a zip archive
 }
 
+// attributeFormats are the output formats that keep features without a geometry.
+var attributeFormats = map[string]bool{"csv": true, "json": true, "esrijson": true, "txt": true}
+
 func main() {
-	urlPtr := flag.String("url", "", "ArcGIS Feature Layer, Feature Server, Map Server, or ArcGIS Online Item URL")
-	formatPtr := flag.String("format", "geojson", "Output format (geojson, kml, gpx, csv, json, txt)")
-	outputPtr := flag.String("output", "", "Output directory (default: current directory)")
-	selectAllPtr := flag.Bool("select-all", false, "Select all found Feature Layers automatically (no prompt)")
-	noColorPtr := flag.Bool("no-color", false, "Disable colored output")
-	overwritePtr := flag.Bool("overwrite", false, "Overwrite existing output files")
-	skipExistingPtr := flag.Bool("skip-existing", false, "Skip processing if output file already exists")
-	prefixPtr := flag.String("prefix", "", "Prefix for output filenames")
-	timeoutPtr := flag.Int("timeout", 30, "HTTP request timeout in seconds")
-	excludeSymbolsPtr := flag.Bool("exclude-symbols", false, "Exclude symbol information from output")
-	saveSymbolsPtr := flag.Bool("save-symbols", false, "Save symbology/images to a separate folder")
-
-	flag.Parse()
+	command, args := CommandDownload, os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
 
-	useColor = !*noColorPtr
+	switch command {
+	case CommandList:
+		runList(args)
+	case CommandInfo:
+		runInfo(args)
+	case CommandDownload:
+		runDownload(args)
+	case CommandConvert:
+		runConvert(args)
+	default:
+		printError(fmt.Sprintf("Unknown command %q, expected list, info, download or convert", command))
+		os.Exit(1)
+	}
+}
+
+// outputFlags holds the flags shared by the download and convert commands, which select
+// the output format and how layers are written.
+type outputFlags struct {
+	format         *string
+	output         *string
+	overwrite      *bool
+	skipExisting   *bool
+	prefix         *string
+	excludeSymbols *bool
+	saveSymbols    *bool
+	minZoom        *int
+	maxZoom        *int
+	tileAttributes *string
+	kmlBalloon     *string
+	kmlFolderBy    *string
+	gpxRoutes      *bool
+	gpxPolygons    *string
+	gpxExtensions  *bool
+	gpxSym         *string
+	outputTemplate *string
+	manifest       *string
+	merge          *bool
+	splitBy        *string
+	compress       *string
+	archive        *string
+}
+
+// addOutputFlags registers the output flags on a command's flag set.
+func addOutputFlags(fs *flag.FlagSet) *outputFlags {
+	return &outputFlags{
+		format:         fs.String("format", "geojson", "Output format (geojson, topojson, kml, kmz, gml, gpx, mbtiles, pmtiles, csv, json, esrijson, txt)"),
+		output:         fs.String("output", "", "Output directory (default: current directory); - writes a single layer to standard output"),
+		overwrite:      fs.Bool("overwrite", false, "Overwrite existing output files"),
+		skipExisting:   fs.Bool("skip-existing", false, "Skip processing if output file already exists"),
+		prefix:         fs.String("prefix", "", "Prefix for output filenames"),
+		excludeSymbols: fs.Bool("exclude-symbols", false, "Exclude symbol information from output"),
+		saveSymbols:    fs.Bool("save-symbols", false, "Save symbology/images to a separate folder"),
+		minZoom:        fs.Int("min-zoom", export.DefaultMinZoom, "Minimum zoom level for vector tile formats (mbtiles, pmtiles)"),
+		maxZoom:        fs.Int("max-zoom", export.DefaultMaxZoom, "Maximum zoom level for vector tile formats (mbtiles, pmtiles)"),
+		tileAttributes: fs.String("tile-attributes", "", "Comma-separated attributes to keep in vector tiles (default: all)"),
+		kmlBalloon:     fs.String("kml-balloon", "", "HTML template file for KML/KMZ placemark balloons, with $[FIELD] placeholders"),
+		kmlFolderBy:    fs.String("kml-folder-by", "", "Group KML/KMZ placemarks into folders by renderer class (class), Web Map group path (path), or an attribute name"),
+		gpxRoutes:      fs.Bool("gpx-routes", false, "Write GPX lines as routes instead of tracks"),
+		gpxPolygons:    fs.String("gpx-polygons", export.GPXPolygonBoundary, "Write GPX polygons as outer boundaries (boundary), all rings (rings), or centroid waypoints (centroid)"),
+		gpxExtensions:  fs.Bool("gpx-extensions", false, "Carry attributes in GPX extensions"),
+		gpxSym:         fs.String("gpx-sym", "", "Set GPX waypoint symbols from the renderer class (class) or an attribute name"),
+		outputTemplate: fs.String("output-template", "", "Output path template, relative to the output directory, e.g. {service}/{path}/{layerName}_{date}"),
+		merge:          fs.Bool("merge", false, "Write all selected layers to one output, keeping each feature's layer as attributes"),
+		splitBy:        fs.String("split-by", "", "Write one file per distinct value of a field, named after the value"),
+		compress:       fs.String("compress", "", "Compress each output as it is written: gzip or zstd"),
+		archive:        fs.String("archive", "", "Bundle the outputs, saved symbols and manifest of the run into an archive: zip"),
+		manifest:       fs.String("manifest", ManifestFileName, "Run manifest file, relative to the output directory (empty to disable)"),
+	}
+}
+
+// toStdout reports whether the output is written to standard output.
+func (f *outputFlags) toStdout() bool {
+	return *f.output == OutputStdout
+}
+
+// config validates the output flags and returns the layer processing configuration. The
+// command sets the standard output writer of a configuration writing to stdout.
+func (f *outputFlags) config() (layerProcessConfig, error) {
+	outputDir := *f.output
+	if outputDir == "" {
+		outputDir, _ = os.Getwd()
+	}
+
+	kmlBalloon := ""
+	if *f.kmlBalloon != "" {
+		template, err := os.ReadFile(*f.kmlBalloon)
+		if err != nil {
+			return layerProcessConfig{}, fmt.Errorf("failed to read KML balloon template %s: %v", *f.kmlBalloon, err)
+		}
+		kmlBalloon = string(template)
+	}
+
+	if *f.outputTemplate != "" {
+		if err := validateOutputTemplate(*f.outputTemplate); err != nil {
+			return layerProcessConfig{}, err
+		}
+	}
+
+	var manifest *runManifest
+	if *f.manifest != "" {
+		manifest = &runManifest{path: *f.manifest}
+	}
+
+	// Standard output carries the output alone, so no manifest is written next to it.
+	toStdout := f.toStdout()
+	if toStdout {
+		if strings.TrimSpace(*f.splitBy) != "" {
+			return layerProcessConfig{}, fmt.Errorf("-split-by writes several files and cannot be used with -output -")
+		}
+		outputDir, _ = os.Getwd()
+		manifest = nil
+	}
 
-	if *urlPtr == "" {
+	switch *f.gpxPolygons {
+	case export.GPXPolygonBoundary, export.GPXPolygonRings, export.GPXPolygonCentroid:
+	default:
+		return layerProcessConfig{}, fmt.Errorf("invalid GPX polygon mode %s, expected boundary, rings or centroid", *f.gpxPolygons)
+	}
+
+	if _, err := compressionExtension(*f.compress); err != nil {
+		return layerProcessConfig{}, err
+	}
+
+	var archive *runArchive
+	switch *f.archive {
+	case "":
+	case ArchiveZip:
+		if toStdout {
+			return layerProcessConfig{}, fmt.Errorf("-archive cannot be used with -output -")
+		}
+		archivePath := filepath.Join(outputDir, fmt.Sprintf(ArchiveNameFormat, time.Now().Format(time.DateOnly)))
+		if _, err := os.Stat(archivePath); err == nil && !*f.overwrite {
+			return layerProcessConfig{}, fmt.Errorf("archive %s already exists. Use --overwrite", archivePath)
+		}
+		archive = &runArchive{path: archivePath}
+	default:
+		return layerProcessConfig{}, fmt.Errorf("invalid archive format %s, expected zip", *f.archive)
+	}
+
+	return layerProcessConfig{
+		format:         *f.format,
+		outputDir:      outputDir,
+		overwrite:      *f.overwrite,
+		skipExisting:   *f.skipExisting,
+		prefix:         *f.prefix,
+		excludeSymbols: *f.excludeSymbols,
+		saveSymbols:    *f.saveSymbols,
+		tileOptions: export.TileOptions{
+			MinZoom:    *f.minZoom,
+			MaxZoom:    *f.maxZoom,
+			Attributes: splitList(*f.tileAttributes),
+		},
+		kmlFolderBy: *f.kmlFolderBy,
+		kmlBalloon:  kmlBalloon,
+		gpxOptions: export.GPXOptions{
+			Routes:      *f.gpxRoutes,
+			PolygonMode: *f.gpxPolygons,
+			Extensions:  *f.gpxExtensions,
+		},
+		gpxSymBy:       *f.gpxSym,
+		outputTemplate: *f.outputTemplate,
+		runDate:        time.Now().Format(time.DateOnly),
+		manifest:       manifest,
+		mergeLayers:    *f.merge,
+		splitBy:        strings.TrimSpace(*f.splitBy),
+		compress:       *f.compress,
+		archive:        archive,
+	}, nil
+}
+
+// parseURLFlag validates and normalizes the -url flag of a command, exiting with the
+// command's usage when it is missing or invalid.
+func parseURLFlag(fs *flag.FlagSet, rawURL string) string {
+	if rawURL == "" {
 		printError("URL is required")
-		flag.Usage()
+		fs.Usage()
+		os.Exit(1)
+	}
+	normalizedURL := arcgis.NormalizeArcGISURL(rawURL)
+	if !arcgis.IsValidHTTPURL(normalizedURL) {
+		fmt.Fprintln(consoleOutput, "Error: Invalid URL provided.")
+		os.Exit(1)
+	}
+	return normalizedURL
+}
+
+// runDownload implements the download command: it finds the layers at a URL, lets the
+// user select them, and downloads and converts the selected layers concurrently.
+func runDownload(args []string) {
+	fs := flag.NewFlagSet(CommandDownload, flag.ExitOnError)
+	urlPtr := fs.String("url", "", "ArcGIS Feature Layer, Feature Server, Map Server, or ArcGIS Online Item URL")
+	selectAllPtr := fs.Bool("select-all", false, "Select all found Feature Layers automatically (no prompt)")
+	layersPtr := fs.String("layers", "", "Comma-separated layer IDs to select (no prompt)")
+	layerNamePtr := fs.String("layer-name", "", "Regular expression selecting layers by name or Web Map group path (no prompt)")
+	geometryTypePtr := fs.String("geometry-type", "", "Select layers by geometry type: point, polyline or polygon (no prompt)")
+	urlFilePtr := fs.String("url-file", "", "File listing URLs to download, one per line; - reads standard input")
+	configPtr := fs.String("config", "", "YAML or JSON job file listing the sources to download (replaces -url)")
+	noColorPtr := fs.Bool("no-color", false, "Disable colored output")
+	timeoutPtr := fs.Int("timeout", DefaultTimeoutSeconds, "HTTP request timeout in seconds")
+	output := addOutputFlags(fs)
+	fs.Parse(args)
+
+	useColor = !*noColorPtr
+
+	config, err := output.config()
+	if err != nil {
+		printError(fmt.Sprintf("Invalid options: %v", err))
 		os.Exit(1)
 	}
 
-	inputURL := *urlPtr
-	if !arcgis.IsValidHTTPURL(inputURL) {
-		normalizedURL := arcgis.NormalizeArcGISURL(inputURL)
-		if !arcgis.IsValidHTTPURL(normalizedURL) {
-			fmt.Println("Error: Invalid URL provided.")
+	if output.toStdout() {
+		if *configPtr != "" || *urlFilePtr != "" || *urlPtr == URLListStdin {
+			printError("Invalid options: -output - writes a single layer and cannot be used with -config, -url-file or -url -")
 			os.Exit(1)
 		}
-		inputURL = normalizedURL
-	} else {
-		inputURL = arcgis.NormalizeArcGISURL(inputURL)
+		// Progress messages move to stderr so that the output can be piped.
+		config.stdout = resultWriter()
 	}
 
-	outputDir := *outputPtr
-	if outputDir == "" {
-		outputDir, _ = os.Getwd()
+	if *configPtr != "" {
+		runJob(*configPtr, config, *timeoutPtr)
+		return
+	}
+
+	selector, err := newLayerSelector(*layersPtr, *layerNamePtr, *geometryTypePtr)
+	if err != nil {
+		printError(fmt.Sprintf("Invalid options: %v", err))
+		os.Exit(1)
 	}
 
 	client := arcgis.NewClient(time.Duration(*timeoutPtr) * time.Second)
 
-	var err error
-	if arcgis.IsArcGISOnlineItemURL(inputURL) {
-		fmt.Println("Detected ArcGIS Online Item URL...")
-		err = handleArcGISOnlineItem(client, inputURL, *selectAllPtr)
-	} else if strings.Contains(strings.ToLower(inputURL), "/mapserver") {
-		fmt.Println("Detected Map Server URL...")
-		err = handleMapServerURL(client, inputURL, *selectAllPtr)
-	} else if strings.Contains(strings.ToLower(inputURL), "/featureserver") {
-		fmt.Println("Detected Feature Server URL...")
-		err = handleFeatureServerURL(client, inputURL, *selectAllPtr)
-	} else {
-		fmt.Println("Assuming single Feature Layer URL...")
-		parts := strings.Split(inputURL, "/")
-		if len(parts) < 2 {
-			err = fmt.Errorf("invalid single layer URL format")
-		} else {
-			layerID := parts[len(parts)-1]
-			baseURL := strings.Join(parts[:len(parts)-1], "/")
-			layersToProcess[inputURL] = arcgis.AvailableLayerInfo{
-				ID:             layerID,
-				Name:           fmt.Sprintf("Layer_%s", layerID),
-				ServiceURL:     baseURL,
-				IsFeatureLayer: true,
-			}
+	if *urlFilePtr != "" || *urlPtr == URLListStdin {
+		listPath := *urlFilePtr
+		if listPath == "" {
+			listPath = URLListStdin
 		}
+		runURLList(client, listPath, selector, config)
+		return
 	}
+	inputURL := parseURLFlag(fs, *urlPtr)
 
+	layers, single, err := discoverLayers(client, inputURL)
+	if err == nil && selector.active() {
+		// Selectors replace the prompt, so a selection that matches nothing is an error.
+		if single {
+			layers = describeLayers(client, layers)
+		}
+		layers, err = selector.filter(layers)
+	}
+	if err == nil {
+		if single || selector.active() {
+			for _, layer := range layers {
+				layersToProcess[fmt.Sprintf(LayerKeyFormat, layer.ServiceURL, layer.ID)] = layer
+			}
+		} else if len(layers) > 0 {
+			err = selectAndAddLayers(layers, *selectAllPtr)
+		}
+	}
 	if err != nil {
 		printError(fmt.Sprintf("Error identifying or fetching initial data: %v", err))
 		os.Exit(1)
@@ -348,9 +678,35 @@ func main() {
 		printInfo("No Feature Layers were selected or found to process.")
 		os.Exit(0)
 	}
+	if err := checkSingleOutput(config, len(layersToProcess)); err != nil {
+		printError(fmt.Sprintf("Invalid options: %v", err))
+		os.Exit(1)
+	}
+
+	var counts layerCounts
+	processLayers(client, layersToProcess, config, &counts)
+	if err := finishRun(config); err != nil {
+		counts.errors.Add(1)
+	}
+	counts.summarize()
+}
+
+// checkSingleOutput checks that a run writing to standard output produces one output:
+// a single layer, or several layers merged into one.
+func checkSingleOutput(config layerProcessConfig, layerCount int) error {
+	if config.stdout != nil && layerCount > 1 && !config.mergeLayers {
+		return fmt.Errorf("-output - writes a single layer, but %d layers were selected; narrow the selection or add -merge", layerCount)
+	}
+	return nil
+}
 
+// processLayers downloads and exports the selected layers concurrently, recording the
+// outcome of each layer in counts.
+func processLayers(client *arcgis.Client, layersToProcess map[string]arcgis.AvailableLayerInfo, config layerProcessConfig, counts *layerCounts) {
+	if config.mergeLayers {
+		config.merger = &layerMerger{}
+	}
 	printInfo(fmt.Sprintf("\nProcessing %d selected layer(s) concurrently...", len(layersToProcess)))
-	var successCount, skippedCount, errorCount atomic.Int32
 	processedKeys := make(map[string]bool)
 	var wg sync.WaitGroup
 	mu := sync.Mutex{} // Mutex to protect processedKeys map
@@ -367,50 +723,59 @@ func main() {
 		wg.Add(1)
 		// Capture range variables for goroutine
 		layerInfoCopy := layerInfo
-		formatCopy := *formatPtr
-		outputDirCopy := outputDir
-		overwriteCopy := *overwritePtr
-		skipExistingCopy := *skipExistingPtr
-		prefixCopy := *prefixPtr
-		excludeSymbolsCopy := *excludeSymbolsPtr
-		saveSymbolsCopy := *saveSymbolsPtr
 
 		go func() {
 			defer wg.Done()
 			printInfo(fmt.Sprintf("Processing Layer: %s (ID: %s)", layerInfoCopy.Name, layerInfoCopy.ID))
-			config := layerProcessConfig{
-				format:         formatCopy,
-				outputDir:      outputDirCopy,
-				overwrite:      overwriteCopy,
-				skipExisting:   skipExistingCopy,
-				prefix:         prefixCopy,
-				excludeSymbols: excludeSymbolsCopy,
-				saveSymbols:    saveSymbolsCopy,
-			}
-			err := processSelectedLayer(client, layerInfoCopy, config)
-			if err != nil {
-				if err.Error() == "skipped existing file" {
-					printWarning(fmt.Sprintf("  Skipped layer %s (output file exists).", layerInfoCopy.Name))
-					skippedCount.Add(1)
-				} else if err.Error() == "no features found" {
-					printWarning(fmt.Sprintf("  Skipped layer %s (no features found).", layerInfoCopy.Name))
-					skippedCount.Add(1)
-				} else {
-					printError(fmt.Sprintf("  Error processing layer %s: %v", layerInfoCopy.Name, err))
-					errorCount.Add(1)
-				}
-			} else {
-				printSuccess(fmt.Sprintf("  Successfully processed layer %s.", layerInfoCopy.Name))
-				successCount.Add(1)
-			}
+			counts.record(layerInfoCopy.Name, processSelectedLayer(client, layerInfoCopy, config))
 		}()
 	}
 
 	wg.Wait() // Wait for all processing goroutines to finish
 
-	finalSuccessCount := successCount.Load()
-	finalSkippedCount := skippedCount.Load()
-	finalErrorCount := errorCount.Load()
+	if config.merger != nil {
+		finishMerge(config, counts)
+	}
+}
+
+// layerCounts tallies the outcome of processing each layer for the final summary.
+type layerCounts struct {
+	success atomic.Int32
+	skipped atomic.Int32
+	errors  atomic.Int32
+}
+
+// record reports the outcome of processing a layer and counts it.
+func (c *layerCounts) record(layerName string, err error) {
+	switch layerStatus(err) {
+	case StatusSuccess:
+		printSuccess(fmt.Sprintf("  Successfully processed layer %s.", layerName))
+		c.success.Add(1)
+	case StatusSkipped:
+		if errors.Is(err, errSkippedExisting) {
+			printWarning(fmt.Sprintf("  Skipped layer %s (output file exists).", layerName))
+		} else {
+			printWarning(fmt.Sprintf("  Skipped layer %s (no features found).", layerName))
+		}
+		c.skipped.Add(1)
+	default:
+		printError(fmt.Sprintf("  Error processing layer %s: %v", layerName, err))
+		c.errors.Add(1)
+	}
+}
+
+// add adds the outcomes counted by other to c.
+func (c *layerCounts) add(other *layerCounts) {
+	c.success.Add(other.success.Load())
+	c.skipped.Add(other.skipped.Load())
+	c.errors.Add(other.errors.Load())
+}
+
+// summarize prints the final summary, exiting with status 1 when any layer failed.
+func (c *layerCounts) summarize() {
+	finalSuccessCount := c.success.Load()
+	finalSkippedCount := c.skipped.Load()
+	finalErrorCount := c.errors.Load()
 
 	summary := fmt.Sprintf("\nProcessing Complete. %d layers succeeded, %d skipped, %d failed.", finalSuccessCount, finalSkippedCount, finalErrorCount)
 	if finalErrorCount > 0 {
@@ -423,12 +788,52 @@ func main() {
 	}
 }
 
+// discoverLayers finds the layers available at an ArcGIS URL. The single result is set
+// when the URL names one layer, which is then processed without prompting.
+func discoverLayers(client *arcgis.Client, inputURL string) ([]arcgis.AvailableLayerInfo, bool, error) {
+	if arcgis.IsArcGISOnlineItemURL(inputURL) {
+		fmt.Fprintln(consoleOutput, "Detected ArcGIS Online Item URL...")
+		return handleArcGISOnlineItem(client, inputURL)
+	} else if strings.Contains(strings.ToLower(inputURL), MapServerPath) {
+		fmt.Fprintln(consoleOutput, "Detected Map Server URL...")
+		layers, err := handleMapServerURL(client, inputURL)
+		return layers, false, err
+	} else if strings.Contains(strings.ToLower(inputURL), FeatureServerPath) {
+		fmt.Fprintln(consoleOutput, "Detected Feature Server URL...")
+		return handleFeatureServerURL(client, inputURL)
+	}
+
+	fmt.Fprintln(consoleOutput, "Assuming single Feature Layer URL...")
+	parts := strings.Split(inputURL, PathSeparator)
+	if len(parts) < MinURLParts {
+		return nil, false, fmt.Errorf("invalid single layer URL format")
+	}
+	layerID := parts[len(parts)-1]
+	return []arcgis.AvailableLayerInfo{{
+		ID:             layerID,
+		Name:           fmt.Sprintf(LayerNameFormat, layerID),
+		ServiceURL:     strings.Join(parts[:len(parts)-1], PathSeparator),
+		IsFeatureLayer: true,
+	}}, true, nil
+}
+
+// splitList splits a comma-separated flag value into trimmed, non-empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // printColor prints a message to the console with the specified color.
 func printColor(colorCode string, message string) {
 	if useColor {
-		fmt.Printf("%s%s%s\n", colorCode, message, colorReset)
+		fmt.Fprintf(consoleOutput, "%s%s%s\n", colorCode, message, colorReset)
 	} else {
-		fmt.Println(message)
+		fmt.Fprintln(consoleOutput, message)
 	}
 }
 
@@ -452,36 +857,38 @@ func printError(message string) {
 	printColor(colorRed, message)
 }
 
-// handleArcGISOnlineItem handles processing for an ArcGIS Online item URL.
-func handleArcGISOnlineItem(client *arcgis.Client, itemPageURL string, selectAll bool) error {
+// handleArcGISOnlineItem finds the layers of an ArcGIS Online item URL.
+func handleArcGISOnlineItem(client *arcgis.Client, itemPageURL string) ([]arcgis.AvailableLayerInfo, bool, error) {
 	itemData, err := client.HandleArcGISOnlineItem(itemPageURL)
 	if err != nil {
-		return err
+		return nil, false, err
 	}
 
 	switch itemData.Type {
 	case "Feature Service":
 		if itemData.URL == "" {
-			return fmt.Errorf("feature Service item has no URL")
+			return nil, false, fmt.Errorf("feature Service item has no URL")
 		}
-		return handleFeatureServerURL(client, itemData.URL, selectAll)
+		return handleFeatureServerURL(client, itemData.URL)
 	case "Map Service":
 		if itemData.URL == "" {
-			return fmt.Errorf("map Service item has no URL")
+			return nil, false, fmt.Errorf("map Service item has no URL")
 		}
-		return handleMapServerURL(client, itemData.URL, selectAll)
+		layers, err := handleMapServerURL(client, itemData.URL)
+		return layers, false, err
 	case "Web Map":
-		return handleWebMap(client, itemData.ID, selectAll)
+		layers, err := handleWebMap(client, itemData.ID)
+		return layers, false, err
 	default:
-		return fmt.Errorf("unsupported item type: %s. Currently supports Feature Service, Map Service, Web Map", itemData.Type)
+		return nil, false, fmt.Errorf("unsupported item type: %s. Currently supports Feature Service, Map Service, Web Map", itemData.Type)
 	}
 }
 
-// handleWebMap handles processing for an ArcGIS Online Web Map item.
-func handleWebMap(client *arcgis.Client, itemID string, selectAll bool) error {
+// handleWebMap finds the layers of an ArcGIS Online Web Map item.
+func handleWebMap(client *arcgis.Client, itemID string) ([]arcgis.AvailableLayerInfo, error) {
 	webMapData, err := client.HandleWebMap(itemID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	availableLayers := []arcgis.AvailableLayerInfo{}
@@ -490,12 +897,12 @@ func handleWebMap(client *arcgis.Client, itemID string, selectAll bool) error {
 	}
 
 	if len(availableLayers) == 0 {
-		fmt.Println("  No processable Feature Layers found in this Web Map.")
-		return nil
+		fmt.Fprintln(consoleOutput, "  No processable Feature Layers found in this Web Map.")
+		return nil, nil
 	}
 
-	fmt.Printf("  Found %d potential Feature Layers in Web Map.\n", len(availableLayers))
-	return selectAndAddLayers(availableLayers, selectAll)
+	fmt.Fprintf(consoleOutput, "  Found %d potential Feature Layers in Web Map.\n", len(availableLayers))
+	return availableLayers, nil
 }
 
 // processOperationalLayer recursively processes operational layers in a Web Map.
@@ -503,19 +910,19 @@ func processOperationalLayer(client *arcgis.Client, opLayer arcgis.OperationalLa
 	currentPath := append(parentPath, opLayer.Title)
 
 	if opLayer.LayerType == "GroupLayer" || len(opLayer.Layers) > 0 {
-		fmt.Printf("    Processing Group: %s\n", strings.Join(currentPath, " > "))
+		fmt.Fprintf(consoleOutput, "    Processing Group: %s\n", strings.Join(currentPath, " > "))
 		for _, subLayer := range opLayer.Layers {
 			processOperationalLayer(client, subLayer, currentPath, availableLayers)
 		}
 	} else if opLayer.FeatureCollection != nil && len(opLayer.FeatureCollection.Layers) > 0 {
-		fmt.Printf("    Skipping Inline Feature Collection: %s (Direct processing not yet implemented)\n", strings.Join(currentPath, " > "))
+		fmt.Fprintf(consoleOutput, "    Skipping Inline Feature Collection: %s (Direct processing not yet implemented)\n", strings.Join(currentPath, " > "))
 	} else if opLayer.URL != "" && (strings.Contains(strings.ToLower(opLayer.URL), "/featureserver") || strings.Contains(strings.ToLower(opLayer.URL), "/mapserver")) {
 		serviceURL := arcgis.NormalizeArcGISURL(opLayer.URL)
 		layerIDStr := ""
 		parts := strings.Split(serviceURL, "/")
 		lastPart := parts[len(parts)-1]
 		if _, err := strconv.Atoi(lastPart); err != nil {
-			fmt.Printf("    Service URL found: %s for layer %s. Fetching service layers...\n", serviceURL, opLayer.Title)
+			fmt.Fprintf(consoleOutput, "    Service URL found: %s for layer %s. Fetching service layers...\n", serviceURL, opLayer.Title)
 			var subLayers []arcgis.AvailableLayerInfo
 			var fetchErr error
 			if strings.Contains(strings.ToLower(serviceURL), "/featureserver") {
@@ -524,7 +931,7 @@ func processOperationalLayer(client *arcgis.Client, opLayer arcgis.OperationalLa
 				subLayers, fetchErr = client.FetchServiceLayers(serviceURL, "MapServer")
 			}
 			if fetchErr != nil {
-				fmt.Printf("      Warning: Failed to fetch layers for service %s: %v\n", serviceURL, fetchErr)
+				fmt.Fprintf(consoleOutput, "      Warning: Failed to fetch layers for service %s: %v\n", serviceURL, fetchErr)
 			} else {
 				for _, sl := range subLayers {
 					sl.ParentPath = currentPath
@@ -537,7 +944,7 @@ func processOperationalLayer(client *arcgis.Client, opLayer arcgis.OperationalLa
 		serviceURL = strings.Join(parts[:len(parts)-1], "/")
 
 		if layerIDStr != "" {
-			fmt.Printf("    Adding Layer Reference: %s (ID: %s) from Service: %s\n", strings.Join(currentPath, " > "), layerIDStr, serviceURL)
+			fmt.Fprintf(consoleOutput, "    Adding Layer Reference: %s (ID: %s) from Service: %s\n", strings.Join(currentPath, " > "), layerIDStr, serviceURL)
 			layerInfo := arcgis.AvailableLayerInfo{
 				ID:             layerIDStr,
 				Name:           opLayer.Title,
@@ -549,15 +956,15 @@ func processOperationalLayer(client *arcgis.Client, opLayer arcgis.OperationalLa
 			*availableLayers = append(*availableLayers, layerInfo)
 		}
 	} else if opLayer.ItemID != "" {
-		fmt.Printf("    Processing Item Reference: %s (ID: %s)\n", strings.Join(currentPath, " > "), opLayer.ItemID)
+		fmt.Fprintf(consoleOutput, "    Processing Item Reference: %s (ID: %s)\n", strings.Join(currentPath, " > "), opLayer.ItemID)
 		itemData, err := client.HandleArcGISOnlineItem(fmt.Sprintf("https://www.arcgis.com/home/item.html?id=%s", opLayer.ItemID))
 		if err != nil || itemData.Error != nil || itemData.URL == "" {
-			fmt.Printf("      Warning: Failed to fetch or use referenced item %s: %v\n", opLayer.ItemID, err)
+			fmt.Fprintf(consoleOutput, "      Warning: Failed to fetch or use referenced item %s: %v\n", opLayer.ItemID, err)
 			if itemData.Error != nil {
-				fmt.Printf("      Item API Error: %s\n", itemData.Error.Message)
+				fmt.Fprintf(consoleOutput, "      Item API Error: %s\n", itemData.Error.Message)
 			}
 		} else {
-			fmt.Printf("      Referenced Item Type: %s, URL: %s\n", itemData.Type, itemData.URL)
+			fmt.Fprintf(consoleOutput, "      Referenced Item Type: %s, URL: %s\n", itemData.Type, itemData.URL)
 			var subLayers []arcgis.AvailableLayerInfo
 			var fetchErr error
 			if strings.Contains(strings.ToLower(itemData.URL), "/featureserver") {
@@ -565,11 +972,11 @@ func processOperationalLayer(client *arcgis.Client, opLayer arcgis.OperationalLa
 			} else if strings.Contains(strings.ToLower(itemData.URL), "/mapserver") {
 				subLayers, fetchErr = client.FetchServiceLayers(itemData.URL, "MapServer")
 			} else {
-				fmt.Printf("      Warning: Referenced item %s has unsupported service URL type: %s\n", opLayer.ItemID, itemData.URL)
+				fmt.Fprintf(consoleOutput, "      Warning: Referenced item %s has unsupported service URL type: %s\n", opLayer.ItemID, itemData.URL)
 			}
 
 			if fetchErr != nil {
-				fmt.Printf("      Warning: Failed to fetch layers for referenced item service %s: %v\n", itemData.URL, fetchErr)
+				fmt.Fprintf(consoleOutput, "      Warning: Failed to fetch layers for referenced item service %s: %v\n", itemData.URL, fetchErr)
 			} else {
 				for _, sl := range subLayers {
 					sl.ParentPath = append(currentPath, sl.ParentPath...)
@@ -578,26 +985,27 @@ func processOperationalLayer(client *arcgis.Client, opLayer arcgis.OperationalLa
 			}
 		}
 	} else {
-		fmt.Printf("    Skipping layer '%s': No URL, ItemID, or FeatureCollection found.\n", opLayer.Title)
+		fmt.Fprintf(consoleOutput, "    Skipping layer '%s': No URL, ItemID, or FeatureCollection found.\n", opLayer.Title)
 	}
 }
 
-// handleMapServerURL handles processing for an ArcGIS Map Server URL.
-func handleMapServerURL(client *arcgis.Client, mapServerURL string, selectAll bool) error {
+// handleMapServerURL finds the layers of an ArcGIS Map Server URL.
+func handleMapServerURL(client *arcgis.Client, mapServerURL string) ([]arcgis.AvailableLayerInfo, error) {
 	layers, err := client.FetchServiceLayers(mapServerURL, "MapServer")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if len(layers) == 0 {
-		fmt.Println("  No processable Feature Layers found in this Map Service.")
-		return nil
+		fmt.Fprintln(consoleOutput, "  No processable Feature Layers found in this Map Service.")
+		return nil, nil
 	}
-	fmt.Printf("  Found %d potential Feature Layers in Map Service.\n", len(layers))
-	return selectAndAddLayers(layers, selectAll)
+	fmt.Fprintf(consoleOutput, "  Found %d potential Feature Layers in Map Service.\n", len(layers))
+	return layers, nil
 }
 
-// handleFeatureServerURL handles processing for an ArcGIS Feature Server URL.
-func handleFeatureServerURL(client *arcgis.Client, featureServerURL string, selectAll bool) error {
+// handleFeatureServerURL finds the layers of an ArcGIS Feature Server URL, or the single
+// layer it names.
+func handleFeatureServerURL(client *arcgis.Client, featureServerURL string) ([]arcgis.AvailableLayerInfo, bool, error) {
 	layerID := ""
 	parts := strings.Split(featureServerURL, "/")
 	lastPart := parts[len(parts)-1]
@@ -607,25 +1015,24 @@ func handleFeatureServerURL(client *arcgis.Client, featureServerURL string, sele
 	}
 
 	if layerID != "" {
-		fmt.Println("  Processing as single Feature Layer URL...")
-		layersToProcess[featureServerURL+"/"+layerID] = arcgis.AvailableLayerInfo{
+		fmt.Fprintln(consoleOutput, "  Processing as single Feature Layer URL...")
+		return []arcgis.AvailableLayerInfo{{
 			ID:             layerID,
 			Name:           fmt.Sprintf("Layer_%s", layerID),
 			ServiceURL:     featureServerURL,
 			IsFeatureLayer: true,
-		}
-		return nil
+		}}, true, nil
 	}
 	layers, err := client.FetchServiceLayers(featureServerURL, "FeatureServer")
 	if err != nil {
-		return err
+		return nil, false, err
 	}
 	if len(layers) == 0 {
-		fmt.Println("  No processable Feature Layers found in this Feature Service.")
-		return nil
+		fmt.Fprintln(consoleOutput, "  No processable Feature Layers found in this Feature Service.")
+		return nil, false, nil
 	}
-	fmt.Printf("  Found %d potential Feature Layers in Feature Service.\n", len(layers))
-	return selectAndAddLayers(layers, selectAll)
+	fmt.Fprintf(consoleOutput, "  Found %d potential Feature Layers in Feature Service.\n", len(layers))
+	return layers, false, nil
 }
 
 // selectAndAddLayers prompts the user to select layers from a list and adds them to the processing queue.
@@ -643,15 +1050,15 @@ func selectAndAddLayers(availableLayers []arcgis.AvailableLayerInfo, selectAll b
 		return nil
 	}
 
-	fmt.Println("  Please select the Feature Layers to process:")
+	fmt.Fprintln(consoleOutput, "  Please select the Feature Layers to process:")
 	for i, layer := range availableLayers {
 		pathStr := ""
 		if len(layer.ParentPath) > 0 {
 			pathStr = fmt.Sprintf(" (Path: %s)", strings.Join(layer.ParentPath, " > "))
 		}
-		fmt.Printf("    %d: %s (ID: %s, Type: %s, Geometry: %s)%s\n", i+1, layer.Name, layer.ID, layer.Type, layer.GeometryType, pathStr)
+		fmt.Fprintf(consoleOutput, "    %d: %s (ID: %s, Type: %s, Geometry: %s)%s\n", i+1, layer.Name, layer.ID, layer.Type, layer.GeometryType, pathStr)
 	}
-	fmt.Print("  Enter comma-separated numbers (e.g., 1,3,4) or 'all': ")
+	fmt.Fprint(consoleOutput, "  Enter comma-separated numbers (e.g., 1,3,4) or 'all': ")
 
 	reader := bufio.NewReader(os.Stdin)
 	input, _ := reader.ReadString('\n')
@@ -692,18 +1099,230 @@ func selectAndAddLayers(availableLayers []arcgis.AvailableLayerInfo, selectAll b
 	return nil
 }
 
-// processSelectedLayer processes a single selected layer and exports it to the specified format.
+// layerSelector picks layers without prompting, by layer ID, by a pattern matched against
+// the layer name and its Web Map group path, and by geometry type.
+type layerSelector struct {
+	ids           map[string]bool
+	name          *regexp.Regexp
+	geometryTypes map[string]bool
+}
+
+// newLayerSelector parses the selector flags. Each argument may be empty to leave that
+// criterion unset.
+//
+// Parameters:
+//   - ids: Comma-separated layer IDs (e.g. "0,3,7")
+//   - namePattern: Regular expression matched against the layer name or its path
+//   - geometryTypes: Comma-separated geometry types (point, polyline, polygon)
+//
+// Returns:
+//   - layerSelector: The parsed selector
+//   - error: Any error that occurred while parsing the selectors
+func newLayerSelector(ids, namePattern, geometryTypes string) (layerSelector, error) {
+	var selector layerSelector
+	for _, id := range splitList(ids) {
+		if _, err := strconv.Atoi(id); err != nil {
+			return selector, fmt.Errorf("invalid layer ID %q in -layers", id)
+		}
+		if selector.ids == nil {
+			selector.ids = make(map[string]bool)
+		}
+		selector.ids[id] = true
+	}
+	if namePattern != "" {
+		pattern, err := regexp.Compile(namePattern)
+		if err != nil {
+			return selector, fmt.Errorf("invalid -layer-name pattern: %v", err)
+		}
+		selector.name = pattern
+	}
+	for _, geometryType := range splitList(geometryTypes) {
+		geometryType = strings.ToLower(geometryType)
+		switch geometryType {
+		case SelectGeometryPoint, SelectGeometryPolyline, SelectGeometryPolygon:
+		default:
+			return selector, fmt.Errorf("invalid geometry type %s, expected point, polyline or polygon", geometryType)
+		}
+		if selector.geometryTypes == nil {
+			selector.geometryTypes = make(map[string]bool)
+		}
+		selector.geometryTypes[geometryType] = true
+	}
+	return selector, nil
+}
+
+// active reports whether any selector is set.
+func (s layerSelector) active() bool {
+	return s.ids != nil || s.name != nil || s.geometryTypes != nil
+}
+
+// matches reports whether a layer satisfies every selector that is set.
+func (s layerSelector) matches(layer arcgis.AvailableLayerInfo) bool {
+	if s.ids != nil && !s.ids[layer.ID] {
+		return false
+	}
+	if s.name != nil {
+		fullPath := strings.Join(append(append([]string{}, layer.ParentPath...), layer.Name), PathSeparatorSpace)
+		if !s.name.MatchString(layer.Name) && !s.name.MatchString(fullPath) {
+			return false
+		}
+	}
+	if s.geometryTypes != nil && !s.geometryTypes[selectorGeometryType(layer.GeometryType)] {
+		return false
+	}
+	return true
+}
+
+// filter returns the layers matching the selector, or an error when none match.
+func (s layerSelector) filter(layers []arcgis.AvailableLayerInfo) ([]arcgis.AvailableLayerInfo, error) {
+	var selected []arcgis.AvailableLayerInfo
+	for _, layer := range layers {
+		if s.matches(layer) {
+			selected = append(selected, layer)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no layers match the selection (%s) among %d available layer(s); run '%s -url ...' to see them", s, len(layers), CommandList)
+	}
+	return selected, nil
+}
+
+// String describes the selectors that are set, for error messages.
+func (s layerSelector) String() string {
+	var parts []string
+	if s.ids != nil {
+		parts = append(parts, "layers "+strings.Join(selectedKeys(s.ids), ","))
+	}
+	if s.name != nil {
+		parts = append(parts, "layer-name "+s.name.String())
+	}
+	if s.geometryTypes != nil {
+		parts = append(parts, "geometry-type "+strings.Join(selectedKeys(s.geometryTypes), ","))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// describeLayers fills in the name, type and geometry type of layers named by a single
+// layer URL from their metadata, so that selectors can match them. Layers whose metadata
+// cannot be fetched are returned unchanged.
+func describeLayers(client *arcgis.Client, layers []arcgis.AvailableLayerInfo) []arcgis.AvailableLayerInfo {
+	described := make([]arcgis.AvailableLayerInfo, len(layers))
+	for i, layer := range layers {
+		described[i] = layer
+		metadata, err := fetchLayerMetadata(client, layer)
+		if err != nil {
+			printWarning(fmt.Sprintf("  Warning: %v", err))
+			continue
+		}
+		if metadata.Name != "" {
+			described[i].Name = metadata.Name
+		}
+		described[i].Type = metadata.Type
+		described[i].GeometryType = metadata.GeometryType
+	}
+	return described
+}
+
+// selectedKeys returns the keys of a selector set in sorted order.
+func selectedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// selectorGeometryType maps an Esri geometry type to its selector keyword; multipoint
+// layers count as points.
+func selectorGeometryType(geometryType string) string {
+	switch geometryType {
+	case "esriGeometryPoint", "esriGeometryMultipoint":
+		return SelectGeometryPoint
+	case "esriGeometryPolyline":
+		return SelectGeometryPolyline
+	case "esriGeometryPolygon", "esriGeometryEnvelope":
+		return SelectGeometryPolygon
+	}
+	return strings.ToLower(geometryType)
+}
+
+// processSelectedLayer processes a single selected layer and exports it to the specified format,
+// recording the outcome in the run manifest.
 func processSelectedLayer(client *arcgis.Client, layerInfo arcgis.AvailableLayerInfo, config layerProcessConfig) error {
+	start := time.Now()
+	report, err := downloadLayer(client, layerInfo, config)
+	config.manifest.add(layerInfo, config.format, report, err, time.Since(start))
+	return err
+}
+
+// downloadLayer fetches a layer's metadata and features and exports them.
+func downloadLayer(client *arcgis.Client, layerInfo arcgis.AvailableLayerInfo, config layerProcessConfig) (layerReport, error) {
+	report := layerReport{name: layerInfo.Name}
+	layerMetadata, err := fetchLayerMetadata(client, layerInfo)
+	if err != nil {
+		return report, err
+	}
+
+	response, err := client.FetchFeaturesWithOptions(layerInfo.ServiceURL, layerInfo.ID, config.query)
+	if err != nil {
+		if errors.Is(err, arcgis.ErrNoFeatures) {
+			return report, errNoFeatures
+		}
+		return report, fmt.Errorf("failed to fetch features: %v", err)
+	}
+
+	report, err = exportLayer(layerInfo, layerMetadata, response.Features, config)
+	if response.ExceededTransferLimit {
+		report.warnings = append([]string{"feature transfer limit exceeded, results may be incomplete"}, report.warnings...)
+	}
+	return report, err
+}
+
+// fetchLayerMetadata fetches the metadata of a layer, such as its fields and renderer.
+func fetchLayerMetadata(client *arcgis.Client, layerInfo arcgis.AvailableLayerInfo) (arcgis.Layer, error) {
 	metadataURL := fmt.Sprintf("%s/%s?f=json", layerInfo.ServiceURL, layerInfo.ID)
 	var layerMetadata arcgis.Layer
 	err := client.FetchAndDecode(metadataURL, &layerMetadata)
 	if err != nil {
-		return fmt.Errorf("failed to fetch layer metadata from %s: %v", metadataURL, err)
+		return layerMetadata, fmt.Errorf("failed to fetch layer metadata from %s: %v", metadataURL, err)
 	}
 	if layerMetadata.Error != nil {
-		return fmt.Errorf("layer metadata API error for %s: %s", metadataURL, layerMetadata.Error.Message)
+		return layerMetadata, fmt.Errorf("layer metadata API error for %s: %s", metadataURL, layerMetadata.Error.Message)
+	}
+	return layerMetadata, nil
+}
+
+// exportLayer exports a layer's features to the configured format, resolving their symbols
+// from the layer's renderer. It is shared by downloaded layers and local files. In merge
+// mode the layer is collected to be written with the others instead.
+func exportLayer(layerInfo arcgis.AvailableLayerInfo, layerMetadata arcgis.Layer, features []arcgis.Feature, config layerProcessConfig) (layerReport, error) {
+	layer, err := prepareLayer(layerInfo, layerMetadata, features, config)
+	if err != nil {
+		return layer.report, err
 	}
+	if config.merger != nil {
+		config.merger.add(layer)
+		return layer.report, nil
+	}
+	return writeLayer(layer, config)
+}
 
+// preparedLayer holds a layer's features ready to be encoded: sorted by object ID, with
+// their symbols resolved and, except in features, dates formatted as ISO 8601.
+type preparedLayer struct {
+	info           arcgis.AvailableLayerInfo
+	metadata       arcgis.Layer
+	name           string // Resolved layer name
+	safeName       string // Layer name made safe for file names
+	features       []arcgis.Feature
+	exportFeatures []convert.Feature
+	geoJSON        *convert.GeoJSON
+	report         layerReport
+}
+
+// prepareLayer resolves a layer's name and symbols and converts its features for export.
+func prepareLayer(layerInfo arcgis.AvailableLayerInfo, layerMetadata arcgis.Layer, features []arcgis.Feature, config layerProcessConfig) (*preparedLayer, error) {
 	actualLayerName := layerMetadata.Name
 	if actualLayerName == "" {
 		actualLayerName = layerInfo.Name
@@ -711,22 +1330,18 @@ func processSelectedLayer(client *arcgis.Client, layerInfo arcgis.AvailableLayer
 	if actualLayerName == "" {
 		actualLayerName = fmt.Sprintf("Layer_%s", layerInfo.ID)
 	}
+	report := layerReport{name: actualLayerName, featureCount: len(features)}
 
-	features, err := client.FetchFeatures(layerInfo.ServiceURL, layerInfo.ID)
-	if err != nil {
-		if strings.Contains(err.Error(), "no features found") {
-			return fmt.Errorf("no features found")
-		}
-		return fmt.Errorf("failed to fetch features: %v", err)
-	}
+	arcgis.SortFeaturesByObjectID(features, layerMetadata.ObjectIDFieldName())
 
 	// Create symbols directory if needed
 	symbolsDir := ""
 	if config.saveSymbols {
 		symbolsDir = filepath.Join(config.outputDir, "symbols", actualLayerName)
 		if err := os.MkdirAll(symbolsDir, DirPerm); err != nil {
-			return fmt.Errorf("failed to create symbols directory %s: %v", symbolsDir, err)
+			return &preparedLayer{report: report}, fmt.Errorf("failed to create symbols directory %s: %v", symbolsDir, err)
 		}
+		config.archive.add(symbolsDir)
 	}
 
 	if !config.excludeSymbols && layerMetadata.DrawingInfo != nil && layerMetadata.DrawingInfo.Renderer != nil {
@@ -737,180 +1352,460 @@ func processSelectedLayer(client *arcgis.Client, layerInfo arcgis.AvailableLayer
 			relativeSymbolsDir = filepath.Join("symbols", actualLayerName)
 		}
 
-		if renderer.DefaultSymbol != nil {
-			defaultSymbolCopy := *renderer.DefaultSymbol
-			if config.saveSymbols {
-				symbolFilenameBase := "default"
-				// Save default symbol
-				if err := saveSymbol(&defaultSymbolCopy, symbolsDir, symbolFilenameBase); err != nil {
-					printWarning(fmt.Sprintf("  Warning: Failed to save default symbol: %v", err))
-				} else {
-					// Update URL to relative path
-					ext := getSymbolFileExtension(&defaultSymbolCopy)
-					defaultSymbolCopy.URL = filepath.ToSlash(filepath.Join(relativeSymbolsDir, symbolFilenameBase+ext)) // Use forward slashes for KML
-				}
-			}
-			for i := range features {
-				if features[i].Attributes == nil {
-					features[i].Attributes = make(map[string]interface{})
-				}
-				features[i].Attributes[KeySymbol] = &defaultSymbolCopy
+		// Resolve each feature's symbol from a copy of the renderer whose symbols point at the saved files.
+		resolved := *renderer
+		resolved.DefaultSymbol = saveRendererSymbol(renderer.DefaultSymbol, symbolsDir, relativeSymbolsDir, "default", config.saveSymbols)
+		resolved.Symbol = saveRendererSymbol(renderer.Symbol, symbolsDir, relativeSymbolsDir, "symbol", config.saveSymbols)
+		classIndex := 0
+		resolved.ClassBreakInfos = make([]arcgis.ClassBreakInfo, len(renderer.ClassBreakInfos))
+		for i, info := range renderer.ClassBreakInfos {
+			resolved.ClassBreakInfos[i] = info
+			resolved.ClassBreakInfos[i].Symbol = saveRendererSymbol(info.Symbol, symbolsDir, relativeSymbolsDir, classSymbolName(info.Label, classIndex), config.saveSymbols)
+			classIndex++
+		}
+		resolved.UniqueValueGroups = make([]arcgis.UniqueValueGroup, len(renderer.UniqueValueGroups))
+		for g, group := range renderer.UniqueValueGroups {
+			resolved.UniqueValueGroups[g] = group
+			resolved.UniqueValueGroups[g].Classes = make([]arcgis.UniqueValueClass, len(group.Classes))
+			for c, class := range group.Classes {
+				resolved.UniqueValueGroups[g].Classes[c] = class
+				resolved.UniqueValueGroups[g].Classes[c].Symbol = saveRendererSymbol(class.Symbol, symbolsDir, relativeSymbolsDir, classSymbolName(class.Label, classIndex), config.saveSymbols)
+				classIndex++
 			}
 		}
+		resolved.UniqueValueInfos = make([]arcgis.UniqueValueInfo, len(renderer.UniqueValueInfos))
+		for i, info := range renderer.UniqueValueInfos {
+			resolved.UniqueValueInfos[i] = info
+			resolved.UniqueValueInfos[i].Symbol = saveRendererSymbol(info.Symbol, symbolsDir, relativeSymbolsDir, classSymbolName(info.Label, classIndex), config.saveSymbols)
+			classIndex++
+		}
 
-		// Handle unique value renderer
-		if renderer.Type == "uniqueValue" && renderer.Field1 != "" && len(renderer.UniqueValueGroups) > 0 {
-			// Create a map for faster symbol lookup based on attribute value
-			symbolMap := make(map[string]*arcgis.Symbol)
-			for _, group := range renderer.UniqueValueGroups {
-				for _, class := range group.Classes {
-					if class.Symbol != nil {
-						classSymbolCopy := *class.Symbol
-						if config.saveSymbols {
-							// Sanitize label for filename
-							safLabel := regexp.MustCompile(`[<>:"/\|?*\s]`).ReplaceAllString(class.Label, "_")
-							if safLabel == "" {
-								safLabel = fmt.Sprintf("class_%d", len(symbolMap)) // Fallback name
-							}
-							symbolFilenameBase := fmt.Sprintf("class_%s", safLabel)
-							// Save class symbol
-							if err := saveSymbol(&classSymbolCopy, symbolsDir, symbolFilenameBase); err != nil {
-								printWarning(fmt.Sprintf("  Warning: Failed to save class symbol %s: %v", symbolFilenameBase, err))
-							} else {
-								// Update URL to relative path
-								text := getSymbolFileExtension(&classSymbolCopy)
-								classSymbolCopy.URL = filepath.ToSlash(filepath.Join(relativeSymbolsDir, symbolFilenameBase+text))
-							}
-						}
-						// Map values to the potentially modified symbol copy
-						for _, valueSet := range class.Values {
-							if len(valueSet) > 0 {
-								symbolMap[valueSet[0]] = &classSymbolCopy
-							}
-						}
-					}
-				}
+		// Class symbols take precedence; the default symbol is only used when no class matches.
+		for i := range features {
+			if features[i].Attributes == nil {
+				features[i].Attributes = make(map[string]interface{})
+			}
+			if symbol := resolved.SymbolFor(features[i].Attributes); symbol != nil {
+				features[i].Attributes[KeySymbol] = symbol
 			}
+		}
+	}
 
-			// Assign symbols to features based on the map
-			for i := range features {
-				if features[i].Attributes == nil {
-					features[i].Attributes = make(map[string]interface{})
-				}
-				// Check if the feature already has a symbol (e.g., default)
-				if _, hasSymbol := features[i].Attributes[KeySymbol]; hasSymbol {
-					continue // Skip if default symbol was already assigned
-				}
-				// Get the attribute value
-				if fieldValue, ok := features[i].Attributes[renderer.Field1]; ok && fieldValue != nil {
-					fieldValueStr := fmt.Sprintf("%v", fieldValue)
-					// Look up the symbol in the map
-					if mappedSymbol, found := symbolMap[fieldValueStr]; found {
-						features[i].Attributes[KeySymbol] = mappedSymbol
-					}
-				}
+	safeFilenameBase := safeFilename(actualLayerName)
+	if safeFilenameBase == "" {
+		safeFilenameBase = fmt.Sprintf(LayerNameFormat, layerInfo.ID)
+	}
+
+	// Dates are written as ISO 8601 in every format except Esri JSON, which keeps epoch milliseconds.
+	dateFields, _, _ := layerTimeFields(&layerMetadata)
+	exportFeatures := convert.FormatDateFields(convertFeatures(features), dateFields)
+
+	// Features whose geometry cannot be converted are left out of the geometry formats.
+	geojsonData, err := convert.ToGeoJSON(exportFeatures)
+	if err != nil {
+		return &preparedLayer{report: report}, fmt.Errorf("failed to convert features to GeoJSON objects: %v", err)
+	}
+	if skipped := len(exportFeatures) - len(geojsonData.Features); skipped > 0 && !attributeFormats[strings.ToLower(config.format)] {
+		report.warnings = append(report.warnings, fmt.Sprintf("%d feature(s) without a supported geometry skipped", skipped))
+		report.featureCount -= skipped
+	}
+
+	return &preparedLayer{
+		info:           layerInfo,
+		metadata:       layerMetadata,
+		name:           actualLayerName,
+		safeName:       safeFilenameBase,
+		features:       features,
+		exportFeatures: exportFeatures,
+		geoJSON:        geojsonData,
+		report:         report,
+	}, nil
+}
+
+// outputBase returns the layer's output path without extension; an output template may
+// place it in subdirectories.
+func (l *preparedLayer) outputBase(config layerProcessConfig) string {
+	outputName := l.safeName
+	if config.outputTemplate != "" {
+		outputName = expandOutputTemplate(config.outputTemplate, l.info, l.safeName, config.format, config.runDate)
+	}
+	return filepath.Join(config.outputDir, filepath.Dir(outputName), config.prefix+filepath.Base(outputName))
+}
+
+// layerEncoder writes an encoded output to w.
+type layerEncoder func(w io.Writer) error
+
+// encodeString returns an encoder writing data in chunks, so that writers without a
+// WriteString method, such as compressors, never hold a second copy of the whole output.
+func encodeString(data string) layerEncoder {
+	return func(w io.Writer) error {
+		for len(data) > 0 {
+			n := min(len(data), OutputChunkSize)
+			if _, err := io.WriteString(w, data[:n]); err != nil {
+				return err
 			}
+			data = data[n:]
 		}
+		return nil
+	}
+}
+
+// encodeBytes returns an encoder writing data.
+func encodeBytes(data []byte) layerEncoder {
+	return func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
 	}
+}
 
-	var data string
-	var fileExt string
+// encodeLayer converts a prepared layer to the configured format, returning an encoder
+// writing the output, the file extension and any files written next to it, such as a
+// GML schema. GeoJSON, JSON, CSV and text are encoded feature by feature as they are
+// written; the other formats are encoded up front, so that their errors are reported
+// before any file is created.
+func encodeLayer(layer *preparedLayer, outputBase string, config layerProcessConfig) (encode layerEncoder, fileExt string, sidecars map[string]string, err error) {
+	// Additional files written next to the output, keyed by file name
+	sidecars = make(map[string]string)
 	switch strings.ToLower(config.format) {
 	case FormatGeoJSON:
-		geojsonData, err := convert.ToGeoJSON(convertFeatures(features))
+		encode = func(w io.Writer) error { return convert.WriteGeoJSON(w, layer.geoJSON) }
+		fileExt = "geojson"
+	case "kml":
+		data, err := export.ConvertGeoJSONToKMLWithOptions(layer.geoJSON, layer.name, kmlOptions(config, layer.info, &layer.metadata))
 		if err != nil {
-			return fmt.Errorf("failed to convert features to GeoJSON objects: %v", err)
+			return nil, "", nil, fmt.Errorf("failed to convert to KML: %v", err)
 		}
-		data, err = marshalGeoJSON(geojsonData, actualLayerName)
+		encode, fileExt = encodeString(data), "kml"
+	case "kmz":
+		kmz, err := export.ConvertGeoJSONToKMZWithOptions(layer.geoJSON, layer.name, kmlOptions(config, layer.info, &layer.metadata))
 		if err != nil {
-			return fmt.Errorf("failed to marshal GeoJSON: %v", err)
+			return nil, "", nil, fmt.Errorf("failed to convert to KMZ: %v", err)
 		}
-		fileExt = "geojson"
-	case "kml":
-		geojsonData, err := convert.ToGeoJSON(convertFeatures(features))
+		encode, fileExt = encodeBytes(kmz), "kmz"
+	case "gpx":
+		data, err := export.ConvertGeoJSONToGPXWithOptions(layer.geoJSON, layer.name, gpxOptions(config, &layer.metadata))
 		if err != nil {
-			return fmt.Errorf("failed to convert features to GeoJSON for KML: %v", err)
+			return nil, "", nil, fmt.Errorf("failed to convert to GPX: %v", err)
 		}
-		data, err = export.ConvertGeoJSONToKML(geojsonData, actualLayerName)
+		encode, fileExt = encodeString(data), "gpx"
+	case "topojson":
+		data, err := export.ConvertGeoJSONToTopoJSON(layer.geoJSON, layer.name)
 		if err != nil {
-			return fmt.Errorf("failed to convert to KML: %v", err)
+			return nil, "", nil, fmt.Errorf("failed to convert to TopoJSON: %v", err)
 		}
-		fileExt = "kml"
-	case "gpx":
-		geojsonData, err := convert.ToGeoJSON(convertFeatures(features))
+		encode, fileExt = encodeString(data), "topojson"
+	case "mbtiles":
+		mbtiles, err := export.ConvertGeoJSONToMBTiles(layer.geoJSON, layer.name, config.tileOptions)
 		if err != nil {
-			return fmt.Errorf("failed to convert features to GeoJSON for GPX: %v", err)
+			return nil, "", nil, fmt.Errorf("failed to convert to MBTiles: %v", err)
 		}
-		data, err = export.ConvertGeoJSONToGPX(geojsonData, actualLayerName)
+		encode, fileExt = encodeBytes(mbtiles), "mbtiles"
+	case "pmtiles":
+		pmtiles, err := export.ConvertGeoJSONToPMTiles(layer.geoJSON, layer.name, config.tileOptions)
 		if err != nil {
-			return fmt.Errorf("failed to convert to GPX: %v", err)
+			return nil, "", nil, fmt.Errorf("failed to convert to PMTiles: %v", err)
 		}
-		fileExt = "gpx"
-	case "json":
-		jsonDataBytes, err := json.MarshalIndent(convertFeatures(features), "", JSONIndent)
+		encode, fileExt = encodeBytes(pmtiles), "pmtiles"
+	case "gml":
+		schemaFile := filepath.Base(outputBase) + ".xsd"
+		gmlOptions := export.GMLOptions{Fields: layer.metadata.Fields, SchemaLocation: schemaFile}
+		if config.query.OutSR != 0 {
+			gmlOptions.SpatialReference = &arcgis.SpatialReference{WKID: config.query.OutSR}
+		}
+		data, err := export.ConvertGeoJSONToGML(layer.geoJSON, layer.name, gmlOptions)
 		if err != nil {
-			return fmt.Errorf("failed to marshal features to JSON: %v", err)
+			return nil, "", nil, fmt.Errorf("failed to convert to GML: %v", err)
 		}
-		data = string(jsonDataBytes)
-		fileExt = "json"
-	case "csv":
-		data, err = convert.FeaturesToCSV(convertFeatures(features))
+		sidecars[schemaFile], err = export.GenerateGMLSchema(layer.geoJSON, layer.name, gmlOptions)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("failed to generate GML schema: %v", err)
+		}
+		encode, fileExt = encodeString(data), "gml"
+	case "esrijson":
+		featureSet := export.BuildEsriFeatureSet(convertFeatures(layer.features), &layer.metadata)
+		if config.query.OutSR != 0 {
+			// Label the FeatureSet with the spatial reference the features were queried in.
+			featureSet.SpatialReference = &arcgis.SpatialReference{WKID: config.query.OutSR, LatestWKID: config.query.OutSR}
+		}
+		data, err := json.MarshalIndent(featureSet, "", JSONIndent)
 		if err != nil {
-			return fmt.Errorf("failed to convert features to CSV: %v", err)
+			return nil, "", nil, fmt.Errorf("failed to convert to Esri JSON: %v", err)
 		}
+		// Keep the .json extension expected by ArcGIS tools without clashing with the json format.
+		encode, fileExt = encodeBytes(data), "esri.json"
+	case "json":
+		encode = func(w io.Writer) error { return convert.WriteFeaturesJSON(w, layer.exportFeatures) }
+		fileExt = "json"
+	case "csv":
+		encode = func(w io.Writer) error { return convert.WriteCSV(w, layer.exportFeatures) }
 		fileExt = "csv"
 	case "txt":
-		data, err = convert.FeaturesToText(convertFeatures(features), actualLayerName)
-		if err != nil {
-			return fmt.Errorf("failed to convert features to text: %v", err)
+		if len(layer.exportFeatures) == 0 {
+			return nil, "", nil, fmt.Errorf("failed to convert features to text: no features to convert to text")
 		}
+		encode = func(w io.Writer) error { return convert.WriteText(w, layer.exportFeatures, layer.name) }
 		fileExt = "txt"
 	default:
-		return fmt.Errorf("unsupported format: %s", config.format)
+		return nil, "", nil, fmt.Errorf("unsupported format: %s", config.format)
 	}
 
-	safeFilenameBase := strings.ReplaceAll(actualLayerName, " ", "_")
-	safeFilenameBase = regexp.MustCompile(`[<>:"/\\|?*\s-]`).ReplaceAllString(safeFilenameBase, "")
-	if safeFilenameBase == "" {
-		safeFilenameBase = fmt.Sprintf(LayerNameFormat, layerInfo.ID)
+	return encode, fileExt, sidecars, nil
+}
+
+// writeLayer converts a prepared layer to the configured format and writes it, together
+// with its sidecar files, at the layer's output path. With a split field, it writes a
+// file per value of the field instead.
+func writeLayer(layer *preparedLayer, config layerProcessConfig) (layerReport, error) {
+	if config.splitBy != "" {
+		return writeSplitLayer(layer, config)
+	}
+	report := layer.report
+	outputBase := layer.outputBase(config)
+	encode, fileExt, sidecars, err := encodeLayer(layer, outputBase, config)
+	if err != nil {
+		return report, err
+	}
+	return writeOutput(report, outputBase, fileExt, encode, sidecars, config)
+}
+
+// writeOutput writes an encoded output and its sidecar files, honoring the overwrite and
+// skip-existing options, and completes the report with the output's path, size and checksum.
+// With -output -, the output is written to standard output and sidecar files are left out.
+func writeOutput(report layerReport, outputBase, fileExt string, encode layerEncoder, sidecars map[string]string, config layerProcessConfig) (layerReport, error) {
+	compressExt, err := compressionExtension(config.compress)
+	if err != nil {
+		return report, err
 	}
-	if config.prefix != "" {
-		safeFilenameBase = config.prefix + safeFilenameBase
+	if config.stdout != nil {
+		report.bytes, report.sha256, err = writeCompressed(config.stdout, encode, config.compress)
+		if err != nil {
+			return report, fmt.Errorf("failed to write to standard output: %v", err)
+		}
+		report.outputPath = OutputStdout
+		for name := range sidecars {
+			warning := fmt.Sprintf("%s not written when writing to standard output", name)
+			printWarning(fmt.Sprintf("  Warning: %s", warning))
+			report.warnings = append(report.warnings, warning)
+		}
+		return report, nil
 	}
 
-	filename := fmt.Sprintf("%s.%s", safeFilenameBase, fileExt)
-	outputPath := filepath.Join(config.outputDir, filename)
+	outputPath := fmt.Sprintf("%s.%s%s", outputBase, fileExt, compressExt)
+	outputDir := filepath.Dir(outputPath)
 
 	if _, err := os.Stat(outputPath); err == nil {
 		if config.skipExisting {
-			return fmt.Errorf("skipped existing file")
+			return report, errSkippedExisting
 		}
 		if !config.overwrite {
-			return fmt.Errorf("output file %s already exists. Use --overwrite or --skip-existing", outputPath)
+			return report, fmt.Errorf("output file %s already exists. Use --overwrite or --skip-existing", outputPath)
 		}
 		printWarning(fmt.Sprintf("  Overwriting existing file: %s", outputPath))
 	} else if !os.IsNotExist(err) {
-		return fmt.Errorf("failed to check output file status %s: %v", outputPath, err)
+		return report, fmt.Errorf("failed to check output file status %s: %v", outputPath, err)
+	}
+
+	if err := os.MkdirAll(outputDir, DirPerm); err != nil {
+		return report, fmt.Errorf("failed to create output directory %s: %v", outputDir, err)
 	}
 
-	if err := os.MkdirAll(config.outputDir, DirPerm); err != nil {
-		return fmt.Errorf("failed to create output directory %s: %v", config.outputDir, err)
+	file, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, FilePerm)
+	if err != nil {
+		return report, fmt.Errorf("failed to write output file %s: %v", outputPath, err)
+	}
+	// The output is encoded and compressed into the file as it is written.
+	report.bytes, report.sha256, err = writeCompressed(file, encode, config.compress)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(outputPath)
+		return report, fmt.Errorf("failed to write output file %s: %v", outputPath, err)
+	}
+	report.outputPath = outputPath
+	config.archive.add(outputPath)
+	// Sidecar files, such as GML schemas, are referenced by name and left uncompressed.
+	for name, content := range sidecars {
+		sidecarPath := filepath.Join(outputDir, name)
+		if err := os.WriteFile(sidecarPath, []byte(content), FilePerm); err != nil {
+			return report, fmt.Errorf("failed to write output file %s: %v", sidecarPath, err)
+		}
+		config.archive.add(sidecarPath)
+	}
+
+	return report, nil
+}
+
+// expandOutputTemplate returns the output path of a layer, without extension, from an
+// output template. The placeholders are replaced by:
+//   - {service}: the name of the layer's Feature or Map Service
+//   - {layerId}: the layer ID
+//   - {layerName}: the layer name, made safe for file names
+//   - {path}: the layer's Web Map group path, one directory per group
+//   - {date}: the date of the run, as YYYY-MM-DD
+//   - {format}: the output format
+//
+// Empty values, such as the path of a layer outside any group, collapse their separators.
+func expandOutputTemplate(template string, layerInfo arcgis.AvailableLayerInfo, safeName, format, date string) string {
+	groups := make([]string, 0, len(layerInfo.ParentPath))
+	for _, group := range layerInfo.ParentPath {
+		if group = safeFilename(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	if date == "" {
+		date = time.Now().Format(time.DateOnly)
 	}
+	replacer := strings.NewReplacer(
+		OutputPlaceholderService, safeFilename(serviceName(layerInfo.ServiceURL)),
+		OutputPlaceholderLayerID, layerInfo.ID,
+		OutputPlaceholderLayerName, safeName,
+		OutputPlaceholderPath, strings.Join(groups, PathSeparator),
+		OutputPlaceholderDate, date,
+		OutputPlaceholderFormat, strings.ToLower(format),
+	)
+	return filepath.Clean(replacer.Replace(template))
+}
 
-	if err := os.WriteFile(outputPath, []byte(data), FilePerm); err != nil {
-		return fmt.Errorf("failed to write output file %s: %v", outputPath, err)
+// serviceName returns the name of the Feature or Map Service of a service URL, such as
+// City for .../rest/services/Utilities/City/FeatureServer, or "" for other URLs.
+func serviceName(serviceURL string) string {
+	parts := strings.Split(strings.TrimSuffix(serviceURL, PathSeparator), PathSeparator)
+	for i := len(parts) - 1; i > 0; i-- {
+		if strings.EqualFold(parts[i], ServiceFeatureServer) || strings.EqualFold(parts[i], ServiceMapServer) {
+			return parts[i-1]
+		}
 	}
+	return ""
+}
+
+// safeFilename makes a name safe to use as a file name: spaces become underscores and
+// characters that are reserved on common file systems are removed.
+func safeFilename(name string) string {
+	name = strings.ReplaceAll(name, " ", "_")
+	return regexp.MustCompile(`[<>:"/\\|?*\s-]`).ReplaceAllString(name, "")
+}
 
+// validateOutputTemplate checks that an output template is not empty and only uses
+// known placeholders.
+func validateOutputTemplate(template string) error {
+	known := map[string]bool{
+		OutputPlaceholderService:   true,
+		OutputPlaceholderLayerID:   true,
+		OutputPlaceholderLayerName: true,
+		OutputPlaceholderPath:      true,
+		OutputPlaceholderDate:      true,
+		OutputPlaceholderFormat:    true,
+	}
+	for _, placeholder := range regexp.MustCompile(`\{[^{}]*\}`).FindAllString(template, -1) {
+		if !known[placeholder] {
+			return fmt.Errorf("unknown placeholder %s in output template %s", placeholder, template)
+		}
+	}
+	if strings.TrimSpace(template) == "" || strings.HasSuffix(template, "/") {
+		return fmt.Errorf("output template %q does not name a file", template)
+	}
 	return nil
 }
 
-// marshalGeoJSON marshals a GeoJSON struct into a JSON string.
-func marshalGeoJSON(geoJSON *convert.GeoJSON, layerName string) (string, error) {
-	data, err := json.MarshalIndent(geoJSON, "", "  ")
-	if err != nil {
-		return "", err
+// layerTimeFields returns the layer's date fields and the fields giving each feature's time:
+// the start and end fields of a time-aware layer, or otherwise the first date field.
+func layerTimeFields(layer *arcgis.Layer) (dateFields []string, startField, endField string) {
+	for _, field := range layer.Fields {
+		if field.Type == export.EsriFieldTypeDate {
+			dateFields = append(dateFields, field.Name)
+		}
+	}
+	if layer.TimeInfo != nil && layer.TimeInfo.StartTimeField != "" {
+		return dateFields, layer.TimeInfo.StartTimeField, layer.TimeInfo.EndTimeField
+	}
+	if len(dateFields) > 0 {
+		return dateFields, dateFields[0], ""
+	}
+	return dateFields, "", ""
+}
+
+// kmlOptions returns the KML options for a layer: its field schema, time fields, labels,
+// the balloon template, and placemark folders as selected by -kml-folder-by, which groups
+// by renderer class label, by the layer's Web Map group path, or by an attribute value.
+// An attribute named like a keyword can be selected with the "field:" prefix.
+func kmlOptions(config layerProcessConfig, layerInfo arcgis.AvailableLayerInfo, layer *arcgis.Layer) export.KMLOptions {
+	opts := export.KMLOptions{Fields: layer.Fields, BalloonTemplate: config.kmlBalloon}
+	_, opts.TimeField, opts.EndTimeField = layerTimeFields(layer)
+	if layer.DrawingInfo != nil {
+		if labelClass := arcgis.DefaultLabelClass(layer.DrawingInfo.LabelingInfo); labelClass != nil {
+			if _, ok := labelClass.LabelFor(map[string]interface{}{}, nil); ok {
+				opts.NameFor = func(feature *convert.GeoJSONFeature) string {
+					label, _ := labelClass.LabelFor(feature.Properties, layer.Fields)
+					return strings.TrimSpace(label)
+				}
+			} else {
+				printWarning(fmt.Sprintf("  Warning: Unsupported label expression %s, naming placemarks from name fields.", labelClass))
+			}
+			if symbol := labelClass.Symbol; symbol != nil && !config.excludeSymbols {
+				opts.LabelColor = symbol.Color
+				if symbol.Font != nil {
+					opts.LabelSize = symbol.Font.Size
+				}
+			}
+		}
+	}
+	switch folderBy := config.kmlFolderBy; folderBy {
+	case "":
+	case KMLFolderByPath:
+		opts.FolderPath = layerInfo.ParentPath
+	case KMLFolderByClass:
+		if layer.DrawingInfo == nil || layer.DrawingInfo.Renderer == nil {
+			printWarning(fmt.Sprintf("  Warning: Layer %s has no renderer, placemarks are not grouped by class.", layer.Name))
+			break
+		}
+		renderer := layer.DrawingInfo.Renderer
+		opts.FolderFor = func(feature *convert.GeoJSONFeature) string {
+			return renderer.ClassLabelFor(feature.Properties)
+		}
+	default:
+		opts.FolderFor = attributeClassifier(folderBy)
+	}
+	return opts
+}
+
+// gpxOptions returns the GPX options for a layer: the flag options with its time fields,
+// and waypoint symbols as selected by -gpx-sym, like KML folders by class or attribute.
+func gpxOptions(config layerProcessConfig, layer *arcgis.Layer) export.GPXOptions {
+	opts := config.gpxOptions
+	_, opts.TimeField, opts.EndTimeField = layerTimeFields(layer)
+	switch symBy := config.gpxSymBy; symBy {
+	case "":
+	case KMLFolderByClass:
+		if layer.DrawingInfo == nil || layer.DrawingInfo.Renderer == nil {
+			printWarning(fmt.Sprintf("  Warning: Layer %s has no renderer, waypoints have no symbols.", layer.Name))
+			break
+		}
+		renderer := layer.DrawingInfo.Renderer
+		opts.SymFor = func(feature *convert.GeoJSONFeature) string {
+			return renderer.ClassLabelFor(feature.Properties)
+		}
+	default:
+		opts.SymFor = attributeClassifier(symBy)
+	}
+	return opts
+}
+
+// attributeClassifier returns a function giving a feature's value of the named attribute
+// as text, with "<Null>" for null values. An attribute named like a keyword can be selected
+// with the "field:" prefix.
+func attributeClassifier(name string) func(feature *convert.GeoJSONFeature) string {
+	field := strings.TrimPrefix(name, KMLFolderFieldPrefix)
+	return func(feature *convert.GeoJSONFeature) string {
+		switch value := feature.Properties[field].(type) {
+		case nil:
+			return KMLFolderNull
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		default:
+			return fmt.Sprintf("%v", value)
+		}
 	}
-	return string(data), nil
 }
 
 // convertToConvertFeature converts a local Feature to a convert.Feature
@@ -930,6 +1825,39 @@ func convertFeatures(features []arcgis.Feature) []convert.Feature {
 	return convertFeatures
 }
 
+// saveRendererSymbol returns a copy of a renderer symbol. When saving symbols, the symbol
+// is written to the symbols directory and the copy's URL points at the saved file.
+func saveRendererSymbol(symbol *arcgis.Symbol, symbolsDir, relativeSymbolsDir, name string, save bool) *arcgis.Symbol {
+	if symbol == nil {
+		return nil
+	}
+	symbolCopy := *symbol
+	if !save {
+		return &symbolCopy
+	}
+	if err := saveSymbol(&symbolCopy, symbolsDir, name); err != nil {
+		printWarning(fmt.Sprintf("  Warning: Failed to save symbol %s: %v", name, err))
+		return &symbolCopy
+	}
+	if symbolCopy.ImageData == "" {
+		// Only picture symbols are saved as image files.
+		return &symbolCopy
+	}
+	// Update URL to relative path, using forward slashes for KML
+	ext := getSymbolFileExtension(&symbolCopy)
+	symbolCopy.URL = filepath.ToSlash(filepath.Join(relativeSymbolsDir, name+ext))
+	return &symbolCopy
+}
+
+// classSymbolName returns the file name base for a renderer class symbol, derived from its label.
+func classSymbolName(label string, index int) string {
+	safeLabel := regexp.MustCompile(`[<>:"/\|?*\s]`).ReplaceAllString(label, "_")
+	if safeLabel == "" {
+		safeLabel = fmt.Sprintf("class_%d", index) // Fallback name
+	}
+	return fmt.Sprintf("class_%s", safeLabel)
+}
+
 // saveSymbol saves a symbol to the specified directory
 func saveSymbol(symbol *arcgis.Symbol, dir, name string) error {
 	if symbol == nil {
@@ -974,6 +1902,10 @@ func saveSymbol(symbol *arcgis.Symbol, dir, name string) error {
 		"xoffset":     symbol.XOffset,
 		"yoffset":     symbol.YOffset,
 		"angle":       symbol.Angle,
+		"color":       symbol.Color,
+		"size":        symbol.Size,
+		"style":       symbol.Style,
+		"outline":     symbol.Outline,
 	}
 
 	metadataPath := filepath.Join(dir, name+".json")
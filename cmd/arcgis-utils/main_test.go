@@ -1,18 +1,28 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/Sudo-Ivan/arcgis-utils/pkg/arcgis"
 	"github.com/Sudo-Ivan/arcgis-utils/pkg/convert"
 	"github.com/Sudo-Ivan/arcgis-utils/pkg/export"
+	"github.com/klauspost/compress/zstd"
 )
 
 func TestMain(m *testing.M) {
@@ -157,7 +167,7 @@ func TestProcessSelectedLayer(t *testing.T) {
 	defer os.RemoveAll(tempDir)
 
 	// Test cases for different formats
-	formats := []string{"geojson", "kml", "gpx", "csv", "json", "txt"}
+	formats := []string{"geojson", "topojson", "kml", "kmz", "gml", "gpx", "mbtiles", "pmtiles", "csv", "json", "esrijson", "txt"}
 
 	for _, format := range formats {
 		t.Run(format, func(t *testing.T) {
@@ -185,7 +195,11 @@ func TestProcessSelectedLayer(t *testing.T) {
 			}
 
 			// Check if output file was created
-			expectedFile := filepath.Join(tempDir, "test_Test_Layer."+format)
+			ext := format
+			if format == "esrijson" {
+				ext = "esri.json"
+			}
+			expectedFile := filepath.Join(tempDir, "test_Test_Layer."+ext)
 			if _, err := os.Stat(expectedFile); os.IsNotExist(err) {
 				t.Errorf("Output file %s was not created", expectedFile)
 			}
@@ -349,3 +363,910 @@ func TestPrintFunctions(t *testing.T) {
 		})
 	}
 }
+
+func TestReadLocalLayer(t *testing.T) {
+	dir := t.TempDir()
+	geoJSONPath := filepath.Join(dir, "parks.geojson")
+	os.WriteFile(geoJSONPath, []byte(`{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "properties": {"OBJECTID": 2, "TYPE": "b"}, "geometry": {"type": "Point", "coordinates": [1, 2]}},
+		{"type": "Feature", "properties": {"OBJECTID": 1, "TYPE": "a"}, "geometry": {"type": "LineString", "coordinates": [[0, 0], [1, 1]]}}
+	]}`), FilePerm)
+	esriPath := filepath.Join(dir, "parks.esri.json")
+	os.WriteFile(esriPath, []byte(`{"objectIdFieldName": "OBJECTID", "geometryType": "esriGeometryPoint",
+		"fields": [{"name": "OBJECTID", "type": "esriFieldTypeOID"}, {"name": "TYPE", "type": "esriFieldTypeString"}],
+		"features": [{"attributes": {"OBJECTID": 1, "TYPE": "a"}, "geometry": {"x": 1, "y": 2}}]}`), FilePerm)
+	metadataPath := filepath.Join(dir, "layer.json")
+	os.WriteFile(metadataPath, []byte(`{"id": 3, "name": "City Parks", "drawingInfo": {"renderer": {"type": "uniqueValue", "field1": "TYPE",
+		"uniqueValueInfos": [{"value": "a", "label": "Type A", "symbol": {"type": "esriSMS", "style": "esriSMSCircle", "color": [255, 0, 0, 255], "size": 8}}]}}}`), FilePerm)
+
+	layerInfo, layer, features, err := readLocalLayer(geoJSONPath, "", "kml")
+	if err != nil {
+		t.Fatalf("readLocalLayer failed for GeoJSON: %v", err)
+	}
+	if layerInfo.Name != "parks" || len(features) != 2 || features[1].Geometry.(map[string]interface{})["paths"] == nil {
+		t.Errorf("Unexpected GeoJSON layer %+v with features %+v", layerInfo, features)
+	}
+
+	layerInfo, layer, features, err = readLocalLayer(esriPath, metadataPath, "kml")
+	if err != nil {
+		t.Fatalf("readLocalLayer failed for Esri JSON: %v", err)
+	}
+	if layer.Name != "City Parks" || layerInfo.ID != "3" || len(layer.Fields) != 2 || layer.ObjectIDField != "OBJECTID" {
+		t.Errorf("Expected metadata merged with the file schema, got %+v", layer)
+	}
+
+	config := layerProcessConfig{format: "kml", outputDir: dir, overwrite: true}
+	if _, err := exportLayer(layerInfo, layer, features, config); err != nil {
+		t.Fatalf("exportLayer failed: %v", err)
+	}
+	kml, err := os.ReadFile(filepath.Join(dir, "City_Parks.kml"))
+	if err != nil {
+		t.Fatalf("Expected output named after the metadata layer name: %v", err)
+	}
+	if !strings.Contains(string(kml), "<styleUrl>#style_esriSMS_") {
+		t.Error("Expected the metadata renderer's symbol in the KML")
+	}
+
+	if _, _, _, err := readLocalLayer(metadataPath, "", "kml"); err == nil {
+		t.Error("Expected an error for a file that is neither GeoJSON nor Esri JSON")
+	}
+
+	// Projected Esri JSON is only written to formats that do not assume WGS84.
+	projectedPath := filepath.Join(dir, "projected.json")
+	os.WriteFile(projectedPath, []byte(`{"geometryType": "esriGeometryPoint", "spatialReference": {"wkid": 102100, "latestWkid": 3857},
+		"features": [{"attributes": {"OBJECTID": 1}, "geometry": {"x": -13580000, "y": 4540000}}]}`), FilePerm)
+	for _, format := range []string{"geojson", "kml", "gpx", "mbtiles", "pmtiles"} {
+		if _, _, _, err := readLocalLayer(projectedPath, "", format); err == nil || !strings.Contains(err.Error(), "3857") {
+			t.Errorf("Expected projected input to be rejected for %s, got %v", format, err)
+		}
+	}
+	if _, _, features, err := readLocalLayer(projectedPath, "", "gml"); err != nil || len(features) != 1 {
+		t.Errorf("Expected projected input to be read for gml, got %d feature(s), %v", len(features), err)
+	}
+}
+
+func TestWriteLayerList(t *testing.T) {
+	layers := []arcgis.AvailableLayerInfo{
+		{ID: "0", Name: "Hydrants", Type: "Feature Layer", GeometryType: "esriGeometryPoint", ServiceURL: "https://example.com/FeatureServer", ParentPath: []string{"Utilities", "Water"}},
+		{ID: "4", Name: "Parcels", ServiceURL: "https://example.com/MapServer"},
+	}
+
+	var table strings.Builder
+	if err := writeLayerList(&table, layers, false); err != nil {
+		t.Fatalf("writeLayerList failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "ID  NAME") || !strings.Contains(lines[1], "Utilities > Water") {
+		t.Errorf("Unexpected table:\n%s", table.String())
+	}
+
+	var out strings.Builder
+	if err := writeLayerList(&out, layers, true); err != nil {
+		t.Fatalf("writeLayerList failed: %v", err)
+	}
+	var entries []layerListEntry
+	if err := json.Unmarshal([]byte(out.String()), &entries); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(entries) != 2 || entries[0].URL != "https://example.com/FeatureServer/0" || len(entries[0].Path) != 2 {
+		t.Errorf("Unexpected entries %+v", entries)
+	}
+}
+
+func TestWriteLayerInfo(t *testing.T) {
+	count := 42
+	entry := layerInfoEntry{
+		URL:         "https://example.com/FeatureServer/0",
+		RecordCount: &count,
+		Metadata: arcgis.Layer{
+			Name:         "Hydrants",
+			GeometryType: "esriGeometryPoint",
+			Extent:       &arcgis.Extent{XMin: -123.5, YMin: 37, XMax: -122, YMax: 38.25, SpatialReference: &arcgis.SpatialReference{WKID: 4326}},
+			Fields: []arcgis.Field{
+				{Name: "OBJECTID", Type: "esriFieldTypeOID"},
+				{Name: "STATUS", Type: "esriFieldTypeSmallInteger", Domain: &arcgis.Domain{CodedValues: []arcgis.CodedValue{{Name: "Active", Code: 1.0}}}},
+			},
+			DrawingInfo: &arcgis.DrawingInfo{Renderer: &arcgis.Renderer{
+				Type:             "uniqueValue",
+				Field1:           "STATUS",
+				UniqueValueInfos: []arcgis.UniqueValueInfo{{Value: "1", Label: "Active"}},
+				DefaultLabel:     "Other",
+			}},
+		},
+	}
+	var out strings.Builder
+	writeLayerInfo(&out, entry)
+	for _, want := range []string{
+		"Layer: Hydrants\n",
+		"Records: 42\n",
+		"Extent: -123.5, 37, -122, 38.25 (WKID 4326)\n",
+		"Object ID Field: OBJECTID\n",
+		"1 coded values",
+		"Renderer: uniqueValue (STATUS)\n  Active\n  Other (default)\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected info to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestLayerSelector(t *testing.T) {
+	layers := []arcgis.AvailableLayerInfo{
+		{ID: "0", Name: "Hydrants", GeometryType: "esriGeometryPoint", ParentPath: []string{"Utilities", "Water"}},
+		{ID: "3", Name: "Mains", GeometryType: "esriGeometryPolyline", ParentPath: []string{"Utilities", "Water"}},
+		{ID: "3", Name: "Sewer Lines", GeometryType: "esriGeometryPolyline", ParentPath: []string{"Utilities", "Sewer"}},
+		{ID: "7", Name: "Parcels", GeometryType: "esriGeometryPolygon"},
+		{ID: "8", Name: "Trees", GeometryType: "esriGeometryMultipoint"},
+	}
+
+	tests := []struct {
+		name          string
+		ids           string
+		namePattern   string
+		geometryTypes string
+		want          []string
+	}{
+		{"ids", "0, 7", "", "", []string{"Hydrants", "Parcels"}},
+		{"name", "", "^(?i)parcels$", "", []string{"Parcels"}},
+		{"group path", "", "^Utilities > Water > ", "", []string{"Hydrants", "Mains"}},
+		{"geometry type", "", "", "point", []string{"Hydrants", "Trees"}},
+		{"combined", "3", "Utilities", "polyline,polygon", []string{"Mains", "Sewer Lines"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, err := newLayerSelector(tt.ids, tt.namePattern, tt.geometryTypes)
+			if err != nil {
+				t.Fatalf("newLayerSelector failed: %v", err)
+			}
+			selected, err := selector.filter(layers)
+			if err != nil {
+				t.Fatalf("filter failed: %v", err)
+			}
+			var names []string
+			for _, layer := range selected {
+				names = append(names, layer.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Selected %v, want %v", names, tt.want)
+			}
+		})
+	}
+
+	if selector, _ := newLayerSelector("", "", ""); selector.active() {
+		t.Error("Expected an empty selector to be inactive")
+	}
+	selector, _ := newLayerSelector("42", "", "polygon")
+	if _, err := selector.filter(layers); err == nil || !strings.Contains(err.Error(), "layers 42, geometry-type polygon") {
+		t.Errorf("Expected a no-match error naming the selectors, got %v", err)
+	}
+	for _, args := range [][3]string{{"a", "", ""}, {"", "(", ""}, {"", "", "line"}} {
+		if _, err := newLayerSelector(args[0], args[1], args[2]); err == nil {
+			t.Errorf("Expected an error for selectors %q", args)
+		}
+	}
+}
+
+// newFeatureServer starts a mock Feature Server with a point layer (0) and a polygon
+// layer (1), plus an unlisted layer (2) whose query exceeds the transfer limit and
+// returns a feature without geometry. Every feature query is sent to queries.
+func newFeatureServer(t *testing.T, queries chan<- map[string]string) *httptest.Server {
+	t.Helper()
+	layers := map[string]arcgis.Layer{
+		"0": {Name: "Hydrants", Type: "Feature Layer", GeometryType: "esriGeometryPoint", ObjectIDField: "OBJECTID"},
+		"1": {Name: "Parks", Type: "Feature Layer", GeometryType: "esriGeometryPolygon", ObjectIDField: "OBJECTID"},
+		"2": {Name: "Meters", Type: "Feature Layer", GeometryType: "esriGeometryPoint", ObjectIDField: "OBJECTID"},
+	}
+	features := map[string][]arcgis.Feature{
+		"0": {
+			{Attributes: map[string]interface{}{"OBJECTID": 1, "NAME": "H1", "STATUS": "Open"}, Geometry: map[string]interface{}{"x": -122.0, "y": 37.0}},
+			{Attributes: map[string]interface{}{"OBJECTID": 2, "NAME": "H2", "STATUS": "Closed"}, Geometry: map[string]interface{}{"x": -122.1, "y": 37.1}},
+			{Attributes: map[string]interface{}{"OBJECTID": 3, "NAME": "H3", "STATUS": "Open"}, Geometry: map[string]interface{}{"x": -122.2, "y": 37.2}},
+		},
+		"1": {
+			{Attributes: map[string]interface{}{"OBJECTID": 1, "NAME": "P1", "STATUS": "Open"}, Geometry: map[string]interface{}{
+				"rings": []interface{}{[]interface{}{[]interface{}{0.0, 0.0}, []interface{}{0.0, 1.0}, []interface{}{1.0, 1.0}, []interface{}{0.0, 0.0}}},
+			}},
+		},
+		"2": {
+			{Attributes: map[string]interface{}{"OBJECTID": 1, "NAME": "M1"}, Geometry: map[string]interface{}{"x": -122.0, "y": 37.0}},
+			{Attributes: map[string]interface{}{"OBJECTID": 2, "NAME": "M2"}},
+		},
+	}
+
+	// Requests are matched case-insensitively and without a trailing slash, as
+	// NormalizeArcGISURL rewrites service URLs.
+	mux := http.NewServeMux()
+	mux.HandleFunc("/arcgis/rest/services/City/FeatureServer", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"layers": [
+			{"id": 0, "name": "Hydrants", "type": "Feature Layer", "geometryType": "esriGeometryPoint"},
+			{"id": 1, "name": "Parks", "type": "Feature Layer", "geometryType": "esriGeometryPolygon"}
+		]}`))
+	})
+	mux.HandleFunc("/arcgis/rest/services/City/FeatureServer/", func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, "/arcgis/rest/services/City/FeatureServer/")
+		id, query := strings.CutSuffix(rest, "/query")
+		if _, ok := layers[id]; !ok {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		if !query {
+			json.NewEncoder(w).Encode(layers[id])
+			return
+		}
+		params := map[string]string{"layer": id}
+		for key := range r.URL.Query() {
+			params[key] = r.URL.Query().Get(key)
+		}
+		if queries != nil {
+			queries <- params
+		}
+		response := arcgis.FeatureResponse{ExceededTransferLimit: id == "2"}
+		for _, feature := range features[id] {
+			// Only the where clause used by the tests is understood.
+			if params["where"] == "STATUS = 'Open'" && feature.Attributes["STATUS"] != "Open" {
+				continue
+			}
+			response.Features = append(response.Features, feature)
+		}
+		json.NewEncoder(w).Encode(response)
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Path = strings.TrimSuffix(strings.Replace(r.URL.Path, "/ArcGIS/", "/arcgis/", 1), "/")
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestLoadJob(t *testing.T) {
+	dir := t.TempDir()
+	yamlJob := filepath.Join(dir, "job.yaml")
+	os.WriteFile(yamlJob, []byte(`output: exports
+format: kml
+timeout: 60
+sources:
+  - url: https://example.com/arcgis/rest/services/City/FeatureServer
+    layers: [0, 3]
+    where: STATUS = 'Open'
+    fields: [NAME, STATUS]
+  - url: https://example.com/arcgis/rest/services/Parcels/MapServer
+    layers: "7"
+    layer-name: ^Parcels$
+    geometry-type: polygon
+    format: gml
+    output-path: parcels/{layerName}
+    crs: EPSG:3857
+`), FilePerm)
+	jsonJob := filepath.Join(dir, "job.json")
+	os.WriteFile(jsonJob, []byte(`{"sources": [{"url": "https://example.com/FeatureServer/0", "layers": [0, "3"], "crs": 2263}]}`), FilePerm)
+
+	job, err := loadJob(yamlJob)
+	if err != nil {
+		t.Fatalf("loadJob(yaml) failed: %v", err)
+	}
+	if job.Output != "exports" || job.Format != "kml" || job.Timeout != 60 || len(job.Sources) != 2 {
+		t.Fatalf("Unexpected job %+v", job)
+	}
+	first, second := job.Sources[0], job.Sources[1]
+	if strings.Join(first.Layers, ",") != "0,3" || first.Where != "STATUS = 'Open'" || strings.Join(first.Fields, ",") != "NAME,STATUS" {
+		t.Errorf("Unexpected first source %+v", first)
+	}
+	if strings.Join(second.Layers, ",") != "7" || second.LayerName != "^Parcels$" || second.GeometryType != "polygon" ||
+		second.Format != "gml" || second.OutputPath != "parcels/{layerName}" || second.CRS != 3857 {
+		t.Errorf("Unexpected second source %+v", second)
+	}
+
+	job, err = loadJob(jsonJob)
+	if err != nil {
+		t.Fatalf("loadJob(json) failed: %v", err)
+	}
+	if source := job.Sources[0]; strings.Join(source.Layers, ",") != "0,3" || source.CRS != 2263 {
+		t.Errorf("Unexpected JSON source %+v", source)
+	}
+
+	for name, content := range map[string]string{
+		"empty.yaml":   "output: exports\n",
+		"crs.yaml":     "sources:\n  - url: https://example.com/FeatureServer\n    crs: web mercator\n",
+		"invalid.json": `{"sources": [`,
+	} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), FilePerm)
+		if _, err := loadJob(path); err == nil {
+			t.Errorf("Expected loadJob to fail for %s", name)
+		}
+	}
+}
+
+func TestJobSourceConfig(t *testing.T) {
+	defaults := layerProcessConfig{format: "geojson", outputDir: "exports"}
+
+	config, selector, err := jobSource{Layers: jobLayerIDs{"0"}, Format: "gml", OutputPath: "{format}/{layerName}", CRS: 3857, Where: "A = 1"}.config(defaults)
+	if err != nil {
+		t.Fatalf("config failed: %v", err)
+	}
+	if config.format != "gml" || config.outputTemplate != "{format}/{layerName}" || config.query.OutSR != 3857 || config.query.Where != "A = 1" || !selector.active() {
+		t.Errorf("Unexpected source config %+v", config)
+	}
+	if defaults.format != "geojson" {
+		t.Error("Source config changed the job defaults")
+	}
+
+	for _, source := range []jobSource{
+		{CRS: 3857},
+		{OutputPath: "{region}/{layerName}"},
+		{OutputPath: "exports/"},
+		{GeometryType: "line"},
+	} {
+		if _, _, err := source.config(defaults); err == nil {
+			t.Errorf("Expected an error for source %+v", source)
+		}
+	}
+}
+
+func TestRunSource(t *testing.T) {
+	queries := make(chan map[string]string, 10)
+	server := newFeatureServer(t, queries)
+	dir := t.TempDir()
+
+	source := jobSource{Layers: jobLayerIDs{"0"}, Where: "STATUS = 'Open'", Fields: []string{"NAME", "STATUS"}, Format: "esrijson", OutputPath: "{format}/layer_{layerId}", CRS: 3857}
+	config, selector, err := source.config(layerProcessConfig{format: "geojson", outputDir: dir})
+	if err != nil {
+		t.Fatalf("config failed: %v", err)
+	}
+	result := runSource(arcgis.NewClient(5*time.Second), server.URL+"/arcgis/rest/services/City/FeatureServer", selector, config)
+	if result.err != nil || result.counts.success.Load() != 1 || result.counts.errors.Load() != 0 {
+		t.Fatalf("Unexpected source result %+v", result)
+	}
+
+	query := <-queries
+	if query["layer"] != "0" || query["where"] != "STATUS = 'Open'" || query["outFields"] != "NAME,STATUS" || query["outSR"] != "3857" {
+		t.Errorf("Unexpected feature query %v", query)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "esrijson", "layer_0.esri.json"))
+	if err != nil {
+		t.Fatalf("Output not written at the templated path: %v", err)
+	}
+	var featureSet export.EsriFeatureSet
+	if err := json.Unmarshal(data, &featureSet); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+	if len(featureSet.Features) != 2 || featureSet.SpatialReference.WKID != 3857 {
+		t.Errorf("Expected 2 features in WKID 3857, got %d in %+v", len(featureSet.Features), featureSet.SpatialReference)
+	}
+
+	selector, _ = newLayerSelector("9", "", "")
+	if result := runSource(arcgis.NewClient(5*time.Second), server.URL+"/arcgis/rest/services/City/FeatureServer", selector, config); result.err == nil {
+		t.Error("Expected an error when no layer matches the selection")
+	}
+}
+
+func TestReadURLList(t *testing.T) {
+	urls, err := readURLList(strings.NewReader(`# City services
+https://example.com/arcgis/rest/services/City/FeatureServer
+
+  https://example.com/arcgis/rest/services/Parcels/MapServer/7   # parcels only
+https://www.arcgis.com/home/item.html?id=abc#overview
+	# indented comment
+`))
+	if err != nil {
+		t.Fatalf("readURLList failed: %v", err)
+	}
+	want := []string{
+		"https://example.com/arcgis/rest/services/City/FeatureServer",
+		"https://example.com/arcgis/rest/services/Parcels/MapServer/7",
+		"https://www.arcgis.com/home/item.html?id=abc#overview",
+	}
+	if strings.Join(urls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("readURLList() = %q, want %q", urls, want)
+	}
+}
+
+func TestRunURLList(t *testing.T) {
+	server := newFeatureServer(t, nil)
+	dir := t.TempDir()
+	listPath := filepath.Join(dir, "urls.txt")
+	serviceURL := server.URL + "/arcgis/rest/services/City/FeatureServer"
+	os.WriteFile(listPath, []byte("# harvest\n"+serviceURL+"\n"+serviceURL+"/1 # parks\n"), FilePerm)
+
+	selector, _ := newLayerSelector("", "", "polygon")
+	runURLList(arcgis.NewClient(5*time.Second), listPath, selector, layerProcessConfig{format: "csv", outputDir: dir, overwrite: true})
+
+	if _, err := os.Stat(filepath.Join(dir, "Parks.csv")); err != nil {
+		t.Errorf("Expected the polygon layer to be exported: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Hydrants.csv")); err == nil {
+		t.Error("Expected the point layer to be filtered out")
+	}
+}
+
+func TestRunManifest(t *testing.T) {
+	server := newFeatureServer(t, nil)
+	serviceURL := server.URL + "/arcgis/rest/services/City/FeatureServer"
+	dir := t.TempDir()
+	client := arcgis.NewClient(5 * time.Second)
+	config := layerProcessConfig{format: "geojson", outputDir: dir, skipExisting: true, manifest: &runManifest{path: ManifestFileName}}
+
+	for _, id := range []string{"0", "2", "9"} {
+		processSelectedLayer(client, arcgis.AvailableLayerInfo{ID: id, Name: "Layer_" + id, ServiceURL: serviceURL}, config)
+	}
+	// A second run of layer 0 skips the existing file.
+	processSelectedLayer(client, arcgis.AvailableLayerInfo{ID: "0", ServiceURL: serviceURL}, config)
+	writeManifest(config)
+
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFileName))
+	if err != nil {
+		t.Fatalf("Manifest not written: %v", err)
+	}
+	var manifest struct {
+		Succeeded, Skipped, Failed int
+		Layers                     []manifestEntry
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Failed to parse manifest: %v", err)
+	}
+	if manifest.Succeeded != 2 || manifest.Skipped != 1 || manifest.Failed != 1 || len(manifest.Layers) != 4 {
+		t.Fatalf("Unexpected manifest totals: %s", data)
+	}
+
+	hydrants := manifest.Layers[0]
+	if hydrants.Status == StatusSkipped {
+		hydrants = manifest.Layers[1]
+	}
+	output, err := os.ReadFile(hydrants.OutputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output %s: %v", hydrants.OutputPath, err)
+	}
+	checksum := sha256.Sum256(output)
+	if hydrants.SourceURL != serviceURL || hydrants.LayerID != "0" || hydrants.Name != "Hydrants" || hydrants.Format != "geojson" ||
+		hydrants.FeatureCount != 3 || hydrants.Bytes != int64(len(output)) || hydrants.SHA256 != hex.EncodeToString(checksum[:]) {
+		t.Errorf("Unexpected entry %+v", hydrants)
+	}
+
+	meters, missing := manifest.Layers[2], manifest.Layers[3]
+	if meters.Status != StatusSuccess || meters.FeatureCount != 1 || len(meters.Warnings) != 2 ||
+		!strings.Contains(meters.Warnings[0], "transfer limit") || !strings.Contains(meters.Warnings[1], "1 feature(s) without a supported geometry") {
+		t.Errorf("Unexpected entry %+v", meters)
+	}
+	if missing.Status != StatusFailed || missing.LayerID != "9" || missing.Error == "" || missing.OutputPath != "" {
+		t.Errorf("Unexpected entry %+v", missing)
+	}
+
+	// Skips are recognized by their sentinel errors, not their wording.
+	if status := layerStatus(fmt.Errorf("layer 3: %w", errNoFeatures)); status != StatusSkipped {
+		t.Errorf("layerStatus(wrapped errNoFeatures) = %s, want %s", status, StatusSkipped)
+	}
+	if status := layerStatus(errors.New("skipped existing file")); status != StatusFailed {
+		t.Errorf("layerStatus(lookalike error) = %s, want %s", status, StatusFailed)
+	}
+}
+
+func TestExpandOutputTemplate(t *testing.T) {
+	layer := arcgis.AvailableLayerInfo{
+		ID:         "3",
+		Name:       "Water Mains",
+		ServiceURL: "https://example.com/arcgis/rest/services/Utilities/City Water/FeatureServer",
+		ParentPath: []string{"Public Works", "Water: Distribution"},
+	}
+	tests := []struct {
+		template string
+		layer    arcgis.AvailableLayerInfo
+		want     string
+	}{
+		{"{service}/{layerId}_{layerName}", layer, "City_Water/3_Water_Mains"},
+		{"{path}/{layerName}", layer, "Public_Works/Water_Distribution/Water_Mains"},
+		{"{date}/{format}/{layerName}", layer, "2025-06-01/kml/Water_Mains"},
+		{"{service}/{path}/{layerName}", arcgis.AvailableLayerInfo{ID: "0", ServiceURL: "https://example.com/Parcels/MapServer"}, "Parcels/Layer_0"},
+		{"{service}_{layerId}", arcgis.AvailableLayerInfo{ID: "0"}, "_0"},
+	}
+	for _, tt := range tests {
+		safeName := safeFilename(tt.layer.Name)
+		if safeName == "" {
+			safeName = "Layer_" + tt.layer.ID
+		}
+		if got := expandOutputTemplate(tt.template, tt.layer, safeName, "KML", "2025-06-01"); got != filepath.FromSlash(tt.want) {
+			t.Errorf("expandOutputTemplate(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+
+	// Layers with the same name in different services no longer collide.
+	dir := t.TempDir()
+	config := layerProcessConfig{format: "csv", outputDir: dir, prefix: "x_", outputTemplate: "{service}/{layerName}"}
+	features := []arcgis.Feature{{Attributes: map[string]interface{}{"NAME": "A"}}}
+	for _, service := range []string{"North", "South"} {
+		layerInfo := arcgis.AvailableLayerInfo{ID: "0", Name: "Parks", ServiceURL: "https://example.com/" + service + "/FeatureServer"}
+		report, err := exportLayer(layerInfo, arcgis.Layer{}, features, config)
+		if err != nil {
+			t.Fatalf("exportLayer failed: %v", err)
+		}
+		if want := filepath.Join(dir, service, "x_Parks.csv"); report.outputPath != want {
+			t.Errorf("Output written to %s, want %s", report.outputPath, want)
+		}
+	}
+
+	for _, template := range []string{"{layerName}", "{service}/{path}/{layerName}_{date}.{format}"} {
+		if err := validateOutputTemplate(template); err != nil {
+			t.Errorf("validateOutputTemplate(%q) failed: %v", template, err)
+		}
+	}
+	for _, template := range []string{"", "{layer}", "{service}/"} {
+		if err := validateOutputTemplate(template); err == nil {
+			t.Errorf("Expected validateOutputTemplate(%q) to fail", template)
+		}
+	}
+}
+
+func TestMergeLayers(t *testing.T) {
+	server := newFeatureServer(t, nil)
+	serviceURL := server.URL + "/arcgis/rest/services/City/FeatureServer"
+	client := arcgis.NewClient(5 * time.Second)
+	layers := map[string]arcgis.AvailableLayerInfo{
+		"0": {ID: "0", Name: "Hydrants", ServiceURL: serviceURL},
+		"1": {ID: "1", Name: "Parks", ServiceURL: serviceURL, ParentPath: []string{"Recreation"}},
+	}
+
+	dir := t.TempDir()
+	manifest := &runManifest{path: ManifestFileName}
+	var counts layerCounts
+	processLayers(client, layers, layerProcessConfig{format: "geojson", outputDir: dir, mergeLayers: true, manifest: manifest}, &counts)
+	if counts.success.Load() != 2 || counts.errors.Load() != 0 {
+		t.Fatalf("Unexpected counts: %d succeeded, %d failed", counts.success.Load(), counts.errors.Load())
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "City.geojson"))
+	if err != nil {
+		t.Fatalf("Merged output not written: %v", err)
+	}
+	var geoJSON convert.GeoJSON
+	if err := json.Unmarshal(data, &geoJSON); err != nil {
+		t.Fatalf("Failed to parse merged GeoJSON: %v", err)
+	}
+	var provenance []string
+	for _, feature := range geoJSON.Features {
+		provenance = append(provenance, fmt.Sprintf("%v/%v/%v", feature.Properties[MergeFieldLayer], feature.Properties[MergeFieldLayerID], feature.Properties["NAME"]))
+		if feature.Properties[MergeFieldSource] != serviceURL {
+			t.Errorf("Unexpected source %v", feature.Properties[MergeFieldSource])
+		}
+	}
+	if got := strings.Join(provenance, ","); got != "Hydrants/0/H1,Hydrants/0/H2,Hydrants/0/H3,Parks/1/P1" {
+		t.Errorf("Unexpected merged features %s", got)
+	}
+	var merged manifestEntry
+	for _, entry := range manifest.entries {
+		if len(entry.MergedLayers) > 0 {
+			merged = entry
+		}
+	}
+	if merged.Name != "City" || merged.FeatureCount != 4 || len(merged.MergedLayers) != 2 || merged.SHA256 == "" {
+		t.Errorf("Unexpected merged manifest entry %+v", merged)
+	}
+
+	processLayers(client, layers, layerProcessConfig{format: "kml", outputDir: dir, mergeLayers: true}, &counts)
+	data, err = os.ReadFile(filepath.Join(dir, "City.kml"))
+	if err != nil {
+		t.Fatalf("Merged KML not written: %v", err)
+	}
+	kml := string(data)
+	for _, want := range []string{"<name>Hydrants</name>", "<name>Recreation</name>", "<name>Parks</name>", `name="layer_id">1<`} {
+		if !strings.Contains(kml, want) {
+			t.Errorf("Merged KML is missing %s", want)
+		}
+	}
+
+	counts = layerCounts{}
+	processLayers(client, layers, layerProcessConfig{format: "esrijson", outputDir: dir, mergeLayers: true}, &counts)
+	if counts.errors.Load() != 1 {
+		t.Error("Expected merging point and polygon layers into Esri JSON to fail")
+	}
+}
+
+func TestSplitLayer(t *testing.T) {
+	server := newFeatureServer(t, nil)
+	serviceURL := server.URL + "/arcgis/rest/services/City/FeatureServer"
+	client := arcgis.NewClient(5 * time.Second)
+	layers := map[string]arcgis.AvailableLayerInfo{
+		"0": {ID: "0", Name: "Hydrants", ServiceURL: serviceURL},
+	}
+
+	dir := t.TempDir()
+	manifest := &runManifest{path: ManifestFileName}
+	var counts layerCounts
+	processLayers(client, layers, layerProcessConfig{format: "csv", outputDir: dir, splitBy: "status", manifest: manifest}, &counts)
+	if counts.success.Load() != 1 || counts.errors.Load() != 0 {
+		t.Fatalf("Unexpected counts: %d succeeded, %d failed", counts.success.Load(), counts.errors.Load())
+	}
+	for file, want := range map[string]int{"Open.csv": 2, "Closed.csv": 1} {
+		data, err := os.ReadFile(filepath.Join(dir, "Hydrants", file))
+		if err != nil {
+			t.Fatalf("Split output %s not written: %v", file, err)
+		}
+		if rows := strings.Count(strings.TrimSpace(string(data)), "\n"); rows != want {
+			t.Errorf("%s has %d rows, want %d", file, rows, want)
+		}
+	}
+
+	entry := manifest.entries[0]
+	if entry.FeatureCount != 3 || len(entry.Splits) != 2 {
+		t.Fatalf("Unexpected manifest entry %+v", entry)
+	}
+	if split := entry.Splits[0]; split.Value != "Closed" || split.FeatureCount != 1 || split.Status != StatusSuccess || split.SHA256 == "" {
+		t.Errorf("Unexpected split %+v", split)
+	}
+	if split := entry.Splits[1]; split.Value != "Open" || split.FeatureCount != 2 {
+		t.Errorf("Unexpected split %+v", split)
+	}
+
+	counts = layerCounts{}
+	processLayers(client, layers, layerProcessConfig{format: "csv", outputDir: dir, splitBy: "status", skipExisting: true}, &counts)
+	if counts.skipped.Load() != 1 {
+		t.Error("Expected an existing split output to be skipped")
+	}
+
+	counts = layerCounts{}
+	processLayers(client, layers, layerProcessConfig{format: "geojson", outputDir: dir, splitBy: "COUNTY"}, &counts)
+	if counts.errors.Load() != 1 {
+		t.Error("Expected splitting by a missing field to fail")
+	}
+
+	// Large numeric values name files and splits in plain decimal notation.
+	var features []arcgis.Feature
+	for i, id := range []float64{1234567, 20240101, 1234567} {
+		features = append(features, arcgis.Feature{
+			Attributes: map[string]interface{}{"OBJECTID": float64(i + 1), "PARCEL": id},
+			Geometry:   map[string]interface{}{"x": -122.0, "y": 37.0},
+		})
+	}
+	config := layerProcessConfig{format: "csv", outputDir: dir, splitBy: "parcel"}
+	layer, err := prepareLayer(arcgis.AvailableLayerInfo{ID: "5", Name: "Parcels"}, arcgis.Layer{Name: "Parcels"}, features, config)
+	if err != nil {
+		t.Fatalf("prepareLayer failed: %v", err)
+	}
+	report, err := writeSplitLayer(layer, config)
+	if err != nil {
+		t.Fatalf("writeSplitLayer failed: %v", err)
+	}
+	if len(report.splits) != 2 || report.splits[0].Value != "1234567" || report.splits[0].FeatureCount != 2 || report.splits[1].Value != "20240101" {
+		t.Fatalf("Unexpected splits %+v", report.splits)
+	}
+	for _, file := range []string{"1234567.csv", "20240101.csv"} {
+		if _, err := os.Stat(filepath.Join(dir, "Parcels", file)); err != nil {
+			t.Errorf("Split output %s not written: %v", file, err)
+		}
+	}
+}
+
+func TestSplitFeatures(t *testing.T) {
+	values := []interface{}{"Adams County", nil, "Adams_County", "", 3.0, 1234567.0, 20240101.0}
+	layer := &preparedLayer{}
+	for _, value := range values {
+		feature := arcgis.Feature{Attributes: map[string]interface{}{"COUNTY": value}}
+		layer.features = append(layer.features, feature)
+		layer.exportFeatures = append(layer.exportFeatures, convertToConvertFeature(feature))
+	}
+
+	var got []string
+	for _, group := range splitFeatures(layer, "COUNTY") {
+		got = append(got, fmt.Sprintf("%q:%d", group.value, len(group.features)))
+	}
+	if want := `"":1,"1234567":1,"20240101":1,"3":1,"Adams County":1,"Adams_County":1,"null":1`; strings.Join(got, ",") != want {
+		t.Errorf("splitFeatures() = %s, want %s", strings.Join(got, ","), want)
+	}
+}
+
+func TestStdoutOutput(t *testing.T) {
+	server := newFeatureServer(t, nil)
+	serviceURL := server.URL + "/arcgis/rest/services/City/FeatureServer"
+	client := arcgis.NewClient(5 * time.Second)
+	dir := t.TempDir()
+
+	var out bytes.Buffer
+	var counts layerCounts
+	config := layerProcessConfig{format: "geojson", outputDir: dir, stdout: &out}
+	processLayers(client, map[string]arcgis.AvailableLayerInfo{"0": {ID: "0", Name: "Hydrants", ServiceURL: serviceURL}}, config, &counts)
+	if counts.success.Load() != 1 {
+		t.Fatalf("Unexpected counts: %d succeeded, %d failed", counts.success.Load(), counts.errors.Load())
+	}
+	var geoJSON convert.GeoJSON
+	if err := json.Unmarshal(out.Bytes(), &geoJSON); err != nil {
+		t.Fatalf("Standard output is not GeoJSON: %v", err)
+	}
+	if len(geoJSON.Features) != 3 {
+		t.Errorf("Expected 3 features on standard output, got %d", len(geoJSON.Features))
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected no files written, found %d", len(entries))
+	}
+
+	out.Reset()
+	config.format = "gml"
+	report, err := writeOutput(layerReport{}, filepath.Join(dir, "Parks"), "gml", encodeString("<gml/>"), map[string]string{"Parks.xsd": "<xsd/>"}, config)
+	if err != nil {
+		t.Fatalf("writeOutput() error = %v", err)
+	}
+	if out.String() != "<gml/>" || report.outputPath != OutputStdout || report.bytes != 6 || len(report.warnings) != 1 {
+		t.Errorf("Unexpected output %q with report %+v", out.String(), report)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Parks.xsd")); !os.IsNotExist(err) {
+		t.Error("Expected the GML schema not to be written")
+	}
+
+	if err := checkSingleOutput(config, 2); err == nil {
+		t.Error("Expected writing two layers to standard output to fail")
+	}
+	config.mergeLayers = true
+	if err := checkSingleOutput(config, 2); err != nil {
+		t.Errorf("Expected merged layers to be written to standard output, got %v", err)
+	}
+}
+
+func TestCompressedOutput(t *testing.T) {
+	data := strings.Repeat(`{"type":"Feature"}`, 100)
+	for _, compression := range []string{CompressGzip, CompressZstd} {
+		dir := t.TempDir()
+		config := layerProcessConfig{outputDir: dir, compress: compression}
+		report, err := writeOutput(layerReport{}, filepath.Join(dir, "Hydrants"), "geojson", encodeString(data), nil, config)
+		if err != nil {
+			t.Fatalf("%s: writeOutput() error = %v", compression, err)
+		}
+		ext, _ := compressionExtension(compression)
+		if report.outputPath != filepath.Join(dir, "Hydrants.geojson"+ext) {
+			t.Errorf("%s: unexpected output path %s", compression, report.outputPath)
+		}
+		compressed, err := os.ReadFile(report.outputPath)
+		if err != nil {
+			t.Fatalf("%s: output not written: %v", compression, err)
+		}
+		checksum := sha256.Sum256(compressed)
+		if report.bytes != int64(len(compressed)) || report.sha256 != hex.EncodeToString(checksum[:]) {
+			t.Errorf("%s: report does not describe the compressed file", compression)
+		}
+
+		var decompressed []byte
+		if compression == CompressGzip {
+			reader, err := gzip.NewReader(bytes.NewReader(compressed))
+			if err != nil {
+				t.Fatalf("gzip: %v", err)
+			}
+			decompressed, err = io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("gzip: %v", err)
+			}
+		} else {
+			decoder, err := zstd.NewReader(nil)
+			if err != nil {
+				t.Fatalf("zstd: %v", err)
+			}
+			decompressed, err = decoder.DecodeAll(compressed, nil)
+			decoder.Close()
+			if err != nil {
+				t.Fatalf("zstd: %v", err)
+			}
+		}
+		if string(decompressed) != data {
+			t.Errorf("%s: decompressed output differs from the data", compression)
+		}
+	}
+
+	// An encoder failing part way through leaves no truncated file behind.
+	dir := t.TempDir()
+	failing := func(w io.Writer) error {
+		if err := encodeString(data)(w); err != nil {
+			return err
+		}
+		return fmt.Errorf("encoder failed")
+	}
+	if _, err := writeOutput(layerReport{}, filepath.Join(dir, "Hydrants"), "csv", failing, nil, layerProcessConfig{outputDir: dir, compress: CompressZstd}); err == nil {
+		t.Error("Expected the encoder error to be returned")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected the partial output to be removed, found %d entries", len(entries))
+	}
+
+	if _, err := compressionExtension("bzip2"); err == nil {
+		t.Error("Expected an unknown compression to be rejected")
+	}
+}
+
+func TestRunArchive(t *testing.T) {
+	server := newFeatureServer(t, nil)
+	serviceURL := server.URL + "/arcgis/rest/services/City/FeatureServer"
+	client := arcgis.NewClient(5 * time.Second)
+	dir := t.TempDir()
+	config := layerProcessConfig{
+		format:         "gml",
+		outputDir:      dir,
+		outputTemplate: "{service}/{layerName}",
+		compress:       CompressGzip,
+		manifest:       &runManifest{path: ManifestFileName},
+		archive:        &runArchive{path: filepath.Join(dir, "export.zip")},
+	}
+
+	var counts layerCounts
+	processLayers(client, map[string]arcgis.AvailableLayerInfo{"0": {ID: "0", Name: "Hydrants", ServiceURL: serviceURL}}, config, &counts)
+	if err := finishRun(config); err != nil {
+		t.Fatalf("finishRun() error = %v", err)
+	}
+
+	archive, err := zip.OpenReader(config.archive.path)
+	if err != nil {
+		t.Fatalf("Archive not written: %v", err)
+	}
+	defer archive.Close()
+	var names []string
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+	}
+	if got := strings.Join(names, ","); got != "City/Hydrants.gml.gz,City/Hydrants.xsd,manifest.json" {
+		t.Errorf("Unexpected archive entries %s", got)
+	}
+
+	// The archive is a bundle: the manifest and outputs stay in place for other tools.
+	for _, name := range []string{"City/Hydrants.gml.gz", "City/Hydrants.xsd", ManifestFileName} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected %s to be kept next to the archive: %v", name, err)
+		}
+	}
+}
+
+// captureOutput runs fn with stdout and stderr redirected, returning what each received.
+func captureOutput(t *testing.T, fn func()) (string, string) {
+	t.Helper()
+	stdout, stderr, console, arcgisOutput, exportOutput := os.Stdout, os.Stderr, consoleOutput, arcgis.Output, export.Output
+	defer func() {
+		os.Stdout, os.Stderr, consoleOutput, arcgis.Output, export.Output = stdout, stderr, console, arcgisOutput, exportOutput
+	}()
+
+	read := func(r *os.File) <-chan string {
+		out := make(chan string)
+		go func() {
+			data, _ := io.ReadAll(r)
+			out <- string(data)
+		}()
+		return out
+	}
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	outC, errC := read(outR), read(errR)
+	os.Stdout, os.Stderr, consoleOutput, arcgis.Output, export.Output = outW, errW, outW, outW, outW
+	fn()
+	outW.Close()
+	errW.Close()
+	return <-outC, <-errC
+}
+
+func TestDownloadToStdout(t *testing.T) {
+	server := newFeatureServer(t, nil)
+	layerURL := server.URL + "/arcgis/rest/services/City/FeatureServer/0"
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	defer func() { layersToProcess = make(map[string]arcgis.AvailableLayerInfo) }()
+
+	stdout, stderr := captureOutput(t, func() {
+		runDownload([]string{"-no-color", "-url", layerURL, "-format", "geojson", "-output", "-"})
+	})
+
+	var geoJSON convert.GeoJSON
+	if err := json.Unmarshal([]byte(stdout), &geoJSON); err != nil {
+		t.Fatalf("Standard output is not only GeoJSON: %v\n%s", err, stdout)
+	}
+	if len(geoJSON.Features) != 3 {
+		t.Errorf("Expected 3 features on standard output, got %d", len(geoJSON.Features))
+	}
+	if !strings.Contains(stderr, "Successfully processed layer") || !strings.Contains(stderr, "Processing Complete") {
+		t.Errorf("Expected progress messages on stderr, got %q", stderr)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected no files written, found %d entries", len(entries))
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/arcgis"
+)
+
+// layerReport describes what exporting a layer produced.
+type layerReport struct {
+	name         string // Resolved layer name
+	outputPath   string
+	featureCount int
+	bytes        int64
+	sha256       string
+	warnings     []string
+	splits       []splitReport // Files written when splitting by attribute value
+}
+
+// manifestEntry is the outcome of processing one layer, as written to the run manifest.
+type manifestEntry struct {
+	SourceURL    string        `json:"sourceUrl"`
+	LayerID      string        `json:"layerId"`
+	Name         string        `json:"name"`
+	Status       string        `json:"status"`
+	Format       string        `json:"format"`
+	FeatureCount int           `json:"featureCount"`
+	OutputPath   string        `json:"outputPath,omitempty"`
+	Bytes        int64         `json:"bytes,omitempty"`
+	SHA256       string        `json:"sha256,omitempty"`
+	DurationMs   int64         `json:"durationMs"`
+	Warnings     []string      `json:"warnings,omitempty"`
+	Error        string        `json:"error,omitempty"`
+	MergedLayers []string      `json:"mergedLayers,omitempty"` // Layers written to a merged output
+	Splits       []splitReport `json:"splits,omitempty"`       // Files written per split field value
+}
+
+// runManifest collects the outcome of every layer processed in a run, so that pipeline
+// tools can consume the results. A nil manifest records nothing.
+type runManifest struct {
+	path    string // Manifest file, relative to the output directory unless absolute
+	mu      sync.Mutex
+	entries []manifestEntry
+}
+
+// add records the outcome of processing a layer.
+func (m *runManifest) add(layerInfo arcgis.AvailableLayerInfo, format string, report layerReport, err error, duration time.Duration) {
+	m.addEntry(newManifestEntry(layerInfo, format, report, err, duration))
+}
+
+// addEntry records a manifest entry.
+func (m *runManifest) addEntry(entry manifestEntry) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, entry)
+}
+
+// newManifestEntry returns the manifest entry of a processed layer.
+func newManifestEntry(layerInfo arcgis.AvailableLayerInfo, format string, report layerReport, err error, duration time.Duration) manifestEntry {
+	entry := manifestEntry{
+		SourceURL:    layerInfo.ServiceURL,
+		LayerID:      layerInfo.ID,
+		Name:         report.name,
+		Status:       layerStatus(err),
+		Format:       format,
+		FeatureCount: report.featureCount,
+		OutputPath:   report.outputPath,
+		Bytes:        report.bytes,
+		SHA256:       report.sha256,
+		DurationMs:   duration.Milliseconds(),
+		Warnings:     report.warnings,
+		Splits:       report.splits,
+	}
+	if entry.Name == "" {
+		entry.Name = layerInfo.Name
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+// write writes the manifest as JSON to path, listing layers by source URL, layer ID and
+// output path so that runs of the same job produce comparable manifests.
+func (m *runManifest) write(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := append([]manifestEntry{}, m.entries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].SourceURL != entries[j].SourceURL {
+			return entries[i].SourceURL < entries[j].SourceURL
+		}
+		if entries[i].LayerID != entries[j].LayerID {
+			return entries[i].LayerID < entries[j].LayerID
+		}
+		return entries[i].OutputPath < entries[j].OutputPath
+	})
+
+	document := struct {
+		CreatedAt string          `json:"createdAt"`
+		Succeeded int             `json:"succeeded"`
+		Skipped   int             `json:"skipped"`
+		Failed    int             `json:"failed"`
+		Layers    []manifestEntry `json:"layers"`
+	}{CreatedAt: time.Now().UTC().Format(time.RFC3339), Layers: entries}
+	for _, entry := range entries {
+		switch entry.Status {
+		case StatusSuccess:
+			document.Succeeded++
+		case StatusSkipped:
+			document.Skipped++
+		default:
+			document.Failed++
+		}
+	}
+
+	data, err := json.MarshalIndent(document, "", JSONIndent)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), DirPerm); err != nil {
+		return fmt.Errorf("failed to create manifest directory: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), FilePerm); err != nil {
+		return fmt.Errorf("failed to write manifest %s: %v", path, err)
+	}
+	return nil
+}
+
+// writeManifest writes the run manifest of a configuration, if any, reporting failures
+// without failing the run.
+func writeManifest(config layerProcessConfig) {
+	if config.manifest == nil {
+		return
+	}
+	path := config.manifest.path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(config.outputDir, path)
+	}
+	if err := config.manifest.write(path); err != nil {
+		printError(fmt.Sprintf("Error writing manifest: %v", err))
+		return
+	}
+	config.archive.add(path)
+	printInfo(fmt.Sprintf("Wrote manifest %s", path))
+}
+
+// layerStatus classifies the outcome of processing a layer.
+func layerStatus(err error) string {
+	switch {
+	case err == nil:
+		return StatusSuccess
+	case errors.Is(err, errSkippedExisting), errors.Is(err, errNoFeatures):
+		return StatusSkipped
+	}
+	return StatusFailed
+}
@@ -0,0 +1,256 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/arcgis"
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/convert"
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/export"
+)
+
+// layerMerger collects the prepared layers of a run in merge mode, so that they are
+// written as a single output once every layer has been downloaded.
+type layerMerger struct {
+	mu     sync.Mutex
+	layers []*preparedLayer
+}
+
+// add collects a prepared layer.
+func (m *layerMerger) add(layer *preparedLayer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.layers = append(m.layers, layer)
+}
+
+// sortedLayers returns the collected layers by service URL and layer ID, so that the
+// merged output does not depend on the order the downloads finished in.
+func (m *layerMerger) sortedLayers() []*preparedLayer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	layers := append([]*preparedLayer{}, m.layers...)
+	sort.SliceStable(layers, func(i, j int) bool {
+		if layers[i].info.ServiceURL != layers[j].info.ServiceURL {
+			return layers[i].info.ServiceURL < layers[j].info.ServiceURL
+		}
+		a, errA := strconv.Atoi(layers[i].info.ID)
+		b, errB := strconv.Atoi(layers[j].info.ID)
+		if errA == nil && errB == nil {
+			return a < b
+		}
+		return layers[i].info.ID < layers[j].info.ID
+	})
+	return layers
+}
+
+// finishMerge writes the layers collected in merge mode as one output, recording it in
+// the run manifest. A failure to write it counts as a failed layer.
+func finishMerge(config layerProcessConfig, counts *layerCounts) {
+	layers := config.merger.sortedLayers()
+	if len(layers) == 0 {
+		return
+	}
+
+	start := time.Now()
+	report, err := writeMergedLayers(layers, config)
+	entry := newManifestEntry(arcgis.AvailableLayerInfo{Name: report.name}, config.format, report, err, time.Since(start))
+	for _, layer := range layers {
+		entry.MergedLayers = append(entry.MergedLayers, fmt.Sprintf(LayerKeyFormat, layer.info.ServiceURL, layer.info.ID))
+	}
+	config.manifest.addEntry(entry)
+
+	switch layerStatus(err) {
+	case StatusSuccess:
+		printSuccess(fmt.Sprintf("  Merged %d layer(s) into %s.", len(layers), report.outputPath))
+	case StatusSkipped:
+		printWarning(fmt.Sprintf("  Skipped merged output of %d layer(s) (output file exists).", len(layers)))
+	default:
+		printError(fmt.Sprintf("  Error writing merged output: %v", err))
+		counts.errors.Add(1)
+	}
+}
+
+// writeMergedLayers writes the collected layers as one output. Every feature keeps its
+// provenance in the layer, layer_id and layer_source attributes. KML and KMZ documents
+// hold a folder per layer, nested in the layer's Web Map group path, and TopoJSON
+// topologies an object per layer; the other formats hold the features of all layers.
+//
+// Parameters:
+//   - layers: Prepared layers, in output order
+//   - config: Processing configuration of the run
+//
+// Returns:
+//   - layerReport: What writing the merged output produced
+//   - error: Any error that occurred while merging or writing
+func writeMergedLayers(layers []*preparedLayer, config layerProcessConfig) (layerReport, error) {
+	merged := mergeLayers(layers)
+	if len(layers) == 1 {
+		printWarning("  Warning: Only one layer was selected to merge.")
+	}
+
+	// Split files hold the features of all layers, whatever the format.
+	format := strings.ToLower(config.format)
+	if config.splitBy != "" {
+		format = ""
+	}
+	switch format {
+	case "kml", "kmz", "topojson":
+	case "esrijson":
+		geometryTypes := make(map[string]bool)
+		for _, layer := range layers {
+			if layer.metadata.GeometryType != "" {
+				geometryTypes[layer.metadata.GeometryType] = true
+			}
+		}
+		if len(geometryTypes) > 1 {
+			return merged.report, fmt.Errorf("cannot merge layers with different geometry types into an Esri JSON FeatureSet")
+		}
+		return writeLayer(merged, config)
+	default:
+		return writeLayer(merged, config)
+	}
+
+	var encode layerEncoder
+	var fileExt string
+	switch format {
+	case "kml", "kmz":
+		kmlLayers := make([]export.KMLLayer, len(layers))
+		for i, layer := range layers {
+			opts := kmlOptions(config, layer.info, &layer.metadata)
+			kmlLayers[i] = export.KMLLayer{
+				Name:         layer.name,
+				GeoJSON:      layer.geoJSON,
+				FolderPath:   layer.info.ParentPath,
+				FolderFor:    opts.FolderFor,
+				Fields:       withProvenanceFields(opts.Fields),
+				TimeField:    opts.TimeField,
+				EndTimeField: opts.EndTimeField,
+				NameFor:      opts.NameFor,
+				LabelColor:   opts.LabelColor,
+				LabelSize:    opts.LabelSize,
+			}
+		}
+		opts := export.KMLOptions{BalloonTemplate: config.kmlBalloon}
+		if format == "kmz" {
+			kmz, err := export.ConvertLayersToKMZ(merged.name, kmlLayers, opts)
+			if err != nil {
+				return merged.report, fmt.Errorf("failed to convert to KMZ: %v", err)
+			}
+			encode, fileExt = encodeBytes(kmz), "kmz"
+		} else {
+			kml, err := export.ConvertLayersToKML(merged.name, kmlLayers, opts)
+			if err != nil {
+				return merged.report, fmt.Errorf("failed to convert to KML: %v", err)
+			}
+			encode, fileExt = encodeString(kml), "kml"
+		}
+	case "topojson":
+		topoLayers := make([]export.TopoJSONLayer, len(layers))
+		names := make(map[string]bool)
+		for i, layer := range layers {
+			// Object names must be unique within the topology.
+			name := layer.name
+			if names[name] {
+				name = fmt.Sprintf("%s_%s", name, layer.info.ID)
+			}
+			names[name] = true
+			topoLayers[i] = export.TopoJSONLayer{Name: name, GeoJSON: layer.geoJSON}
+		}
+		topoJSON, err := export.ConvertLayersToTopoJSON(topoLayers, export.DefaultTopoJSONQuantization)
+		if err != nil {
+			return merged.report, fmt.Errorf("failed to convert to TopoJSON: %v", err)
+		}
+		encode, fileExt = encodeString(topoJSON), "topojson"
+	}
+	return writeOutput(merged.report, merged.outputBase(config), fileExt, encode, nil, config)
+}
+
+// mergeLayers adds the provenance attributes to the features of each layer and combines
+// the layers into one, named after their common service, or "merged".
+func mergeLayers(layers []*preparedLayer) *preparedLayer {
+	serviceURL := layers[0].info.ServiceURL
+	geometryType := layers[0].metadata.GeometryType
+	name := MergedLayerName
+	seenFields := make(map[string]bool)
+	merged := &preparedLayer{geoJSON: &convert.GeoJSON{Type: layers[0].geoJSON.Type, CRS: layers[0].geoJSON.CRS}}
+
+	for _, layer := range layers {
+		provenance := map[string]interface{}{
+			MergeFieldLayer:   layer.name,
+			MergeFieldLayerID: layer.info.ID,
+			MergeFieldSource:  layer.info.ServiceURL,
+		}
+		for i := range layer.features {
+			layer.features[i].Attributes = withAttributes(layer.features[i].Attributes, provenance)
+		}
+		for i := range layer.exportFeatures {
+			layer.exportFeatures[i].Attributes = withAttributes(layer.exportFeatures[i].Attributes, provenance)
+		}
+		for i := range layer.geoJSON.Features {
+			layer.geoJSON.Features[i].Properties = withAttributes(layer.geoJSON.Features[i].Properties, provenance)
+		}
+
+		merged.features = append(merged.features, layer.features...)
+		merged.exportFeatures = append(merged.exportFeatures, layer.exportFeatures...)
+		merged.geoJSON.Features = append(merged.geoJSON.Features, layer.geoJSON.Features...)
+		merged.report.featureCount += layer.report.featureCount
+		for _, warning := range layer.report.warnings {
+			merged.report.warnings = append(merged.report.warnings, fmt.Sprintf("%s: %s", layer.name, warning))
+		}
+		for _, field := range layer.metadata.Fields {
+			if !seenFields[field.Name] {
+				seenFields[field.Name] = true
+				merged.metadata.Fields = append(merged.metadata.Fields, field)
+			}
+		}
+		if layer.info.ServiceURL != serviceURL {
+			serviceURL = ""
+		}
+		if layer.metadata.GeometryType != geometryType {
+			geometryType = ""
+		}
+	}
+
+	if service := serviceName(serviceURL); service != "" {
+		name = service
+	}
+	merged.info = arcgis.AvailableLayerInfo{Name: name, ServiceURL: serviceURL}
+	merged.metadata.Name = name
+	merged.metadata.GeometryType = geometryType
+	merged.metadata.Fields = withProvenanceFields(merged.metadata.Fields)
+	merged.name = name
+	merged.safeName = safeFilename(name)
+	merged.report.name = name
+	return merged
+}
+
+// withAttributes returns attributes with the given values added, creating the map if needed.
+func withAttributes(attributes, values map[string]interface{}) map[string]interface{} {
+	if attributes == nil {
+		attributes = make(map[string]interface{}, len(values))
+	}
+	for key, value := range values {
+		attributes[key] = value
+	}
+	return attributes
+}
+
+// withProvenanceFields appends the provenance attributes to a field schema. An empty
+// schema is left empty, as it is inferred from the attributes.
+func withProvenanceFields(fields []arcgis.Field) []arcgis.Field {
+	if len(fields) == 0 {
+		return fields
+	}
+	withProvenance := append([]arcgis.Field{}, fields...)
+	for _, name := range []string{MergeFieldLayer, MergeFieldLayerID, MergeFieldSource} {
+		withProvenance = append(withProvenance, arcgis.Field{Name: name, Type: export.EsriFieldTypeString, Alias: name})
+	}
+	return withProvenance
+}
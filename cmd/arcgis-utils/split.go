@@ -0,0 +1,167 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/arcgis"
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/convert"
+)
+
+// splitReport describes one file written when splitting a layer by attribute value.
+type splitReport struct {
+	Value        string `json:"value"`
+	OutputPath   string `json:"outputPath,omitempty"`
+	FeatureCount int    `json:"featureCount"`
+	Bytes        int64  `json:"bytes,omitempty"`
+	SHA256       string `json:"sha256,omitempty"`
+	Status       string `json:"status"`
+}
+
+// splitGroup holds the features of a layer that share a value of the split field.
+type splitGroup struct {
+	value          string
+	features       []arcgis.Feature
+	exportFeatures []convert.Feature
+}
+
+// writeSplitLayer writes one file per distinct value of the split field, in a directory
+// named after the layer. Files are named after a safe version of the value; values that
+// end up with the same file name are numbered.
+//
+// Parameters:
+//   - layer: Prepared layer to split
+//   - config: Processing configuration, with the split field
+//
+// Returns:
+//   - layerReport: What writing the files produced, with a split report per file
+//   - error: Any error that occurred while splitting or writing
+func writeSplitLayer(layer *preparedLayer, config layerProcessConfig) (layerReport, error) {
+	report := layer.report
+	field, err := resolveSplitField(layer, config.splitBy)
+	if err != nil {
+		return report, err
+	}
+
+	outputDir := layer.outputBase(config)
+	usedNames := make(map[string]bool)
+	report.featureCount = 0
+	written, skipped := 0, 0
+	for _, group := range splitFeatures(layer, field) {
+		name := safeFilename(group.value)
+		if name == "" {
+			name = SplitEmptyValueName
+		}
+		base := name
+		for i := 2; usedNames[strings.ToLower(name)]; i++ {
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+		usedNames[strings.ToLower(name)] = true
+
+		geoJSON, err := convert.ToGeoJSON(group.exportFeatures)
+		if err != nil {
+			return report, fmt.Errorf("failed to convert features to GeoJSON objects: %v", err)
+		}
+		part := &preparedLayer{
+			info:           layer.info,
+			metadata:       layer.metadata,
+			name:           layer.name,
+			safeName:       name,
+			features:       group.features,
+			exportFeatures: group.exportFeatures,
+			geoJSON:        geoJSON,
+			report:         layerReport{name: layer.name, featureCount: len(geoJSON.Features)},
+		}
+		if attributeFormats[strings.ToLower(config.format)] {
+			part.report.featureCount = len(group.features)
+		}
+		if part.report.featureCount == 0 {
+			// Every feature of the value lacks a geometry the format can hold.
+			continue
+		}
+
+		encode, fileExt, sidecars, err := encodeLayer(part, filepath.Join(outputDir, name), config)
+		if err != nil {
+			return report, fmt.Errorf("failed to write %s = %s: %v", field, group.value, err)
+		}
+		partReport, err := writeOutput(part.report, filepath.Join(outputDir, name), fileExt, encode, sidecars, config)
+		split := splitReport{
+			Value:        group.value,
+			OutputPath:   partReport.outputPath,
+			FeatureCount: part.report.featureCount,
+			Bytes:        partReport.bytes,
+			SHA256:       partReport.sha256,
+			Status:       layerStatus(err),
+		}
+		switch split.Status {
+		case StatusSuccess:
+			written++
+			report.featureCount += split.FeatureCount
+			report.bytes += split.Bytes
+			printInfo(fmt.Sprintf("    %s = %s: %d feature(s) to %s", field, group.value, split.FeatureCount, split.OutputPath))
+		case StatusSkipped:
+			skipped++
+		default:
+			return report, fmt.Errorf("failed to write %s = %s: %v", field, group.value, err)
+		}
+		report.splits = append(report.splits, split)
+	}
+
+	if written == 0 && skipped > 0 {
+		return report, errSkippedExisting
+	}
+	report.outputPath = outputDir
+	return report, nil
+}
+
+// resolveSplitField returns the attribute name of the split field, matching the layer's
+// fields, or its features' attributes when it has none, without regard to case.
+func resolveSplitField(layer *preparedLayer, name string) (string, error) {
+	for _, field := range layer.metadata.Fields {
+		if strings.EqualFold(field.Name, name) {
+			return field.Name, nil
+		}
+	}
+	if len(layer.metadata.Fields) == 0 {
+		for _, feature := range layer.exportFeatures {
+			for key := range feature.Attributes {
+				if strings.EqualFold(key, name) {
+					return key, nil
+				}
+			}
+		}
+	}
+	return "", fmt.Errorf("split field %s not found in layer %s", name, layer.name)
+}
+
+// splitFeatures groups a layer's features by the value of a field, ordered by value.
+// Values are formatted as attribute text, so that large numbers such as IDs and dates
+// are never written in exponent form. Features without a value are grouped under "null".
+func splitFeatures(layer *preparedLayer, field string) []*splitGroup {
+	groups := make(map[string]*splitGroup)
+	for i, feature := range layer.exportFeatures {
+		value := SplitNullValue
+		if v, ok := feature.Attributes[field]; ok && v != nil {
+			value = convert.FormatValue(v)
+		}
+		group := groups[value]
+		if group == nil {
+			group = &splitGroup{value: value}
+			groups[value] = group
+		}
+		group.features = append(group.features, layer.features[i])
+		group.exportFeatures = append(group.exportFeatures, feature)
+	}
+
+	sorted := make([]*splitGroup, 0, len(groups))
+	for _, group := range groups {
+		sorted = append(sorted, group)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].value < sorted[j].value })
+	return sorted
+}
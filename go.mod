@@ -1,3 +1,8 @@
 module github.com/Sudo-Ivan/arcgis-utils
 
 go 1.24.2
+
+require (
+	github.com/klauspost/compress v1.18.0
+	gopkg.in/yaml.v3 v3.0.1
+)
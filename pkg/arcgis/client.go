@@ -7,15 +7,26 @@ package arcgis
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// Output receives the progress messages and warnings printed while fetching data.
+// Commands writing their result to stdout point it at stderr.
+var Output io.Writer = os.Stdout
+
+// ErrNoFeatures is returned, wrapped, when a feature query matches no features.
+var ErrNoFeatures = errors.New("no features found")
+
 // Client represents an ArcGIS client with configuration.
 // It handles HTTP requests to ArcGIS services with configurable timeouts.
 type Client struct {
@@ -81,7 +92,7 @@ func NormalizeArcGISURL(rawURL string) string {
 	// If it IS an ArcGIS service URL (or AGOL item URL, though AGOL items are less likely to need scheme added)
 	u, err := url.Parse(rawURL)
 	if err != nil {
-		fmt.Printf("Warning: Failed to parse URL for normalization: %v\n", err)
+		fmt.Fprintf(Output, "Warning: Failed to parse URL for normalization: %v\n", err)
 		return rawURL // Return original on parse error
 	}
 
@@ -197,17 +208,39 @@ func (c *Client) FetchAndDecode(urlStr string, target interface{}) error {
 //   - []Feature: Slice of features from the layer
 //   - error: Any error that occurred during the fetch operation
 func (c *Client) FetchFeatures(baseURL, layerID string) ([]Feature, error) {
+	featureResp, err := c.FetchFeaturesWithOptions(baseURL, layerID, QueryOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return featureResp.Features, nil
+}
+
+// FetchFeaturesWithOptions fetches the features of an ArcGIS layer matching a query.
+// The function handles:
+//   - Filtering features with a where clause, defaulting to all features
+//   - Limiting the returned attributes, defaulting to all fields
+//   - Projecting geometries to a spatial reference, defaulting to WGS84
+//
+// Parameters:
+//   - baseURL: Base URL of the FeatureServer or MapServer
+//   - layerID: ID of the layer to fetch features from
+//   - opts: Query options
+//
+// Returns:
+//   - *FeatureResponse: Features from the layer and whether the transfer limit was exceeded
+//   - error: Any error that occurred during the fetch operation
+func (c *Client) FetchFeaturesWithOptions(baseURL, layerID string, opts QueryOptions) (*FeatureResponse, error) {
 	queryURL := fmt.Sprintf("%s/%s/query", baseURL, layerID)
 	u, _ := url.Parse(queryURL)
 	q := u.Query()
 	q.Set("f", "json")
-	q.Set("where", "1=1")
-	q.Set("outFields", "*")
+	q.Set("where", opts.whereClause())
+	q.Set("outFields", opts.outFields())
 	q.Set("returnGeometry", "true")
-	q.Set("outSR", "4326")
+	q.Set("outSR", strconv.Itoa(opts.outSR()))
 	u.RawQuery = q.Encode()
 
-	fmt.Printf("    Fetching features: %s\n", u.String())
+	fmt.Fprintf(Output, "    Fetching features: %s\n", u.String())
 
 	req, err := http.NewRequest("GET", u.String(), http.NoBody)
 	if err != nil {
@@ -237,13 +270,86 @@ func (c *Client) FetchFeatures(baseURL, layerID string) ([]Feature, error) {
 	}
 
 	if len(featureResp.Features) == 0 && !featureResp.ExceededTransferLimit {
-		return nil, fmt.Errorf("no features found for layer %s at %s", layerID, baseURL)
+		return nil, fmt.Errorf("%w for layer %s at %s", ErrNoFeatures, layerID, baseURL)
 	}
 	if featureResp.ExceededTransferLimit {
-		fmt.Printf("  Warning: Feature transfer limit exceeded for layer %s. Results may be incomplete.\n", layerID)
+		fmt.Fprintf(Output, "  Warning: Feature transfer limit exceeded for layer %s. Results may be incomplete.\n", layerID)
 	}
 
-	return featureResp.Features, nil
+	return &featureResp, nil
+}
+
+// FetchFeatureCount fetches the number of features in an ArcGIS layer.
+// Parameters:
+//   - baseURL: Base URL of the FeatureServer or MapServer
+//   - layerID: ID of the layer to count features in
+//
+// Returns:
+//   - int: Number of features in the layer
+//   - error: Any error that occurred during the fetch operation
+func (c *Client) FetchFeatureCount(baseURL, layerID string) (int, error) {
+	u, _ := url.Parse(fmt.Sprintf("%s/%s/query", baseURL, layerID))
+	q := u.Query()
+	q.Set("f", "json")
+	q.Set("where", DefaultWhereClause)
+	q.Set("returnCountOnly", "true")
+	u.RawQuery = q.Encode()
+
+	var countResp struct {
+		Count *int `json:"count"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := c.FetchAndDecode(u.String(), &countResp); err != nil {
+		return 0, fmt.Errorf("failed to fetch feature count: %v", err)
+	}
+	if countResp.Error != nil {
+		return 0, fmt.Errorf("feature count API error: %s", countResp.Error.Message)
+	}
+	if countResp.Count == nil {
+		return 0, fmt.Errorf("feature count missing from response")
+	}
+	return *countResp.Count, nil
+}
+
+// SortFeaturesByObjectID orders features by their object ID so that exports of unchanged
+// data are identical regardless of the order the server returned them in.
+// Parameters:
+//   - features: Features to sort in place
+//   - objectIDField: Name of the object ID attribute, or "" for OBJECTID
+//
+// Features without a numeric object ID keep their relative order after the others.
+func SortFeaturesByObjectID(features []Feature, objectIDField string) {
+	if objectIDField == "" {
+		objectIDField = DefaultObjectIDField
+	}
+	id := func(f Feature) (float64, bool) {
+		v, ok := f.Attributes[objectIDField].(float64)
+		return v, ok
+	}
+	sort.SliceStable(features, func(i, j int) bool {
+		a, okA := id(features[i])
+		b, okB := id(features[j])
+		if okA != okB {
+			return okA
+		}
+		return okA && a < b
+	})
+}
+
+// ObjectIDFieldName returns the name of the layer's object ID field: its objectIdField,
+// else its first field of type esriFieldTypeOID, else "".
+func (l *Layer) ObjectIDFieldName() string {
+	if l.ObjectIDField != "" {
+		return l.ObjectIDField
+	}
+	for _, field := range l.Fields {
+		if field.Type == FieldTypeOID {
+			return field.Name
+		}
+	}
+	return ""
 }
 
 // FetchServiceLayers fetches the layers from an ArcGIS Feature Server or Map Server.
@@ -256,7 +362,7 @@ func (c *Client) FetchFeatures(baseURL, layerID string) ([]Feature, error) {
 //   - error: Any error that occurred during the fetch operation
 func (c *Client) FetchServiceLayers(serviceURL string, serviceType string) ([]AvailableLayerInfo, error) {
 	fetchURL := fmt.Sprintf("%s?f=json", serviceURL)
-	fmt.Printf("    Fetching service metadata: %s\n", fetchURL)
+	fmt.Fprintf(Output, "    Fetching service metadata: %s\n", fetchURL)
 
 	availableLayers := []AvailableLayerInfo{}
 
@@ -271,17 +377,17 @@ func (c *Client) FetchServiceLayers(serviceURL string, serviceType string) ([]Av
 		}
 
 		if len(metadata.Layers) == 0 && len(metadata.Tables) == 0 {
-			fmt.Printf("      No layers or tables found in Feature Server metadata at %s\n", fetchURL)
+			fmt.Fprintf(Output, "      No layers or tables found in Feature Server metadata at %s\n", fetchURL)
 		}
 
 		for _, layer := range metadata.Layers {
-			layerIDStr, ok := layer.ID.(json.Number)
+			layerIDStr, ok := layerIDString(layer.ID)
 			if !ok {
-				fmt.Printf("      Warning: Could not parse layer ID for %s\n", layer.Name)
+				fmt.Fprintf(Output, "      Warning: Could not parse layer ID for %s\n", layer.Name)
 				continue
 			}
 			availableLayers = append(availableLayers, AvailableLayerInfo{
-				ID:             layerIDStr.String(),
+				ID:             layerIDStr,
 				Name:           layer.Name,
 				Type:           layer.Type,
 				GeometryType:   layer.GeometryType,
@@ -300,7 +406,7 @@ func (c *Client) FetchServiceLayers(serviceURL string, serviceType string) ([]Av
 		}
 
 		if len(metadata.Layers) == 0 {
-			fmt.Printf("      No layers found in Map Server metadata at %s\n", fetchURL)
+			fmt.Fprintf(Output, "      No layers found in Map Server metadata at %s\n", fetchURL)
 		}
 
 		layerMap := make(map[int]MapServiceLayer)
@@ -349,7 +455,7 @@ func (c *Client) FetchServiceLayers(serviceURL string, serviceType string) ([]Av
 					IsFeatureLayer: true,
 				})
 			} else {
-				fmt.Printf("    Skipping layer '%s' (ID: %d) - Type is '%s', not 'Feature Layer'.\n", layer.Name, layer.ID, layer.Type)
+				fmt.Fprintf(Output, "    Skipping layer '%s' (ID: %d) - Type is '%s', not 'Feature Layer'.\n", layer.Name, layer.ID, layer.Type)
 			}
 		}
 	} else {
@@ -373,7 +479,7 @@ func (c *Client) HandleArcGISOnlineItem(itemPageURL string) (*ItemData, error) {
 		return nil, fmt.Errorf("could not extract item ID from URL: %s", itemPageURL)
 	}
 	itemID := matches[1]
-	fmt.Printf("  Item ID: %s\n", itemID)
+	fmt.Fprintf(Output, "  Item ID: %s\n", itemID)
 
 	itemAPIURL := fmt.Sprintf("https://www.arcgis.com/sharing/rest/content/items/%s?f=json", itemID)
 
@@ -386,7 +492,7 @@ func (c *Client) HandleArcGISOnlineItem(itemPageURL string) (*ItemData, error) {
 		return nil, fmt.Errorf("item API error: %s", itemData.Error.Message)
 	}
 
-	fmt.Printf("  Item Type: %s\n", itemData.Type)
+	fmt.Fprintf(Output, "  Item Type: %s\n", itemData.Type)
 	return &itemData, nil
 }
 
@@ -399,7 +505,7 @@ func (c *Client) HandleArcGISOnlineItem(itemPageURL string) (*ItemData, error) {
 //   - error: Any error that occurred during processing
 func (c *Client) HandleWebMap(itemID string) (*WebMapData, error) {
 	webMapDataURL := fmt.Sprintf("https://www.arcgis.com/sharing/rest/content/items/%s/data?f=json", itemID)
-	fmt.Printf("  Fetching Web Map data: %s\n", webMapDataURL)
+	fmt.Fprintf(Output, "  Fetching Web Map data: %s\n", webMapDataURL)
 
 	var webMapData WebMapData
 	err := c.FetchAndDecode(webMapDataURL, &webMapData)
@@ -412,3 +518,41 @@ func (c *Client) HandleWebMap(itemID string) (*WebMapData, error) {
 
 	return &webMapData, nil
 }
+
+// whereClause returns the query's where clause, matching all features by default.
+func (o QueryOptions) whereClause() string {
+	if strings.TrimSpace(o.Where) == "" {
+		return DefaultWhereClause
+	}
+	return o.Where
+}
+
+// outFields returns the query's comma-separated field list, all fields by default.
+func (o QueryOptions) outFields() string {
+	if len(o.OutFields) == 0 {
+		return DefaultOutFields
+	}
+	return strings.Join(o.OutFields, ",")
+}
+
+// outSR returns the well-known ID of the query's output spatial reference, WGS84 by default.
+func (o QueryOptions) outSR() int {
+	if o.OutSR == 0 {
+		return WGS84WKID
+	}
+	return o.OutSR
+}
+
+// layerIDString returns a layer ID decoded from JSON as a string. Service metadata gives
+// IDs as numbers, which decode as float64 unless the decoder uses json.Number.
+func layerIDString(id interface{}) (string, bool) {
+	switch v := id.(type) {
+	case json.Number:
+		return v.String(), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case string:
+		return v, v != ""
+	}
+	return "", false
+}
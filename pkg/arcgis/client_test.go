@@ -1,6 +1,9 @@
 package arcgis
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -96,3 +99,295 @@ func TestNewClient(t *testing.T) {
 		t.Errorf("NewClient HTTPClient timeout = %v; want %v", client.HTTPClient.Timeout, timeout)
 	}
 }
+
+func TestDecodeSimpleSymbol(t *testing.T) {
+	data := `{
+		"type": "esriSFS",
+		"style": "esriSFSSolid",
+		"color": [255, 128, 0, 191],
+		"outline": {"type": "esriSLS", "style": "esriSLSDash", "color": [0, 0, 0, 255], "width": 0.75}
+	}`
+	var symbol Symbol
+	if err := json.Unmarshal([]byte(data), &symbol); err != nil {
+		t.Fatalf("Failed to decode symbol: %v", err)
+	}
+	if symbol.Style != "esriSFSSolid" || len(symbol.Color) != 4 || symbol.Color[3] != 191 {
+		t.Errorf("Unexpected fill: style %q, color %v", symbol.Style, symbol.Color)
+	}
+	if symbol.Outline == nil || symbol.Outline.Width != 0.75 || symbol.Outline.Style != "esriSLSDash" {
+		t.Errorf("Unexpected outline: %+v", symbol.Outline)
+	}
+}
+
+func TestClassBreaksRenderer(t *testing.T) {
+	data := `{
+		"type": "classBreaks",
+		"field": "POP",
+		"normalizationType": "esriNormalizeByField",
+		"normalizationField": "AREA",
+		"minValue": 0,
+		"defaultSymbol": {"type": "esriSFS", "color": [200, 200, 200, 255]},
+		"classBreakInfos": [
+			{"classMaxValue": 10, "label": "Low", "symbol": {"type": "esriSFS", "color": [0, 255, 0, 255]}},
+			{"classMaxValue": 100, "label": "Medium", "symbol": {"type": "esriSFS", "color": [255, 255, 0, 255]}},
+			{"classMaxValue": 1000, "label": "High", "symbol": {"type": "esriSFS", "color": [255, 0, 0, 255]}}
+		]
+	}`
+	var renderer Renderer
+	if err := json.Unmarshal([]byte(data), &renderer); err != nil {
+		t.Fatalf("Failed to decode renderer: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		attributes map[string]interface{}
+		wantRed    int
+	}{
+		{"Lower bound", map[string]interface{}{"POP": 0.0, "AREA": 1.0}, 0},
+		{"Class maximum is inclusive", map[string]interface{}{"POP": 100.0, "AREA": 10.0}, 0},
+		{"Second class", map[string]interface{}{"POP": 101.0, "AREA": 10.0}, 255},
+		{"Normalized into last class", map[string]interface{}{"POP": 5000.0, "AREA": 5.0}, 255},
+		{"Numeric string", map[string]interface{}{"POP": "50", "AREA": 1.0}, 255},
+		{"Above all classes", map[string]interface{}{"POP": 5000.0, "AREA": 1.0}, 200},
+		{"Below minimum", map[string]interface{}{"POP": -1.0, "AREA": 1.0}, 200},
+		{"Zero divisor", map[string]interface{}{"POP": 5.0, "AREA": 0.0}, 200},
+		{"Missing value", map[string]interface{}{"AREA": 1.0}, 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			symbol := renderer.SymbolFor(tt.attributes)
+			if symbol == nil || symbol.Color[0] != tt.wantRed {
+				t.Errorf("SymbolFor(%v) = %+v; want red %d", tt.attributes, symbol, tt.wantRed)
+			}
+		})
+	}
+
+	if info := renderer.ClassBreakFor(map[string]interface{}{"POP": 150.0, "AREA": 1.0}); info == nil || info.Label != "High" {
+		t.Errorf("ClassBreakFor = %+v; want High", info)
+	}
+}
+
+func TestVisualVariables(t *testing.T) {
+	data := `{
+		"type": "simple",
+		"symbol": {"type": "esriSMS", "style": "esriSMSCircle", "color": [0, 0, 0, 200], "size": 6,
+			"outline": {"type": "esriSLS", "color": [255, 255, 255, 255], "width": 1}},
+		"visualVariables": [
+			{"type": "colorInfo", "field": "T", "stops": [{"value": 0, "color": [0, 0, 255]}, {"value": 100, "color": [255, 0, 0]}]},
+			{"type": "sizeInfo", "field": "T", "minDataValue": 0, "maxDataValue": 100,
+				"minSize": 4, "maxSize": {"type": "sizeInfo", "expression": "view.scale", "stops": [{"value": 1000, "size": 24}]}},
+			{"type": "transparencyInfo", "field": "OPACITY", "stops": [{"value": 0, "transparency": 100}, {"value": 1, "transparency": 0}]},
+			{"type": "rotationInfo", "field": "HEADING"}
+		]
+	}`
+	var renderer Renderer
+	if err := json.Unmarshal([]byte(data), &renderer); err != nil {
+		t.Fatalf("Failed to decode renderer: %v", err)
+	}
+
+	symbol := renderer.SymbolFor(map[string]interface{}{"T": 25.0, "OPACITY": 0.5, "HEADING": 90.0})
+	if symbol == renderer.Symbol {
+		t.Fatal("Expected a modified copy of the symbol")
+	}
+	if want := []int{64, 0, 191, 128}; len(symbol.Color) != 4 || symbol.Color[0] != want[0] || symbol.Color[2] != want[2] || symbol.Color[3] != want[3] {
+		t.Errorf("Color = %v; want %v", symbol.Color, want)
+	}
+	if symbol.Size != 9 {
+		t.Errorf("Size = %g; want 9", symbol.Size)
+	}
+	if symbol.Outline.Color[3] != 128 || renderer.Symbol.Outline.Color[3] != 255 {
+		t.Errorf("Outline alpha = %d, original %d; want 128 and 255", symbol.Outline.Color[3], renderer.Symbol.Outline.Color[3])
+	}
+	if symbol.Angle != 270 {
+		t.Errorf("Angle = %g; want 270", symbol.Angle)
+	}
+
+	clamped := renderer.SymbolFor(map[string]interface{}{"T": 500.0})
+	if clamped.Size != 24 || clamped.Color[0] != 255 || clamped.Color[3] != 200 {
+		t.Errorf("Expected clamped size 24 and red with the symbol's alpha, got size %g color %v", clamped.Size, clamped.Color)
+	}
+	if unchanged := renderer.SymbolFor(map[string]interface{}{}); unchanged != renderer.Symbol {
+		t.Error("Expected the renderer's symbol when no visual variable applies")
+	}
+}
+
+func TestUniqueValueRenderer(t *testing.T) {
+	groups := `{
+		"type": "uniqueValue",
+		"field1": "TYPE",
+		"field2": "STATUS",
+		"fieldDelimiter": "|",
+		"defaultSymbol": {"type": "esriSMS", "size": 1},
+		"uniqueValueGroups": [{"classes": [
+			{"label": "Open school", "values": [["School", "1"]], "symbol": {"type": "esriSMS", "size": 2}},
+			{"label": "Closed", "values": [["School", "0"], ["Library", "0"]], "symbol": {"type": "esriSMS", "size": 3}},
+			{"label": "Unknown library", "values": [["Library|<Null>"]], "symbol": {"type": "esriSMS", "size": 4}}
+		]}]
+	}`
+	infos := `{
+		"type": "uniqueValue",
+		"field1": "TYPE",
+		"field2": "STATUS",
+		"fieldDelimiter": "|",
+		"defaultSymbol": {"type": "esriSMS", "size": 1},
+		"uniqueValueInfos": [
+			{"value": "School|1", "label": "Open school", "symbol": {"type": "esriSMS", "size": 2}},
+			{"value": "School|0", "label": "Closed", "symbol": {"type": "esriSMS", "size": 3}},
+			{"value": "Library|0", "label": "Closed", "symbol": {"type": "esriSMS", "size": 3}},
+			{"value": "Library|<Null>", "label": "Unknown library", "symbol": {"type": "esriSMS", "size": 4}}
+		]
+	}`
+
+	tests := []struct {
+		name       string
+		attributes map[string]interface{}
+		wantSize   float64
+		wantLabel  string
+	}{
+		{"Numeric second field", map[string]interface{}{"TYPE": "School", "STATUS": 1.0}, 2, "Open school"},
+		{"Second value set", map[string]interface{}{"TYPE": "Library", "STATUS": "0"}, 3, "Closed"},
+		{"Null value", map[string]interface{}{"TYPE": "Library", "STATUS": nil}, 4, "Unknown library"},
+		{"Default when unmatched", map[string]interface{}{"TYPE": "Park", "STATUS": 1.0}, 1, ""},
+	}
+	for name, data := range map[string]string{"uniqueValueGroups": groups, "uniqueValueInfos": infos} {
+		var renderer Renderer
+		if err := json.Unmarshal([]byte(data), &renderer); err != nil {
+			t.Fatalf("Failed to decode %s renderer: %v", name, err)
+		}
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				if symbol := renderer.SymbolFor(tt.attributes); symbol == nil || symbol.Size != tt.wantSize {
+					t.Errorf("SymbolFor(%v) = %+v; want size %g", tt.attributes, symbol, tt.wantSize)
+				}
+				label := ""
+				if class := renderer.UniqueValueClassFor(tt.attributes); class != nil {
+					label = class.Label
+				}
+				if label != tt.wantLabel {
+					t.Errorf("UniqueValueClassFor(%v) label = %q; want %q", tt.attributes, label, tt.wantLabel)
+				}
+			})
+		}
+	}
+}
+
+func TestClassLabelFor(t *testing.T) {
+	renderer := Renderer{
+		Type:         RendererUniqueValue,
+		Field1:       "KIND",
+		DefaultLabel: "Other",
+		UniqueValueInfos: []UniqueValueInfo{
+			{Value: "1", Label: "Wells"},
+			{Value: "2"},
+		},
+	}
+	tests := []struct {
+		kind interface{}
+		want string
+	}{
+		{1.0, "Wells"},
+		{2.0, "2"},
+		{3.0, "Other"},
+	}
+	for _, tt := range tests {
+		if got := renderer.ClassLabelFor(map[string]interface{}{"KIND": tt.kind}); got != tt.want {
+			t.Errorf("ClassLabelFor(%v) = %q; want %q", tt.kind, got, tt.want)
+		}
+	}
+}
+
+func TestLabelFor(t *testing.T) {
+	attributes := map[string]interface{}{"NAME": "Main St", "NUM": 12.0, "TYPE": 2.0, "NOTE": nil}
+	fields := []Field{{Name: "TYPE", Domain: &Domain{CodedValues: []CodedValue{{Name: "Avenue", Code: 2.0}}}}}
+	tests := []struct {
+		name           string
+		class          LabelClass
+		want           string
+		wantOK         bool
+		useCodedValues bool
+	}{
+		{"Legacy field", LabelClass{LabelExpression: "[NAME]"}, "Main St", true, false},
+		{"Legacy template", LabelClass{LabelExpression: "[NAME] ([NUM])"}, "Main St (12)", true, false},
+		{"Legacy concat", LabelClass{LabelExpression: `"No. " CONCAT [NUM] CONCAT " ""A"""`}, `No. 12 "A"`, true, false},
+		{"Legacy coded value", LabelClass{LabelExpression: "[TYPE]", UseCodedValues: true}, "Avenue", true, true},
+		{"Arcade field", LabelClass{LabelExpressionInfo: &LabelExpressionInfo{Expression: "$feature.NAME"}}, "Main St", true, false},
+		{"Arcade concatenation", LabelClass{LabelExpressionInfo: &LabelExpressionInfo{
+			Expression: `return $feature["NAME"] + ' #' + $feature.num + TextFormatting.NewLine + $feature.NOTE;`,
+		}}, "Main St #12\n", true, false},
+		{"Arcade template literal", LabelClass{LabelExpressionInfo: &LabelExpressionInfo{Expression: "`${$feature.NAME} - ${$feature.NUM}`"}}, "Main St - 12", true, false},
+		{"Arcade function", LabelClass{LabelExpressionInfo: &LabelExpressionInfo{Expression: "Upper($feature.NAME)"}}, "", false, false},
+		{"Arcade dangling operator", LabelClass{LabelExpressionInfo: &LabelExpressionInfo{Expression: "$feature.NAME +"}}, "", false, false},
+		{"No expression", LabelClass{}, "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.class.LabelFor(attributes, fields)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("LabelFor() = %q, %v; want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	classes := []LabelClass{{Where: "TYPE = 1", LabelExpression: "[NUM]"}, {LabelExpression: "[NAME]"}}
+	if class := DefaultLabelClass(classes); class != &classes[1] {
+		t.Errorf("DefaultLabelClass() = %v; want the class without a where clause", class)
+	}
+
+	// Keys differing only in case resolve the same way every time: to the layer's field,
+	// or to the first key in sorted order without one.
+	mixed := map[string]interface{}{"NAME": "upper", "Name": "title", "name": "lower"}
+	class := LabelClass{LabelExpression: "[nAmE]"}
+	for i := 0; i < 20; i++ {
+		if got, _ := class.LabelFor(mixed, []Field{{Name: "Name"}}); got != "title" {
+			t.Fatalf("LabelFor() with a Name field = %q, want %q", got, "title")
+		}
+		if got, _ := class.LabelFor(mixed, nil); got != "upper" {
+			t.Fatalf("LabelFor() without fields = %q, want %q", got, "upper")
+		}
+	}
+}
+
+func TestSortFeaturesByObjectID(t *testing.T) {
+	features := []Feature{
+		{Attributes: map[string]interface{}{"FID": 10.0, "NAME": "c"}},
+		{Attributes: map[string]interface{}{"NAME": "no id"}},
+		{Attributes: map[string]interface{}{"FID": 2.0, "NAME": "a"}},
+		{Attributes: map[string]interface{}{"FID": "x", "NAME": "text id"}},
+		{Attributes: map[string]interface{}{"FID": 3.0, "NAME": "b"}},
+	}
+	layer := &Layer{Fields: []Field{{Name: "NAME", Type: "esriFieldTypeString"}, {Name: "FID", Type: FieldTypeOID}}}
+	SortFeaturesByObjectID(features, layer.ObjectIDFieldName())
+
+	want := []string{"a", "b", "c", "no id", "text id"}
+	for i, feature := range features {
+		if feature.Attributes["NAME"] != want[i] {
+			t.Errorf("Feature %d = %v, want %s", i, feature.Attributes["NAME"], want[i])
+		}
+	}
+	if name := (&Layer{}).ObjectIDFieldName(); name != "" {
+		t.Errorf("ObjectIDFieldName() = %q, want empty", name)
+	}
+}
+
+func TestFetchFeatureCount(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Get("returnCountOnly") != "true":
+			http.Error(w, "Not found", http.StatusNotFound)
+		case r.URL.Path == "/0/query":
+			w.Write([]byte(`{"count": 1234}`))
+		default:
+			w.Write([]byte(`{"error": {"message": "Invalid layer"}}`))
+		}
+	}))
+	defer server.Close()
+	client := NewClient(5 * time.Second)
+
+	count, err := client.FetchFeatureCount(server.URL, "0")
+	if err != nil || count != 1234 {
+		t.Errorf("FetchFeatureCount() = %d, %v; want 1234", count, err)
+	}
+	if _, err := client.FetchFeatureCount(server.URL, "9"); err == nil {
+		t.Error("Expected an API error for an invalid layer")
+	}
+}
@@ -10,3 +10,51 @@ const (
 	ServiceMapServer     = "MapServer"
 )
 
+// Renderer constants
+const (
+	RendererSimple            = "simple"
+	RendererUniqueValue       = "uniqueValue"
+	RendererClassBreaks       = "classBreaks"
+	NormalizeByField          = "esriNormalizeByField"
+	NormalizeByLog            = "esriNormalizeByLog"
+	NormalizeByPercentOfTotal = "esriNormalizeByPercentOfTotal"
+	DefaultFieldDelimiter     = ","
+	NullValue                 = "<Null>"
+)
+
+// Visual variable constants
+const (
+	VisualVariableColorInfo        = "colorInfo"
+	VisualVariableSizeInfo         = "sizeInfo"
+	VisualVariableTransparencyInfo = "transparencyInfo"
+	VisualVariableRotationInfo     = "rotationInfo"
+	VisualVariableTargetOutline    = "outline"
+	RotationArithmetic             = "arithmetic"
+)
+
+// Symbol type constants
+const (
+	SymbolSimpleMarker  = "esriSMS"
+	SymbolSimpleLine    = "esriSLS"
+	SymbolSimpleFill    = "esriSFS"
+	SymbolPictureMarker = "esriPMS"
+)
+
+// Label expression constants
+const (
+	ArcadeNewLine = "TextFormatting.NewLine"
+	LegacyConcat  = "CONCAT"
+)
+
+// Feature ordering constants
+const (
+	DefaultObjectIDField = "OBJECTID"
+	FieldTypeOID         = "esriFieldTypeOID"
+)
+
+// Feature query constants
+const (
+	DefaultWhereClause = "1=1"
+	DefaultOutFields   = "*"
+	WGS84WKID          = 4326
+)
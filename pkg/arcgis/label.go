@@ -0,0 +1,357 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package arcgis provides functionality for interacting with ArcGIS REST services and ArcGIS Online.
+// It includes client implementations for fetching and processing data from various ArcGIS endpoints.
+package arcgis
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefaultLabelClass returns the label class used to name features: the first class
+// without a where clause, or the first class if every class is filtered.
+//
+// Parameters:
+//   - classes: The layer's label classes
+//
+// Returns:
+//   - *LabelClass: The selected label class, or nil if there are none
+func DefaultLabelClass(classes []LabelClass) *LabelClass {
+	for i := range classes {
+		if strings.TrimSpace(classes[i].Where) == "" {
+			return &classes[i]
+		}
+	}
+	if len(classes) > 0 {
+		return &classes[0]
+	}
+	return nil
+}
+
+// LabelFor evaluates the label class's expression for a feature.
+// The function handles:
+//   - Arcade expressions concatenating $feature.FIELD, $feature["FIELD"], string and
+//     number literals, template literals and TextFormatting.NewLine with + or &
+//   - Legacy expressions with [FIELD] references, quoted text and CONCAT, & or + operators
+//   - Coded value domain names instead of codes when UseCodedValues is set
+//
+// Expressions using other Arcade features, such as functions or variables, are not evaluated.
+//
+// Parameters:
+//   - attributes: The feature's attribute values
+//   - fields: The layer's fields, used for coded value domains; may be nil
+//
+// Returns:
+//   - string: The label text
+//   - bool: False if the class has no expression or the expression is not supported
+func (c *LabelClass) LabelFor(attributes map[string]interface{}, fields []Field) (string, bool) {
+	value := func(name string) string {
+		return labelValue(attributes, fields, name, c.UseCodedValues)
+	}
+	if c.LabelExpressionInfo != nil && strings.TrimSpace(c.LabelExpressionInfo.Expression) != "" {
+		return evaluateArcadeLabel(c.LabelExpressionInfo.Expression, value)
+	}
+	if strings.TrimSpace(c.LabelExpression) != "" {
+		return evaluateLegacyLabel(c.LabelExpression, value)
+	}
+	return "", false
+}
+
+// evaluateArcadeLabel evaluates a single Arcade return expression made of concatenated terms.
+func evaluateArcadeLabel(expression string, value func(name string) string) (string, bool) {
+	expr := strings.TrimSpace(expression)
+	expr = strings.TrimSpace(strings.TrimSuffix(expr, ";"))
+	if rest := strings.TrimPrefix(expr, "return"); rest != expr && (rest == "" || unicode.IsSpace(rune(rest[0]))) {
+		expr = strings.TrimSpace(rest)
+	}
+
+	var label strings.Builder
+	expectTerm := true
+	for i := 0; i < len(expr); {
+		ch := expr[i]
+		switch {
+		case unicode.IsSpace(rune(ch)):
+			i++
+			continue
+		case ch == '+' || ch == '&':
+			if expectTerm {
+				return "", false
+			}
+			expectTerm = true
+			i++
+			continue
+		}
+		if !expectTerm {
+			return "", false
+		}
+
+		switch {
+		case ch == '"' || ch == '\'':
+			text, n, ok := readQuoted(expr[i:])
+			if !ok {
+				return "", false
+			}
+			label.WriteString(text)
+			i += n
+		case ch == '`':
+			end := strings.IndexByte(expr[i+1:], '`')
+			if end < 0 {
+				return "", false
+			}
+			text, ok := evaluateTemplateLiteral(expr[i+1:i+1+end], value)
+			if !ok {
+				return "", false
+			}
+			label.WriteString(text)
+			i += end + 2
+		case strings.HasPrefix(expr[i:], "$feature"):
+			name, n, ok := readFeatureField(expr[i:])
+			if !ok {
+				return "", false
+			}
+			label.WriteString(value(name))
+			i += n
+		case strings.HasPrefix(expr[i:], ArcadeNewLine):
+			label.WriteString("\n")
+			i += len(ArcadeNewLine)
+		case ch == '-' || ch == '.' || (ch >= '0' && ch <= '9'):
+			n := 1
+			for n < len(expr[i:]) && (expr[i+n] == '.' || (expr[i+n] >= '0' && expr[i+n] <= '9')) {
+				n++
+			}
+			number, err := strconv.ParseFloat(expr[i:i+n], 64)
+			if err != nil {
+				return "", false
+			}
+			label.WriteString(strconv.FormatFloat(number, 'f', -1, 64))
+			i += n
+		default:
+			return "", false
+		}
+		expectTerm = false
+	}
+	if expectTerm {
+		return "", false
+	}
+	return label.String(), true
+}
+
+// evaluateTemplateLiteral expands ${...} placeholders of an Arcade template literal.
+func evaluateTemplateLiteral(template string, value func(name string) string) (string, bool) {
+	var text strings.Builder
+	for {
+		start := strings.Index(template, "${")
+		if start < 0 {
+			text.WriteString(template)
+			return text.String(), true
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			return "", false
+		}
+		text.WriteString(template[:start])
+		inner, ok := evaluateArcadeLabel(template[start+2:start+end], value)
+		if !ok {
+			return "", false
+		}
+		text.WriteString(inner)
+		template = template[start+end+1:]
+	}
+}
+
+// readFeatureField reads a $feature.NAME or $feature["NAME"] reference and returns the
+// field name and the number of bytes consumed.
+func readFeatureField(expr string) (string, int, bool) {
+	i := len("$feature")
+	switch {
+	case strings.HasPrefix(expr[i:], "."):
+		i++
+		start := i
+		for i < len(expr) && (expr[i] == '_' || unicode.IsLetter(rune(expr[i])) || unicode.IsDigit(rune(expr[i]))) {
+			i++
+		}
+		if i == start {
+			return "", 0, false
+		}
+		return expr[start:i], i, true
+	case strings.HasPrefix(expr[i:], "["):
+		i = skipSpaces(expr, i+1)
+		name, n, ok := readQuoted(expr[i:])
+		if !ok {
+			return "", 0, false
+		}
+		i = skipSpaces(expr, i+n)
+		if i >= len(expr) || expr[i] != ']' {
+			return "", 0, false
+		}
+		return name, i + 1, true
+	}
+	return "", 0, false
+}
+
+// skipSpaces returns the index of the first non-space byte of expr at or after i.
+func skipSpaces(expr string, i int) int {
+	for i < len(expr) && expr[i] == ' ' {
+		i++
+	}
+	return i
+}
+
+// readQuoted reads a single or double quoted string literal with backslash escapes and
+// returns its text and the number of bytes consumed.
+func readQuoted(expr string) (string, int, bool) {
+	if expr == "" || (expr[0] != '"' && expr[0] != '\'') {
+		return "", 0, false
+	}
+	quote := expr[0]
+	var text strings.Builder
+	for i := 1; i < len(expr); i++ {
+		switch expr[i] {
+		case '\\':
+			if i+1 >= len(expr) {
+				return "", 0, false
+			}
+			i++
+			switch expr[i] {
+			case 'n':
+				text.WriteByte('\n')
+			case 't':
+				text.WriteByte('\t')
+			default:
+				text.WriteByte(expr[i])
+			}
+		case quote:
+			return text.String(), i + 1, true
+		default:
+			text.WriteByte(expr[i])
+		}
+	}
+	return "", 0, false
+}
+
+// evaluateLegacyLabel evaluates a legacy label expression. Expressions without quoted
+// text or operators are templates: [FIELD] references are replaced and other text is kept.
+// Otherwise the expression is a concatenation of [FIELD] references and quoted strings.
+func evaluateLegacyLabel(expression string, value func(name string) string) (string, bool) {
+	expr := strings.TrimSpace(expression)
+	if !strings.ContainsAny(expr, "\"&+") && !strings.Contains(strings.ToUpper(expr), LegacyConcat) {
+		var label strings.Builder
+		for {
+			start := strings.IndexByte(expr, '[')
+			if start < 0 {
+				label.WriteString(expr)
+				return label.String(), true
+			}
+			end := strings.IndexByte(expr[start:], ']')
+			if end < 0 {
+				return "", false
+			}
+			label.WriteString(expr[:start])
+			label.WriteString(value(expr[start+1 : start+end]))
+			expr = expr[start+end+1:]
+		}
+	}
+
+	var label strings.Builder
+	for i := 0; i < len(expr); {
+		switch {
+		case unicode.IsSpace(rune(expr[i])) || expr[i] == '&' || expr[i] == '+':
+			i++
+		case strings.HasPrefix(strings.ToUpper(expr[i:]), LegacyConcat):
+			i += len(LegacyConcat)
+		case expr[i] == '[':
+			end := strings.IndexByte(expr[i:], ']')
+			if end < 0 {
+				return "", false
+			}
+			label.WriteString(value(expr[i+1 : i+end]))
+			i += end + 1
+		case expr[i] == '"':
+			// Legacy expressions escape quotes by doubling them.
+			end := i + 1
+			var text strings.Builder
+			for ; end < len(expr); end++ {
+				if expr[end] == '"' {
+					if end+1 < len(expr) && expr[end+1] == '"' {
+						text.WriteByte('"')
+						end++
+						continue
+					}
+					break
+				}
+				text.WriteByte(expr[end])
+			}
+			if end >= len(expr) {
+				return "", false
+			}
+			label.WriteString(text.String())
+			i = end + 1
+		default:
+			return "", false
+		}
+	}
+	return label.String(), true
+}
+
+// labelValue formats a feature's field value for a label, matching the field name without
+// regard to case as Arcade does. Null values are empty and, when useCodedValues is set,
+// codes of coded value domains are replaced by their names.
+func labelValue(attributes map[string]interface{}, fields []Field, name string, useCodedValues bool) string {
+	name = strings.TrimSpace(name)
+	value, ok := attributes[name]
+	if !ok {
+		name, value = matchAttribute(attributes, fields, name)
+	}
+	if value == nil {
+		return ""
+	}
+	text := uniqueValueString(value)
+	if useCodedValues {
+		for _, field := range fields {
+			if field.Name != name || field.Domain == nil {
+				continue
+			}
+			for _, cv := range field.Domain.CodedValues {
+				if uniqueValueString(cv.Code) == text {
+					return cv.Name
+				}
+			}
+		}
+	}
+	return text
+}
+
+// matchAttribute finds an attribute by name without regard to case. The layer's fields
+// are tried first, in field order, then the attribute keys in sorted order, so that keys
+// differing only in case always resolve to the same attribute.
+func matchAttribute(attributes map[string]interface{}, fields []Field, name string) (string, interface{}) {
+	for _, field := range fields {
+		if value, ok := attributes[field.Name]; ok && strings.EqualFold(field.Name, name) {
+			return field.Name, value
+		}
+	}
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if strings.EqualFold(key, name) {
+			return key, attributes[key]
+		}
+	}
+	return name, nil
+}
+
+// String returns a short description of the label class's expression.
+func (c *LabelClass) String() string {
+	if c.LabelExpressionInfo != nil && c.LabelExpressionInfo.Expression != "" {
+		return fmt.Sprintf("Arcade %q", c.LabelExpressionInfo.Expression)
+	}
+	return fmt.Sprintf("%q", c.LabelExpression)
+}
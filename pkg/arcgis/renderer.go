@@ -0,0 +1,460 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package arcgis provides functionality for interacting with ArcGIS REST services and ArcGIS Online.
+// It includes client implementations for fetching and processing data from various ArcGIS endpoints.
+package arcgis
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// VisualVariableSize is a size in points used by size visual variables. ArcGIS encodes
+// it either as a number or as a scale-dependent object with its own stops, in which case
+// the size of the first stop is used.
+type VisualVariableSize float64
+
+// UnmarshalJSON decodes a size given as a number or as a scale-dependent size object.
+func (s *VisualVariableSize) UnmarshalJSON(data []byte) error {
+	var size float64
+	if err := json.Unmarshal(data, &size); err == nil {
+		*s = VisualVariableSize(size)
+		return nil
+	}
+	var scaled struct {
+		Stops []VisualVariableStop `json:"stops"`
+	}
+	if err := json.Unmarshal(data, &scaled); err != nil {
+		return err
+	}
+	if len(scaled.Stops) > 0 {
+		*s = VisualVariableSize(scaled.Stops[0].Size)
+	}
+	return nil
+}
+
+// SymbolFor resolves the symbol a renderer draws for a feature.
+// The function handles:
+//   - Simple renderers, which use the same symbol for every feature
+//   - Class breaks renderers, matching the normalized field value against the class ranges
+//   - Unique value renderers, matching the values of up to three fields against the classes
+//   - Falling back to the default symbol when no class matches
+//   - Visual variables adjusting color, size, transparency and rotation of the symbol
+//
+// When visual variables apply, a modified copy is returned and the renderer's symbols
+// are left unchanged.
+//
+// Parameters:
+//   - attributes: The feature's attribute values
+//
+// Returns:
+//   - *Symbol: The symbol for the feature, or nil if the renderer draws none
+func (r *Renderer) SymbolFor(attributes map[string]interface{}) *Symbol {
+	var symbol *Symbol
+	switch r.Type {
+	case RendererSimple:
+		symbol = r.Symbol
+	case RendererClassBreaks:
+		if info := r.ClassBreakFor(attributes); info != nil {
+			symbol = info.Symbol
+		}
+	case RendererUniqueValue:
+		if class := r.UniqueValueClassFor(attributes); class != nil {
+			symbol = class.Symbol
+		}
+	}
+	if symbol == nil {
+		symbol = r.DefaultSymbol
+	}
+	return r.ApplyVisualVariables(symbol, attributes)
+}
+
+// UniqueValueClassFor returns the class of a unique value renderer that a feature belongs to.
+// The function handles:
+//   - Keys made of Field1, Field2 and Field3, joined by FieldDelimiter
+//   - Classes listed in uniqueValueGroups, with one value per field in each value set
+//   - Classes listed in uniqueValueInfos, whose value is the delimited key
+//   - Null attribute values, which match the "<Null>" value
+//
+// Classes from uniqueValueInfos are returned as a UniqueValueClass with a single value set.
+//
+// Parameters:
+//   - attributes: The feature's attribute values
+//
+// Returns:
+//   - *UniqueValueClass: The matching class, or nil if no class matches
+func (r *Renderer) UniqueValueClassFor(attributes map[string]interface{}) *UniqueValueClass {
+	var values []string
+	for _, field := range []string{r.Field1, r.Field2, r.Field3} {
+		if field == "" {
+			break
+		}
+		values = append(values, uniqueValueString(attributes[field]))
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	delimiter := r.FieldDelimiter
+	if delimiter == "" {
+		delimiter = DefaultFieldDelimiter
+	}
+	key := strings.Join(values, delimiter)
+
+	for g := range r.UniqueValueGroups {
+		for c := range r.UniqueValueGroups[g].Classes {
+			class := &r.UniqueValueGroups[g].Classes[c]
+			for _, valueSet := range class.Values {
+				if valueSetMatches(valueSet, values, key) {
+					return class
+				}
+			}
+		}
+	}
+	for _, info := range r.UniqueValueInfos {
+		if info.Value == key {
+			return &UniqueValueClass{
+				Label:       info.Label,
+				Description: info.Description,
+				Values:      [][]string{values},
+				Symbol:      info.Symbol,
+			}
+		}
+	}
+	return nil
+}
+
+// ClassLabelFor returns the legend label of the class a feature is drawn with.
+// Unique value classes without a label are named after their values, and features
+// matching no class get the renderer's default label.
+//
+// Parameters:
+//   - attributes: The feature's attribute values
+//
+// Returns:
+//   - string: The class label, or "" if the renderer has none for the feature
+func (r *Renderer) ClassLabelFor(attributes map[string]interface{}) string {
+	switch r.Type {
+	case RendererSimple:
+		return r.Label
+	case RendererClassBreaks:
+		if info := r.ClassBreakFor(attributes); info != nil {
+			return info.Label
+		}
+	case RendererUniqueValue:
+		if class := r.UniqueValueClassFor(attributes); class != nil {
+			if class.Label == "" && len(class.Values) > 0 {
+				delimiter := r.FieldDelimiter
+				if delimiter == "" {
+					delimiter = DefaultFieldDelimiter
+				}
+				return strings.Join(class.Values[0], delimiter)
+			}
+			return class.Label
+		}
+	}
+	return r.DefaultLabel
+}
+
+// valueSetMatches reports whether a class value set matches the feature's field values.
+// A value set holds one value per field; a single value may also be the delimited key.
+func valueSetMatches(valueSet, values []string, key string) bool {
+	if len(valueSet) == 1 {
+		return valueSet[0] == key
+	}
+	if len(valueSet) != len(values) {
+		return false
+	}
+	for i := range values {
+		if valueSet[i] != values[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// uniqueValueString formats an attribute value the way unique value renderers list it:
+// numbers without exponents or trailing zeros and null as "<Null>".
+func uniqueValueString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return NullValue
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// ClassBreakFor returns the class of a class breaks renderer that a feature falls in.
+// The value of Field is normalized by field, logarithm or percent of total as configured;
+// the first class is bounded below by MinValue and every other class by the previous maximum.
+//
+// Parameters:
+//   - attributes: The feature's attribute values
+//
+// Returns:
+//   - *ClassBreakInfo: The matching class, or nil if the value is missing or outside all classes
+func (r *Renderer) ClassBreakFor(attributes map[string]interface{}) *ClassBreakInfo {
+	value, ok := numericAttribute(attributes, r.Field)
+	if !ok {
+		return nil
+	}
+	switch r.NormalizationType {
+	case NormalizeByField:
+		divisor, ok := numericAttribute(attributes, r.NormalizationField)
+		if !ok || divisor == 0 {
+			return nil
+		}
+		value /= divisor
+	case NormalizeByLog:
+		if value <= 0 {
+			return nil
+		}
+		value = math.Log10(value)
+	case NormalizeByPercentOfTotal:
+		if r.NormalizationTotal == 0 {
+			return nil
+		}
+		value = value / r.NormalizationTotal * 100
+	}
+
+	for i := range r.ClassBreakInfos {
+		info := &r.ClassBreakInfos[i]
+		switch {
+		case info.ClassMinValue != nil:
+			if value < *info.ClassMinValue {
+				continue
+			}
+		case i == 0:
+			if r.MinValue != nil && value < *r.MinValue {
+				continue
+			}
+		default:
+			if value <= r.ClassBreakInfos[i-1].ClassMaxValue {
+				continue
+			}
+		}
+		if value <= info.ClassMaxValue {
+			return info
+		}
+	}
+	return nil
+}
+
+// ApplyVisualVariables returns the symbol adjusted by the renderer's visual variables.
+// Variables driven by an Arcade valueExpression are not evaluated, and variables whose
+// value is missing for the feature leave the symbol unchanged.
+//
+// Parameters:
+//   - symbol: The class or default symbol for the feature
+//   - attributes: The feature's attribute values
+//
+// Returns:
+//   - *Symbol: The symbol itself when nothing applies, otherwise an adjusted copy
+func (r *Renderer) ApplyVisualVariables(symbol *Symbol, attributes map[string]interface{}) *Symbol {
+	if symbol == nil || len(r.VisualVariables) == 0 {
+		return symbol
+	}
+	result := symbol
+	mutable := func() *Symbol {
+		if result == symbol {
+			result = symbol.clone()
+		}
+		return result
+	}
+
+	for _, vv := range r.VisualVariables {
+		value, ok := numericAttribute(attributes, vv.Field)
+		if !ok {
+			continue
+		}
+		if vv.NormalizationField != "" {
+			divisor, ok := numericAttribute(attributes, vv.NormalizationField)
+			if !ok || divisor == 0 {
+				continue
+			}
+			value /= divisor
+		}
+
+		switch vv.Type {
+		case VisualVariableColorInfo:
+			// Picture markers have no color to replace.
+			if symbol.Type != SymbolSimpleLine && symbol.Type != SymbolSimpleMarker && symbol.Type != SymbolSimpleFill {
+				continue
+			}
+			if color := interpolateColor(vv.Stops, value); color != nil {
+				s := mutable()
+				// Keep the symbol's own transparency when the stop has none.
+				if len(color) < 4 && len(s.Color) > 3 {
+					color = append(color, s.Color[3])
+				}
+				s.Color = color
+			}
+		case VisualVariableSizeInfo:
+			size, ok := vv.sizeFor(value)
+			if !ok {
+				continue
+			}
+			s := mutable()
+			switch {
+			case vv.Target == VisualVariableTargetOutline:
+				if s.Outline != nil {
+					s.Outline.Width = size
+				}
+			case s.Type == SymbolSimpleMarker:
+				s.Size = size
+			case s.Type == SymbolSimpleLine:
+				s.Width = size
+			case s.Type == SymbolPictureMarker && s.Width > 0 && s.Height > 0:
+				// Scale picture markers by their larger side, keeping the aspect ratio.
+				factor := size / math.Max(s.Width, s.Height)
+				s.Width *= factor
+				s.Height *= factor
+			}
+		case VisualVariableTransparencyInfo:
+			transparency, ok := interpolateStops(vv.Stops, value, func(stop VisualVariableStop) float64 { return stop.Transparency })
+			if !ok {
+				continue
+			}
+			alpha := int(math.Round(255 * (1 - math.Max(0, math.Min(100, transparency))/100)))
+			s := mutable()
+			s.Color = withAlpha(s.Color, alpha)
+			if s.Outline != nil {
+				s.Outline.Color = withAlpha(s.Outline.Color, alpha)
+			}
+		case VisualVariableRotationInfo:
+			// Symbol angles are counter-clockwise from the symbol's upright position.
+			angle := -value
+			if vv.RotationType == RotationArithmetic {
+				angle = value - 90
+			}
+			mutable().Angle = math.Mod(math.Mod(angle, 360)+360, 360)
+		}
+	}
+	return result
+}
+
+// sizeFor maps a data value to a size in points using the stops, or linearly between the
+// minimum and maximum data values when no stops are given. Values are clamped to the range.
+func (vv VisualVariable) sizeFor(value float64) (float64, bool) {
+	if len(vv.Stops) > 0 {
+		return interpolateStops(vv.Stops, value, func(stop VisualVariableStop) float64 { return stop.Size })
+	}
+	if vv.MinDataValue == nil || vv.MaxDataValue == nil || vv.MinSize == nil || vv.MaxSize == nil {
+		return 0, false
+	}
+	minData, maxData := *vv.MinDataValue, *vv.MaxDataValue
+	minSize, maxSize := float64(*vv.MinSize), float64(*vv.MaxSize)
+	if maxData <= minData || value <= minData {
+		return minSize, true
+	}
+	if value >= maxData {
+		return maxSize, true
+	}
+	return minSize + (value-minData)/(maxData-minData)*(maxSize-minSize), true
+}
+
+// interpolateStops linearly interpolates a stop property at value, clamping to the first
+// and last stop. Stops are expected in ascending value order.
+func interpolateStops(stops []VisualVariableStop, value float64, property func(VisualVariableStop) float64) (float64, bool) {
+	if len(stops) == 0 {
+		return 0, false
+	}
+	if value <= stops[0].Value {
+		return property(stops[0]), true
+	}
+	for i := 1; i < len(stops); i++ {
+		if value <= stops[i].Value {
+			lower, upper := stops[i-1], stops[i]
+			if upper.Value == lower.Value {
+				return property(upper), true
+			}
+			t := (value - lower.Value) / (upper.Value - lower.Value)
+			return property(lower) + t*(property(upper)-property(lower)), true
+		}
+	}
+	return property(stops[len(stops)-1]), true
+}
+
+// interpolateColor interpolates the color stops at value component by component.
+// Returns nil if there are no stops with colors.
+func interpolateColor(stops []VisualVariableStop, value float64) []int {
+	var colored []VisualVariableStop
+	for _, stop := range stops {
+		if len(stop.Color) >= 3 {
+			colored = append(colored, stop)
+		}
+	}
+	if len(colored) == 0 {
+		return nil
+	}
+	components := len(colored[0].Color)
+	for _, stop := range colored {
+		if len(stop.Color) < components {
+			components = len(stop.Color)
+		}
+	}
+	color := make([]int, components)
+	for c := range color {
+		component, _ := interpolateStops(colored, value, func(stop VisualVariableStop) float64 { return float64(stop.Color[c]) })
+		color[c] = int(math.Round(component))
+	}
+	return color
+}
+
+// withAlpha returns a copy of an [r, g, b, a] color with its alpha replaced; nil stays nil.
+func withAlpha(color []int, alpha int) []int {
+	if len(color) < 3 {
+		return color
+	}
+	return []int{color[0], color[1], color[2], alpha}
+}
+
+// clone returns a copy of the symbol that shares no color or outline with the original.
+func (s *Symbol) clone() *Symbol {
+	c := *s
+	if s.Color != nil {
+		c.Color = append([]int(nil), s.Color...)
+	}
+	if s.Outline != nil {
+		c.Outline = s.Outline.clone()
+	}
+	return &c
+}
+
+// numericAttribute returns an attribute as a number, accepting numeric strings.
+// Returns false if the field is empty, missing, null or not numeric.
+func numericAttribute(attributes map[string]interface{}, field string) (float64, bool) {
+	if field == "" {
+		return 0, false
+	}
+	switch v := attributes[field].(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
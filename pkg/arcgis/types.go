@@ -28,39 +28,203 @@ type Layer struct {
 	GeometryType string       `json:"geometryType"`
 	Description  string       `json:"description"`
 	DrawingInfo  *DrawingInfo `json:"drawingInfo"`
-	Error        *struct {
+	// Fields, ObjectIDField and DisplayField describe the layer's attribute schema.
+	Fields        []Field `json:"fields"`
+	ObjectIDField string  `json:"objectIdField"`
+	DisplayField  string  `json:"displayField"`
+	// TimeInfo is set for time-aware layers.
+	TimeInfo *TimeInfo `json:"timeInfo,omitempty"`
+	// Extent is the bounding box of the layer's features in its spatial reference.
+	Extent *Extent `json:"extent,omitempty"`
+	Error  *struct {
 		Message string `json:"message"`
 	} `json:"error"`
 }
 
+// TimeInfo represents the time settings of a time-aware layer.
+// Features are valid from the start time field's value to the end time field's value;
+// layers recording instants have no end time field.
+type TimeInfo struct {
+	StartTimeField string `json:"startTimeField"`
+	EndTimeField   string `json:"endTimeField,omitempty"`
+	TrackIDField   string `json:"trackIdField,omitempty"`
+	// TimeExtent holds the layer's first and last time in epoch milliseconds.
+	TimeExtent []float64 `json:"timeExtent,omitempty"`
+}
+
+// Extent represents a bounding box with the spatial reference of its coordinates.
+type Extent struct {
+	XMin             float64           `json:"xmin"`
+	YMin             float64           `json:"ymin"`
+	XMax             float64           `json:"xmax"`
+	YMax             float64           `json:"ymax"`
+	SpatialReference *SpatialReference `json:"spatialReference,omitempty"`
+}
+
+// Field represents an attribute field of a layer.
+// It contains the field's name, Esri field type, alias, length and optional domain.
+type Field struct {
+	Name   string  `json:"name"`
+	Type   string  `json:"type"`
+	Alias  string  `json:"alias,omitempty"`
+	Length int     `json:"length,omitempty"`
+	Domain *Domain `json:"domain,omitempty"`
+}
+
+// Domain represents an attribute domain of a field.
+// Coded value domains list allowed codes with their names; range domains hold a minimum and maximum.
+type Domain struct {
+	Type        string       `json:"type"`
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	CodedValues []CodedValue `json:"codedValues,omitempty"`
+	Range       []float64    `json:"range,omitempty"`
+}
+
+// CodedValue represents a single entry of a coded value domain.
+type CodedValue struct {
+	Name string      `json:"name"`
+	Code interface{} `json:"code"`
+}
+
+// SpatialReference represents the spatial reference of geometries.
+// It identifies the coordinate system by well-known ID or, for custom systems, WKT.
+type SpatialReference struct {
+	WKID       int    `json:"wkid,omitempty"`
+	LatestWKID int    `json:"latestWkid,omitempty"`
+	WKT        string `json:"wkt,omitempty"`
+}
+
 // DrawingInfo represents drawing information for a layer.
 // It contains the renderer configuration for visualizing the layer's features.
 type DrawingInfo struct {
 	Renderer *Renderer `json:"renderer"`
+	// LabelingInfo holds the layer's label classes.
+	LabelingInfo []LabelClass `json:"labelingInfo,omitempty"`
+}
+
+// LabelClass represents a label class of a layer.
+// The label text comes from an Arcade expression in LabelExpressionInfo or, on older
+// servers, from a LabelExpression with [FIELD] references; Symbol is the text symbol.
+type LabelClass struct {
+	LabelExpression     string               `json:"labelExpression,omitempty"`
+	LabelExpressionInfo *LabelExpressionInfo `json:"labelExpressionInfo,omitempty"`
+	UseCodedValues      bool                 `json:"useCodedValues,omitempty"`
+	Where               string               `json:"where,omitempty"`
+	LabelPlacement      string               `json:"labelPlacement,omitempty"`
+	Symbol              *Symbol              `json:"symbol,omitempty"`
+}
+
+// LabelExpressionInfo holds an Arcade label expression.
+type LabelExpressionInfo struct {
+	Expression string `json:"expression"`
 }
 
 // Renderer represents the renderer for a layer.
 // It defines how features should be displayed, including symbols and value-based styling.
+// Simple renderers use Symbol for every feature, class breaks renderers pick the symbol
+// of the numeric range the (optionally normalized) Field value falls in, and visual
+// variables adjust color, size, transparency and rotation per feature.
 type Renderer struct {
 	Type              string             `json:"type"`
 	Field1            string             `json:"field1"`
 	DefaultSymbol     *Symbol            `json:"defaultSymbol"`
 	DefaultLabel      string             `json:"defaultLabel"`
 	UniqueValueGroups []UniqueValueGroup `json:"uniqueValueGroups"`
+	// Field2, Field3 and FieldDelimiter extend unique value keys to several fields;
+	// UniqueValueInfos is the flat class list used by older servers instead of groups.
+	Field2           string            `json:"field2,omitempty"`
+	Field3           string            `json:"field3,omitempty"`
+	FieldDelimiter   string            `json:"fieldDelimiter,omitempty"`
+	UniqueValueInfos []UniqueValueInfo `json:"uniqueValueInfos,omitempty"`
+	// Symbol and Label are used by simple renderers.
+	Symbol *Symbol `json:"symbol,omitempty"`
+	Label  string  `json:"label,omitempty"`
+	// Field, MinValue, normalization and ClassBreakInfos are used by class breaks renderers.
+	Field                string           `json:"field,omitempty"`
+	ClassificationMethod string           `json:"classificationMethod,omitempty"`
+	NormalizationType    string           `json:"normalizationType,omitempty"`
+	NormalizationField   string           `json:"normalizationField,omitempty"`
+	NormalizationTotal   float64          `json:"normalizationTotal,omitempty"`
+	MinValue             *float64         `json:"minValue,omitempty"`
+	ClassBreakInfos      []ClassBreakInfo `json:"classBreakInfos,omitempty"`
+	VisualVariables      []VisualVariable `json:"visualVariables,omitempty"`
+}
+
+// ClassBreakInfo represents one class of a class breaks renderer.
+// A class covers values above the previous class's maximum (or from ClassMinValue
+// when given) up to and including ClassMaxValue.
+type ClassBreakInfo struct {
+	ClassMinValue *float64 `json:"classMinValue,omitempty"`
+	ClassMaxValue float64  `json:"classMaxValue"`
+	Label         string   `json:"label"`
+	Description   string   `json:"description"`
+	Symbol        *Symbol  `json:"symbol"`
+}
+
+// VisualVariable represents a data-driven color, size, transparency or rotation ramp.
+// Values are read from Field, optionally divided by NormalizationField, and mapped
+// through Stops or, for size without stops, the data and size ranges.
+type VisualVariable struct {
+	Type               string               `json:"type"`
+	Field              string               `json:"field,omitempty"`
+	NormalizationField string               `json:"normalizationField,omitempty"`
+	ValueExpression    string               `json:"valueExpression,omitempty"`
+	Stops              []VisualVariableStop `json:"stops,omitempty"`
+	MinDataValue       *float64             `json:"minDataValue,omitempty"`
+	MaxDataValue       *float64             `json:"maxDataValue,omitempty"`
+	MinSize            *VisualVariableSize  `json:"minSize,omitempty"`
+	MaxSize            *VisualVariableSize  `json:"maxSize,omitempty"`
+	// Target is "outline" when a size variable applies to polygon outlines.
+	Target string `json:"target,omitempty"`
+	// RotationType is "geographic" (clockwise from north, the default) or "arithmetic".
+	RotationType string `json:"rotationType,omitempty"`
+}
+
+// VisualVariableStop represents a data value and the color, size or transparency it maps to.
+type VisualVariableStop struct {
+	Value        float64 `json:"value"`
+	Color        []int   `json:"color,omitempty"`
+	Size         float64 `json:"size,omitempty"`
+	Transparency float64 `json:"transparency,omitempty"`
+	Label        string  `json:"label,omitempty"`
 }
 
 // Symbol represents a symbol used for rendering features.
-// It defines the visual appearance of features, including images, sizes, and offsets.
+// It defines the visual appearance of features, including images, sizes, offsets,
+// colors and outlines of picture and simple (esriSMS, esriSLS, esriSFS) symbols,
+// and the font and halo of text symbols (esriTS).
 type Symbol struct {
 	Type        string  `json:"type"`
 	URL         string  `json:"url"`
 	ImageData   string  `json:"imageData"`
 	ContentType string  `json:"contentType"`
-	Width       int     `json:"width"`
-	Height      int     `json:"height"`
-	XOffset     int     `json:"xoffset"`
-	YOffset     int     `json:"yoffset"`
+	Width       float64 `json:"width"`
+	Height      float64 `json:"height"`
+	XOffset     float64 `json:"xoffset"`
+	YOffset     float64 `json:"yoffset"`
 	Angle       float64 `json:"angle"`
+	// Color is an [r, g, b, a] array with components from 0 to 255; nil means no color.
+	Color []int `json:"color,omitempty"`
+	// Size is the marker size in points (esriSMS).
+	Size float64 `json:"size,omitempty"`
+	// Style is the marker shape, line dash or fill pattern, e.g. esriSMSCircle,
+	// esriSLSDash or esriSFSSolid.
+	Style string `json:"style,omitempty"`
+	// Outline is the line symbol drawn around markers and fills.
+	Outline *Symbol `json:"outline,omitempty"`
+	// Font, HaloColor and HaloSize describe text symbols (esriTS).
+	Font      *Font   `json:"font,omitempty"`
+	HaloColor []int   `json:"haloColor,omitempty"`
+	HaloSize  float64 `json:"haloSize,omitempty"`
+}
+
+// Font represents the font of a text symbol. Size is given in points.
+type Font struct {
+	Family string  `json:"family,omitempty"`
+	Size   float64 `json:"size,omitempty"`
+	Style  string  `json:"style,omitempty"`
+	Weight string  `json:"weight,omitempty"`
 }
 
 // UniqueValueGroup represents a group of unique values for rendering.
@@ -79,6 +243,15 @@ type UniqueValueClass struct {
 	Symbol      *Symbol    `json:"symbol"`
 }
 
+// UniqueValueInfo represents a class of a unique value renderer in the flat encoding.
+// For multi-field renderers, Value joins the field values with the renderer's FieldDelimiter.
+type UniqueValueInfo struct {
+	Value       string  `json:"value"`
+	Label       string  `json:"label"`
+	Description string  `json:"description"`
+	Symbol      *Symbol `json:"symbol"`
+}
+
 // FeatureResponse represents the response from a feature query.
 // It contains the requested features and any transfer limit information.
 type FeatureResponse struct {
@@ -89,6 +262,14 @@ type FeatureResponse struct {
 	} `json:"error"`
 }
 
+// QueryOptions holds the options of a feature query. Zero values select all features,
+// all fields and WGS84 geometries.
+type QueryOptions struct {
+	Where     string   // SQL where clause filtering the features
+	OutFields []string // Fields to return
+	OutSR     int      // Well-known ID of the spatial reference of returned geometries
+}
+
 // Feature represents a geographic feature with attributes and geometry.
 // It contains the feature's properties and spatial data.
 type Feature struct {
@@ -5,10 +5,14 @@
 package convert
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/Sudo-Ivan/arcgis-utils/pkg/arcgis"
@@ -17,8 +21,9 @@ import (
 // ToGeoJSON converts a slice of Feature structs to a GeoJSON FeatureCollection.
 // It handles:
 //   - Point geometries (x,y coordinates)
-//   - LineString geometries (paths)
-//   - Polygon geometries (rings)
+//   - MultiPoint geometries (points)
+//   - LineString and MultiLineString geometries (one or more paths)
+//   - Polygon and MultiPolygon geometries (rings grouped into outer rings and holes)
 //   - Feature attributes and properties
 //   - Symbol information
 //
@@ -49,69 +54,7 @@ func ToGeoJSON(features []Feature) (*GeoJSON, error) {
 
 		var geoJSONFeature GeoJSONFeature
 		if geometry != nil {
-			if xVal, xOk := geometry["x"]; xOk {
-				if yVal, yOk := geometry["y"]; yOk {
-					x, xFloatOk := xVal.(float64)
-					y, yFloatOk := yVal.(float64)
-					if xFloatOk && yFloatOk {
-						geoJSONFeature.Geometry = map[string]interface{}{
-							"type":        "Point",
-							"coordinates": []float64{x, y},
-						}
-					}
-				}
-			} else if paths, ok := geometry["paths"]; ok {
-				pathArray, pathArrayOk := paths.([]interface{})
-				if pathArrayOk && len(pathArray) > 0 {
-					firstPath, firstPathOk := pathArray[0].([]interface{})
-					if firstPathOk {
-						coords := [][]float64{}
-						for _, p := range firstPath {
-							point, pointOk := p.([]interface{})
-							if pointOk && len(point) >= 2 {
-								x, xOk := point[0].(float64)
-								y, yOk := point[1].(float64)
-								if xOk && yOk {
-									coords = append(coords, []float64{x, y})
-								}
-							}
-						}
-						geoJSONFeature.Geometry = map[string]interface{}{
-							"type":        "LineString",
-							"coordinates": coords,
-						}
-					}
-				}
-			} else if rings, ok := geometry["rings"]; ok {
-				ringArray, ringArrayOk := rings.([]interface{})
-				if ringArrayOk && len(ringArray) > 0 {
-					allRings := [][][]float64{}
-					for _, r := range ringArray {
-						ringCoords, ringCoordsOk := r.([]interface{})
-						if ringCoordsOk {
-							singleRing := [][]float64{}
-							for _, p := range ringCoords {
-								point, pointOk := p.([]interface{})
-								if pointOk && len(point) >= 2 {
-									x, xOk := point[0].(float64)
-									y, yOk := point[1].(float64)
-									if xOk && yOk {
-										singleRing = append(singleRing, []float64{x, y})
-									}
-								}
-							}
-							if len(singleRing) > 0 && (singleRing[0][0] != singleRing[len(singleRing)-1][0] || singleRing[0][1] != singleRing[len(singleRing)-1][1]) {
-								singleRing = append(singleRing, singleRing[0])
-							}
-							allRings = append(allRings, singleRing)
-						}
-					}
-					geoJSONFeature.Geometry = map[string]interface{}{
-						"type":        "Polygon",
-						"coordinates": allRings,
-					}
-				}
-			}
+			geoJSONFeature.Geometry = esriGeometryToGeoJSON(geometry)
 		}
 
 		if geoJSONFeature.Geometry != nil {
@@ -122,19 +65,7 @@ func ToGeoJSON(features []Feature) (*GeoJSON, error) {
 			if symbolData, ok := feature.Attributes["symbol"]; ok {
 				// Attempt to cast the attribute value to *arcgis.Symbol
 				if arcSymbol, castOk := symbolData.(*arcgis.Symbol); castOk && arcSymbol != nil {
-					// Create a convert.Symbol and copy fields
-					symbol := &Symbol{
-						Type:        arcSymbol.Type,
-						URL:         arcSymbol.URL,
-						ImageData:   arcSymbol.ImageData,
-						ContentType: arcSymbol.ContentType,
-						Width:       arcSymbol.Width,
-						Height:      arcSymbol.Height,
-						XOffset:     arcSymbol.XOffset,
-						YOffset:     arcSymbol.YOffset,
-						Angle:       arcSymbol.Angle,
-					}
-					geoJSONFeature.Symbol = symbol
+					geoJSONFeature.Symbol = symbolFromArcGIS(arcSymbol)
 				}
 			}
 
@@ -145,6 +76,296 @@ func ToGeoJSON(features []Feature) (*GeoJSON, error) {
 	return &geoJSON, nil
 }
 
+// FromGeoJSON converts a GeoJSON document to features with Esri JSON geometries, the
+// reverse of ToGeoJSON, so that local GeoJSON files can be exported like downloaded layers.
+// It handles:
+//   - FeatureCollection and single Feature documents
+//   - Point, MultiPoint, LineString, MultiLineString, Polygon and MultiPolygon geometries
+//   - Polygon rings reoriented to Esri's clockwise outer rings and counter-clockwise holes
+//
+// Features with other or missing geometries are kept without a geometry.
+//
+// Parameters:
+//   - data: GeoJSON document
+//
+// Returns:
+//   - []Feature: Features with the GeoJSON properties as attributes
+//   - error: Any error that occurred while parsing the document
+func FromGeoJSON(data []byte) ([]Feature, error) {
+	type geoJSONFeature struct {
+		Properties map[string]interface{} `json:"properties"`
+		Geometry   map[string]interface{} `json:"geometry"`
+	}
+	var doc struct {
+		Type     string           `json:"type"`
+		Features []geoJSONFeature `json:"features"`
+		geoJSONFeature
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse GeoJSON: %v", err)
+	}
+
+	var geoJSONFeatures []geoJSONFeature
+	switch doc.Type {
+	case "FeatureCollection":
+		geoJSONFeatures = doc.Features
+	case "Feature":
+		geoJSONFeatures = []geoJSONFeature{doc.geoJSONFeature}
+	default:
+		return nil, fmt.Errorf("unsupported GeoJSON type: %q", doc.Type)
+	}
+
+	features := make([]Feature, 0, len(geoJSONFeatures))
+	for _, f := range geoJSONFeatures {
+		attributes := f.Properties
+		if attributes == nil {
+			attributes = make(map[string]interface{})
+		}
+		feature := Feature{Attributes: attributes}
+		if f.Geometry != nil {
+			if geometry := geoJSONGeometryToEsri(f.Geometry); geometry != nil {
+				feature.Geometry = geometry
+			}
+		}
+		features = append(features, feature)
+	}
+	return features, nil
+}
+
+// geoJSONGeometryToEsri converts a decoded GeoJSON geometry to an Esri JSON geometry, or
+// returns nil if the geometry is empty or not supported.
+func geoJSONGeometryToEsri(geometry map[string]interface{}) map[string]interface{} {
+	coordinates := geometry[KeyCoordinates]
+	switch geometry[KeyType] {
+	case "Point":
+		point := parseEsriPath([]interface{}{coordinates})
+		if len(point) == 0 {
+			return nil
+		}
+		return map[string]interface{}{"x": point[0][0], "y": point[0][1]}
+	case "MultiPoint":
+		if points := parseEsriPath(coordinates); len(points) > 0 {
+			return map[string]interface{}{"points": esriPath(points)}
+		}
+	case "LineString":
+		if line := parseEsriPath(coordinates); len(line) > 0 {
+			return map[string]interface{}{"paths": []interface{}{esriPath(line)}}
+		}
+	case "MultiLineString":
+		if lines := parseEsriPaths(coordinates); len(lines) > 0 {
+			paths := make([]interface{}, len(lines))
+			for i, line := range lines {
+				paths[i] = esriPath(line)
+			}
+			return map[string]interface{}{"paths": paths}
+		}
+	case "Polygon", "MultiPolygon":
+		polygons := []interface{}{coordinates}
+		if geometry[KeyType] == "MultiPolygon" {
+			polygons, _ = coordinates.([]interface{})
+		}
+		var rings []interface{}
+		for _, polygon := range polygons {
+			for i, ring := range parseEsriPaths(polygon) {
+				// Outer rings are clockwise (negative area) and holes counter-clockwise.
+				if (i == 0) == (ringArea(ring) > 0) {
+					for a, b := 0, len(ring)-1; a < b; a, b = a+1, b-1 {
+						ring[a], ring[b] = ring[b], ring[a]
+					}
+				}
+				rings = append(rings, esriPath(ring))
+			}
+		}
+		if len(rings) > 0 {
+			return map[string]interface{}{"rings": rings}
+		}
+	}
+	return nil
+}
+
+// esriPath converts coordinates to the nested lists of a decoded Esri JSON path.
+func esriPath(coords [][]float64) []interface{} {
+	path := make([]interface{}, len(coords))
+	for i, c := range coords {
+		path[i] = []interface{}{c[0], c[1]}
+	}
+	return path
+}
+
+// symbolFromArcGIS copies an ArcGIS symbol, including its outline, into a convert.Symbol.
+func symbolFromArcGIS(arcSymbol *arcgis.Symbol) *Symbol {
+	if arcSymbol == nil {
+		return nil
+	}
+	return &Symbol{
+		Type:        arcSymbol.Type,
+		URL:         arcSymbol.URL,
+		ImageData:   arcSymbol.ImageData,
+		ContentType: arcSymbol.ContentType,
+		Width:       arcSymbol.Width,
+		Height:      arcSymbol.Height,
+		XOffset:     arcSymbol.XOffset,
+		YOffset:     arcSymbol.YOffset,
+		Angle:       arcSymbol.Angle,
+		Color:       arcSymbol.Color,
+		Size:        arcSymbol.Size,
+		Style:       arcSymbol.Style,
+		Outline:     symbolFromArcGIS(arcSymbol.Outline),
+	}
+}
+
+// esriGeometryToGeoJSON converts an Esri JSON geometry to a GeoJSON geometry, or returns nil
+// if the geometry is empty or not recognized.
+func esriGeometryToGeoJSON(geometry map[string]interface{}) map[string]interface{} {
+	if xVal, ok := geometry["x"]; ok {
+		x, xOk := xVal.(float64)
+		y, yOk := geometry["y"].(float64)
+		if !xOk || !yOk {
+			return nil
+		}
+		return map[string]interface{}{KeyType: "Point", KeyCoordinates: []float64{x, y}}
+	}
+	if points, ok := geometry["points"]; ok {
+		coords := parseEsriPath(points)
+		if len(coords) == 0 {
+			return nil
+		}
+		return map[string]interface{}{KeyType: "MultiPoint", KeyCoordinates: coords}
+	}
+	if paths, ok := geometry["paths"]; ok {
+		lines := parseEsriPaths(paths)
+		switch len(lines) {
+		case 0:
+			return nil
+		case 1:
+			return map[string]interface{}{KeyType: "LineString", KeyCoordinates: lines[0]}
+		default:
+			return map[string]interface{}{KeyType: "MultiLineString", KeyCoordinates: lines}
+		}
+	}
+	if rings, ok := geometry["rings"]; ok {
+		ringCoords := parseEsriPaths(rings)
+		for i, ring := range ringCoords {
+			if ring[0][0] != ring[len(ring)-1][0] || ring[0][1] != ring[len(ring)-1][1] {
+				ringCoords[i] = append(ring, ring[0])
+			}
+		}
+		polygons := groupEsriRings(ringCoords)
+		switch len(polygons) {
+		case 0:
+			return nil
+		case 1:
+			return map[string]interface{}{KeyType: "Polygon", KeyCoordinates: polygons[0]}
+		default:
+			return map[string]interface{}{KeyType: "MultiPolygon", KeyCoordinates: polygons}
+		}
+	}
+	return nil
+}
+
+// parseEsriPaths parses a list of Esri JSON paths or rings, dropping empty ones.
+func parseEsriPaths(value interface{}) [][][]float64 {
+	paths, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+	result := [][][]float64{}
+	for _, path := range paths {
+		if coords := parseEsriPath(path); len(coords) > 0 {
+			result = append(result, coords)
+		}
+	}
+	return result
+}
+
+// parseEsriPath parses a list of Esri JSON [x, y, ...] positions, keeping x and y.
+func parseEsriPath(value interface{}) [][]float64 {
+	points, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+	coords := [][]float64{}
+	for _, p := range points {
+		point, pointOk := p.([]interface{})
+		if pointOk && len(point) >= MinCoords {
+			x, xOk := point[IndexFirst].(float64)
+			y, yOk := point[IndexSecond].(float64)
+			if xOk && yOk {
+				coords = append(coords, []float64{x, y})
+			}
+		}
+	}
+	return coords
+}
+
+// groupEsriRings groups Esri polygon rings into polygons.
+// Esri outer rings are clockwise and holes counter-clockwise; each hole is assigned to the
+// smallest outer ring containing it, so that a lake hole goes to the polygon around it rather
+// than to an island inside an enclosing polygon. Rings that cannot be assigned become polygons
+// of their own.
+func groupEsriRings(rings [][][]float64) [][][][]float64 {
+	var polygons [][][][]float64
+	var holes [][][]float64
+	for _, ring := range rings {
+		if ringArea(ring) <= 0 {
+			polygons = append(polygons, [][][]float64{ring})
+		} else {
+			holes = append(holes, ring)
+		}
+	}
+	outers := len(polygons)
+	for _, hole := range holes {
+		best, bestArea := -1, 0.0
+		for i := 0; i < outers; i++ {
+			area := -ringArea(polygons[i][0])
+			if (best < 0 || area < bestArea) && pointInRing(hole[0], polygons[i][0]) {
+				best, bestArea = i, area
+			}
+		}
+		if best < 0 {
+			polygons = append(polygons, [][][]float64{hole})
+		} else {
+			polygons[best] = append(polygons[best], hole)
+		}
+	}
+	return polygons
+}
+
+// ringArea returns the signed area of a ring; clockwise rings have a negative area.
+func ringArea(ring [][]float64) float64 {
+	area := 0.0
+	for i := 0; i+1 < len(ring); i++ {
+		area += ring[i][0]*ring[i+1][1] - ring[i+1][0]*ring[i][1]
+	}
+	return area / 2
+}
+
+// pointInRing reports whether a point lies inside a ring using ray casting.
+func pointInRing(point []float64, ring [][]float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > point[1]) != (yj > point[1]) && point[0] < (xj-xi)*(point[1]-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// FormatValue formats an attribute value as text. Numbers are written in plain decimal
+// notation with the fewest digits that represent them exactly, so that large IDs and
+// epoch dates are never written in exponent form.
+func FormatValue(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
 // FeaturesToCSV converts a slice of Feature structs to a CSV string.
 // The CSV includes:
 //   - All unique attribute fields as columns
@@ -158,8 +379,25 @@ func ToGeoJSON(features []Feature) (*GeoJSON, error) {
 //   - string: CSV formatted string
 //   - error: Any error that occurred during conversion
 func FeaturesToCSV(features []Feature) (string, error) {
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, features); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// WriteCSV writes features as CSV to w, row by row, in the format of FeaturesToCSV.
+// Nothing is written for an empty slice.
+//
+// Parameters:
+//   - w: Destination of the CSV
+//   - features: Slice of Feature structs to convert
+//
+// Returns:
+//   - error: Any error that occurred during conversion or writing
+func WriteCSV(w io.Writer, features []Feature) error {
 	if len(features) == 0 {
-		return "", nil
+		return nil
 	}
 
 	// Determine all unique headers from all features' attributes
@@ -177,12 +415,11 @@ func FeaturesToCSV(features []Feature) (string, error) {
 	sort.Strings(headers)                     // Sort for consistent column order
 	headers = append(headers, "WKT_Geometry") // Add geometry column header
 
-	var buf bytes.Buffer
-	w := csv.NewWriter(&buf)
+	csvWriter := csv.NewWriter(w)
 
 	// Write header row
-	if err := w.Write(headers); err != nil {
-		return "", fmt.Errorf("failed to write CSV header: %v", err)
+	if err := csvWriter.Write(headers); err != nil {
+		return fmt.Errorf("failed to write CSV header: %v", err)
 	}
 
 	// Write data rows
@@ -193,23 +430,22 @@ func FeaturesToCSV(features []Feature) (string, error) {
 				row[i] = geometryToWKT(feature.Geometry)
 			} else {
 				if val, ok := feature.Attributes[header]; ok && val != nil {
-					row[i] = fmt.Sprintf("%v", val)
+					row[i] = FormatValue(val)
 				} else {
 					row[i] = "" // Handle nil or missing attributes
 				}
 			}
 		}
-		if err := w.Write(row); err != nil {
-			return "", fmt.Errorf("failed to write row to CSV: %v", err)
+		if err := csvWriter.Write(row); err != nil {
+			return fmt.Errorf("failed to write row to CSV: %v", err)
 		}
 	}
 
-	w.Flush()
-	if err := w.Error(); err != nil {
-		return "", fmt.Errorf("error during CSV writing: %v", err)
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return fmt.Errorf("error during CSV writing: %v", err)
 	}
-
-	return buf.String(), nil
+	return nil
 }
 
 // FeaturesToText converts a slice of Feature structs to a formatted text string.
@@ -226,18 +462,36 @@ func FeaturesToCSV(features []Feature) (string, error) {
 //   - string: Formatted text output
 //   - error: Any error that occurred during conversion
 func FeaturesToText(features []Feature, layerName string) (string, error) {
+	var output strings.Builder
+	if err := WriteText(&output, features, layerName); err != nil {
+		return "", err
+	}
+	return output.String(), nil
+}
+
+// WriteText writes features as formatted text to w, feature by feature, in the format
+// of FeaturesToText.
+//
+// Parameters:
+//   - w: Destination of the text
+//   - features: Slice of Feature structs to convert
+//   - layerName: Name of the layer for the header
+//
+// Returns:
+//   - error: Any error that occurred during conversion or writing
+func WriteText(w io.Writer, features []Feature, layerName string) error {
 	if len(features) == 0 {
-		return "", fmt.Errorf("no features to convert to text")
+		return fmt.Errorf("no features to convert to text")
 	}
 
-	var output strings.Builder
+	output := bufio.NewWriter(w)
 
-	output.WriteString(fmt.Sprintf("Layer: %s\n", layerName))
-	output.WriteString(fmt.Sprintf("Total Features: %d\n", len(features)))
+	fmt.Fprintf(output, "Layer: %s\n", layerName)
+	fmt.Fprintf(output, "Total Features: %d\n", len(features))
 	output.WriteString("========================================\n\n")
 
 	for i, feature := range features {
-		output.WriteString(fmt.Sprintf("--- Feature %d ---\n", i+1))
+		fmt.Fprintf(output, "--- Feature %d ---\n", i+1)
 
 		// Sort attribute keys for consistent order
 		var keys []string
@@ -248,7 +502,7 @@ func FeaturesToText(features []Feature, layerName string) (string, error) {
 
 		output.WriteString("Attributes:\n")
 		for _, k := range keys {
-			output.WriteString(fmt.Sprintf("  %s: %v\n", k, feature.Attributes[k]))
+			fmt.Fprintf(output, "  %s: %s\n", k, FormatValue(feature.Attributes[k]))
 		}
 
 		output.WriteString("Geometry (WKT):\n")
@@ -256,93 +510,159 @@ func FeaturesToText(features []Feature, layerName string) (string, error) {
 		if wkt == "" {
 			output.WriteString("  <No Geometry>\n")
 		} else {
-			output.WriteString(fmt.Sprintf("  %s\n", wkt))
+			fmt.Fprintf(output, "  %s\n", wkt)
 		}
 		output.WriteString("\n") // Add a blank line between features
 	}
 
-	return output.String(), nil
+	if err := output.Flush(); err != nil {
+		return fmt.Errorf("failed to write text: %v", err)
+	}
+	return nil
+}
+
+// WriteGeoJSON writes a FeatureCollection to w feature by feature, producing the same
+// indented JSON as json.MarshalIndent with a two-space indent, without encoding the
+// whole collection in memory first.
+//
+// Parameters:
+//   - w: Destination of the GeoJSON
+//   - geoJSON: FeatureCollection to write
+//
+// Returns:
+//   - error: Any error that occurred during encoding or writing
+func WriteGeoJSON(w io.Writer, geoJSON *GeoJSON) error {
+	typeJSON, err := json.Marshal(geoJSON.Type)
+	if err != nil {
+		return fmt.Errorf("failed to marshal GeoJSON: %v", err)
+	}
+	crsJSON, err := json.MarshalIndent(geoJSON.CRS, "  ", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal GeoJSON: %v", err)
+	}
+
+	output := bufio.NewWriter(w)
+	fmt.Fprintf(output, "{\n  \"type\": %s,\n  \"crs\": %s,\n  \"features\": ", typeJSON, crsJSON)
+	if err := writeIndentedArray(output, "  ", len(geoJSON.Features), geoJSON.Features == nil, func(i int) interface{} { return geoJSON.Features[i] }); err != nil {
+		return fmt.Errorf("failed to marshal GeoJSON: %v", err)
+	}
+	output.WriteString("\n}")
+	if err := output.Flush(); err != nil {
+		return fmt.Errorf("failed to write GeoJSON: %v", err)
+	}
+	return nil
+}
+
+// WriteFeaturesJSON writes features as an indented JSON array to w, one feature at a
+// time, producing the same output as json.MarshalIndent with a two-space indent.
+//
+// Parameters:
+//   - w: Destination of the JSON
+//   - features: Slice of Feature structs to write
+//
+// Returns:
+//   - error: Any error that occurred during encoding or writing
+func WriteFeaturesJSON(w io.Writer, features []Feature) error {
+	output := bufio.NewWriter(w)
+	if err := writeIndentedArray(output, "", len(features), features == nil, func(i int) interface{} { return features[i] }); err != nil {
+		return fmt.Errorf("failed to marshal features to JSON: %v", err)
+	}
+	if err := output.Flush(); err != nil {
+		return fmt.Errorf("failed to write JSON: %v", err)
+	}
+	return nil
+}
+
+// writeIndentedArray writes an array nested at prefix as json.MarshalIndent would, encoding
+// one element at a time. A nil array is written as null.
+func writeIndentedArray(w *bufio.Writer, prefix string, count int, isNil bool, item func(int) interface{}) error {
+	switch {
+	case isNil:
+		w.WriteString("null")
+		return nil
+	case count == 0:
+		w.WriteString("[]")
+		return nil
+	}
+	w.WriteString("[")
+	for i := 0; i < count; i++ {
+		data, err := json.MarshalIndent(item(i), prefix+"  ", "  ")
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			w.WriteString(",")
+		}
+		w.WriteString("\n" + prefix + "  ")
+		w.Write(data)
+	}
+	w.WriteString("\n" + prefix + "]")
+	return nil
 }
 
 // geometryToWKT converts a geometry interface to a WKT string.
 // Supports:
-//   - Point geometries (x,y coordinates)
-//   - LineString geometries (paths)
-//   - Polygon geometries (rings)
+//   - Point and MultiPoint geometries (x,y coordinates and points)
+//   - LineString and MultiLineString geometries (paths)
+//   - Polygon and MultiPolygon geometries (rings)
 //
 // Returns empty string if geometry is nil or invalid.
 func geometryToWKT(geometry interface{}) string {
-	if geometry == nil {
-		return ""
-	}
-
 	geomMap, ok := geometry.(map[string]interface{})
 	if !ok {
 		return ""
 	}
+	geoJSONGeometry := esriGeometryToGeoJSON(geomMap)
+	if geoJSONGeometry == nil {
+		return ""
+	}
 
-	if xVal, xOk := geomMap["x"]; xOk {
-		if yVal, yOk := geomMap["y"]; yOk {
-			x, xFloatOk := xVal.(float64)
-			y, yFloatOk := yVal.(float64)
-			if xFloatOk && yFloatOk {
-				return fmt.Sprintf("POINT (%.10f %.10f)", x, y)
+	switch coords := geoJSONGeometry[KeyCoordinates].(type) {
+	case []float64:
+		return fmt.Sprintf("POINT (%s)", wktPosition(coords))
+	case [][]float64:
+		if geoJSONGeometry[KeyType] == "MultiPoint" {
+			points := make([]string, len(coords))
+			for i, c := range coords {
+				points[i] = fmt.Sprintf("(%s)", wktPosition(c))
 			}
+			return fmt.Sprintf("MULTIPOINT (%s)", strings.Join(points, ", "))
 		}
-	} else if paths, pOk := geomMap["paths"]; pOk {
-		pathArray, pathArrayOk := paths.([]interface{})
-		if pathArrayOk && len(pathArray) > 0 {
-			firstPath, firstPathOk := pathArray[0].([]interface{})
-			if firstPathOk {
-				var points []string
-				for _, p := range firstPath {
-					point, pointOk := p.([]interface{})
-					if pointOk && len(point) >= 2 {
-						x, xOk := point[0].(float64)
-						y, yOk := point[1].(float64)
-						if xOk && yOk {
-							points = append(points, fmt.Sprintf("%.10f %.10f", x, y))
-						}
-					}
-				}
-				if len(points) == 0 {
-					return ""
-				}
-				return fmt.Sprintf("LINESTRING (%s)", strings.Join(points, ", "))
-			}
+		return fmt.Sprintf("LINESTRING %s", wktPositions(coords))
+	case [][][]float64:
+		if geoJSONGeometry[KeyType] == "MultiLineString" {
+			return fmt.Sprintf("MULTILINESTRING %s", wktPositionLists(coords))
 		}
-	} else if rings, rOk := geomMap["rings"]; rOk {
-		ringArray, ringArrayOk := rings.([]interface{})
-		if ringArrayOk && len(ringArray) > 0 {
-			var polygonRings []string
-			for _, r := range ringArray {
-				ringCoords, ringCoordsOk := r.([]interface{})
-				if ringCoordsOk {
-					var points []string
-					for _, p := range ringCoords {
-						point, pointOk := p.([]interface{})
-						if pointOk && len(point) >= 2 {
-							x, xOk := point[0].(float64)
-							y, yOk := point[1].(float64)
-							if xOk && yOk {
-								points = append(points, fmt.Sprintf("%.10f %.10f", x, y))
-							}
-						}
-					}
-					if len(points) > 0 {
-						if points[0] != points[len(points)-1] {
-							points = append(points, points[0])
-						}
-						polygonRings = append(polygonRings, fmt.Sprintf("(%s)", strings.Join(points, ", ")))
-					}
-				}
-			}
-			if len(polygonRings) == 0 {
-				return ""
-			}
-			return fmt.Sprintf("POLYGON (%s)", strings.Join(polygonRings, ", "))
+		return fmt.Sprintf("POLYGON %s", wktPositionLists(coords))
+	case [][][][]float64:
+		polygons := make([]string, len(coords))
+		for i, polygon := range coords {
+			polygons[i] = wktPositionLists(polygon)
 		}
+		return fmt.Sprintf("MULTIPOLYGON (%s)", strings.Join(polygons, ", "))
 	}
-
 	return ""
 }
+
+// wktPosition formats a single position as "x y".
+func wktPosition(c []float64) string {
+	return fmt.Sprintf("%.10f %.10f", c[IndexFirst], c[IndexSecond])
+}
+
+// wktPositions formats a list of positions as "(x y, x y, ...)".
+func wktPositions(coords [][]float64) string {
+	points := make([]string, len(coords))
+	for i, c := range coords {
+		points[i] = wktPosition(c)
+	}
+	return fmt.Sprintf("(%s)", strings.Join(points, ", "))
+}
+
+// wktPositionLists formats several position lists as "((...), (...))".
+func wktPositionLists(lists [][][]float64) string {
+	parts := make([]string, len(lists))
+	for i, list := range lists {
+		parts[i] = wktPositions(list)
+	}
+	return fmt.Sprintf("(%s)", strings.Join(parts, ", "))
+}
@@ -1,6 +1,8 @@
 package convert
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"testing"
@@ -135,10 +137,10 @@ func TestConvertToGeoJSON(t *testing.T) {
 				t.Errorf("Feature 1: Expected symbol contentType 'image/png', got %q", symbol.ContentType)
 			}
 			if symbol.Width != 20 {
-				t.Errorf("Feature 1: Expected symbol width 20, got %d", symbol.Width)
+				t.Errorf("Feature 1: Expected symbol width 20, got %g", symbol.Width)
 			}
 			if symbol.Height != 20 {
-				t.Errorf("Feature 1: Expected symbol height 20, got %d", symbol.Height)
+				t.Errorf("Feature 1: Expected symbol height 20, got %g", symbol.Height)
 			}
 		}
 	}
@@ -240,3 +242,219 @@ func TestConvertFeaturesToText(t *testing.T) {
 		t.Errorf("Text output missing '<No Geometry>' marker for nil geometry feature.")
 	}
 }
+
+func TestMultiPartGeometries(t *testing.T) {
+	ring := func(points ...[]float64) []interface{} {
+		r := make([]interface{}, len(points))
+		for i, p := range points {
+			r[i] = []interface{}{p[0], p[1]}
+		}
+		return r
+	}
+	// Esri outer rings are clockwise, holes counter-clockwise.
+	outerA := ring([]float64{0, 0}, []float64{0, 10}, []float64{10, 10}, []float64{10, 0}, []float64{0, 0})
+	holeA := ring([]float64{2, 2}, []float64{4, 2}, []float64{4, 4}, []float64{2, 4}, []float64{2, 2})
+	outerB := ring([]float64{20, 0}, []float64{20, 5}, []float64{25, 5}, []float64{25, 0}, []float64{20, 0})
+
+	tests := []struct {
+		name     string
+		geometry map[string]interface{}
+		wantType string
+		wantWKT  string
+	}{
+		{
+			name:     "MultiPoint",
+			geometry: map[string]interface{}{"points": ring([]float64{1, 2}, []float64{3, 4})},
+			wantType: "MultiPoint",
+			wantWKT:  "MULTIPOINT ((1.0000000000 2.0000000000), (3.0000000000 4.0000000000))",
+		},
+		{
+			name:     "MultiLineString",
+			geometry: map[string]interface{}{"paths": []interface{}{ring([]float64{0, 0}, []float64{1, 1}), ring([]float64{2, 2}, []float64{3, 3})}},
+			wantType: "MultiLineString",
+			wantWKT:  "MULTILINESTRING ((0.0000000000 0.0000000000, 1.0000000000 1.0000000000), (2.0000000000 2.0000000000, 3.0000000000 3.0000000000))",
+		},
+		{
+			name:     "Polygon with hole",
+			geometry: map[string]interface{}{"rings": []interface{}{outerA, holeA}},
+			wantType: "Polygon",
+		},
+		{
+			name:     "MultiPolygon",
+			geometry: map[string]interface{}{"rings": []interface{}{outerA, outerB, holeA}},
+			wantType: "MultiPolygon",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			geoJSON, err := ToGeoJSON([]Feature{{Attributes: map[string]interface{}{}, Geometry: tt.geometry}})
+			if err != nil {
+				t.Fatalf("ToGeoJSON failed: %v", err)
+			}
+			if len(geoJSON.Features) != 1 {
+				t.Fatalf("Expected 1 feature, got %d", len(geoJSON.Features))
+			}
+			geom := geoJSON.Features[0].Geometry.(map[string]interface{})
+			if geom["type"] != tt.wantType {
+				t.Errorf("Expected %s, got %v", tt.wantType, geom["type"])
+			}
+			if tt.wantWKT != "" {
+				if got := geometryToWKT(tt.geometry); got != tt.wantWKT {
+					t.Errorf("geometryToWKT() = %q, want %q", got, tt.wantWKT)
+				}
+			}
+			if tt.wantType == "MultiPolygon" {
+				polygons := geom["coordinates"].([][][][]float64)
+				if len(polygons) != 2 || len(polygons[0]) != 2 || len(polygons[1]) != 1 {
+					t.Errorf("Expected hole assigned to the first polygon, got %v", polygons)
+				}
+			}
+		})
+	}
+}
+
+func TestGroupEsriRingsNested(t *testing.T) {
+	// A polygon with a lake, an island in the lake and a pond on the island. Outer rings are
+	// clockwise and holes counter-clockwise.
+	outer := [][]float64{{0, 0}, {0, 10}, {10, 10}, {10, 0}, {0, 0}}
+	lake := [][]float64{{2, 2}, {8, 2}, {8, 8}, {2, 8}, {2, 2}}
+	island := [][]float64{{4, 4}, {4, 6}, {6, 6}, {6, 4}, {4, 4}}
+	pond := [][]float64{{4.5, 4.5}, {5.5, 4.5}, {5.5, 5.5}, {4.5, 5.5}, {4.5, 4.5}}
+
+	polygons := groupEsriRings([][][]float64{outer, island, lake, pond})
+	if len(polygons) != 2 {
+		t.Fatalf("Expected 2 polygons, got %d: %v", len(polygons), polygons)
+	}
+	if len(polygons[0]) != 2 || polygons[0][1][0][0] != 2 {
+		t.Errorf("Expected the lake assigned to the outer polygon, got %v", polygons[0])
+	}
+	if len(polygons[1]) != 2 || polygons[1][1][0][0] != 4.5 {
+		t.Errorf("Expected the pond assigned to the island, got %v", polygons[1])
+	}
+}
+
+func TestFormatDateFields(t *testing.T) {
+	original := map[string]interface{}{"OBJECTID": 1.0, "CREATED": 1709296200000.0, "EDITED": 1709296200123.0, "CLOSED": nil}
+	features := []Feature{{Attributes: original}, {Attributes: map[string]interface{}{"OBJECTID": 2.0}}}
+
+	formatted := FormatDateFields(features, []string{"CREATED", "EDITED", "CLOSED"})
+	tests := []struct {
+		field string
+		want  interface{}
+	}{
+		{"CREATED", "2024-03-01T12:30:00Z"},
+		{"EDITED", "2024-03-01T12:30:00.123Z"},
+		{"CLOSED", nil},
+		{"OBJECTID", 1.0},
+	}
+	for _, tt := range tests {
+		if got := formatted[0].Attributes[tt.field]; got != tt.want {
+			t.Errorf("%s = %v; want %v", tt.field, got, tt.want)
+		}
+	}
+	if original["CREATED"] != 1709296200000.0 {
+		t.Error("Expected the input attributes to be left unchanged")
+	}
+	if _, ok := formatted[1].Attributes["CREATED"]; ok {
+		t.Error("Expected no date to be added to features without one")
+	}
+
+	for _, value := range []interface{}{1709296200000.0, "2024-03-01T12:30:00Z", "2024-03-01T13:30:00+01:00"} {
+		if got, ok := ParseFeatureTime(value); !ok || got.UnixMilli() != 1709296200000 {
+			t.Errorf("ParseFeatureTime(%v) = %v, %v", value, got, ok)
+		}
+	}
+	if _, ok := ParseFeatureTime("yesterday"); ok {
+		t.Error("Expected an unparseable time to be rejected")
+	}
+}
+
+func TestFromGeoJSON(t *testing.T) {
+	// A counter-clockwise outer ring with a clockwise hole, as GeoJSON recommends.
+	data := []byte(`{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "properties": {"NAME": "Point"}, "geometry": {"type": "Point", "coordinates": [1, 2, 30]}},
+		{"type": "Feature", "properties": null, "geometry": {"type": "MultiLineString", "coordinates": [[[0, 0], [1, 1]], [[2, 2], [3, 3]]]}},
+		{"type": "Feature", "properties": {"NAME": "Park"}, "geometry": {"type": "Polygon", "coordinates": [
+			[[0, 0], [4, 0], [4, 4], [0, 4], [0, 0]],
+			[[1, 1], [1, 2], [2, 2], [2, 1], [1, 1]]
+		]}},
+		{"type": "Feature", "properties": {"NAME": "Nowhere"}, "geometry": null}
+	]}`)
+	features, err := FromGeoJSON(data)
+	if err != nil {
+		t.Fatalf("FromGeoJSON failed: %v", err)
+	}
+	if len(features) != 4 {
+		t.Fatalf("Expected 4 features, got %d", len(features))
+	}
+	if point := features[0].Geometry.(map[string]interface{}); point["x"] != 1.0 || point["y"] != 2.0 {
+		t.Errorf("Unexpected point %v", point)
+	}
+	if features[1].Attributes == nil || len(features[1].Geometry.(map[string]interface{})["paths"].([]interface{})) != 2 {
+		t.Errorf("Expected empty attributes and two paths, got %+v", features[1])
+	}
+	if features[3].Geometry != nil {
+		t.Errorf("Expected no geometry, got %v", features[3].Geometry)
+	}
+
+	// Converting back to GeoJSON restores the polygon with its hole.
+	geoJSON, err := ToGeoJSON(features)
+	if err != nil {
+		t.Fatalf("ToGeoJSON failed: %v", err)
+	}
+	if len(geoJSON.Features) != 3 {
+		t.Fatalf("Expected 3 features with geometries, got %d", len(geoJSON.Features))
+	}
+	polygon := geoJSON.Features[2].Geometry.(map[string]interface{})
+	if rings := polygon["coordinates"].([][][]float64); polygon["type"] != "Polygon" || len(rings) != 2 {
+		t.Errorf("Expected a polygon with a hole, got %v", polygon)
+	}
+
+	single, err := FromGeoJSON([]byte(`{"type": "Feature", "properties": {"A": 1}, "geometry": {"type": "MultiPoint", "coordinates": [[1, 2], [3, 4]]}}`))
+	if err != nil || len(single) != 1 || single[0].Geometry.(map[string]interface{})["points"] == nil {
+		t.Errorf("Unexpected single feature %+v: %v", single, err)
+	}
+	if _, err := FromGeoJSON([]byte(`{"type": "Topology"}`)); err == nil {
+		t.Error("Expected an error for an unsupported GeoJSON type")
+	}
+}
+
+func TestWriteGeoJSON(t *testing.T) {
+	full, err := ToGeoJSON(testFeatures)
+	if err != nil {
+		t.Fatalf("ToGeoJSON failed: %v", err)
+	}
+	full.Features[0].Properties["note"] = "<a & b>"
+	collections := map[string]*GeoJSON{
+		"features": full,
+		"empty":    {Type: "FeatureCollection", Features: []GeoJSONFeature{}},
+		"nil":      {Type: "FeatureCollection"},
+	}
+	for name, geoJSON := range collections {
+		want, err := json.MarshalIndent(geoJSON, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got bytes.Buffer
+		if err := WriteGeoJSON(&got, geoJSON); err != nil {
+			t.Fatalf("%s: WriteGeoJSON failed: %v", name, err)
+		}
+		if got.String() != string(want) {
+			t.Errorf("%s: WriteGeoJSON differs from json.MarshalIndent:\n%s\nwant:\n%s", name, got.String(), want)
+		}
+	}
+
+	for name, features := range map[string][]Feature{"features": testFeatures, "empty": {}, "nil": nil} {
+		want, err := json.MarshalIndent(features, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got bytes.Buffer
+		if err := WriteFeaturesJSON(&got, features); err != nil {
+			t.Fatalf("%s: WriteFeaturesJSON failed: %v", name, err)
+		}
+		if got.String() != string(want) {
+			t.Errorf("%s: WriteFeaturesJSON differs from json.MarshalIndent:\n%s\nwant:\n%s", name, got.String(), want)
+		}
+	}
+}
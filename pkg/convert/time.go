@@ -0,0 +1,89 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package convert provides functions for converting between different geospatial data formats.
+package convert
+
+import (
+	"strings"
+	"time"
+)
+
+// EsriDateToISO formats an Esri date, given in milliseconds since the Unix epoch,
+// as an ISO 8601 timestamp in UTC. Fractional seconds are only written when present.
+//
+// Parameters:
+//   - milliseconds: Milliseconds since 1970-01-01T00:00:00Z
+//
+// Returns:
+//   - string: ISO 8601 timestamp, e.g. 2024-03-01T12:30:00Z
+func EsriDateToISO(milliseconds float64) string {
+	return time.UnixMilli(int64(milliseconds)).UTC().Format(time.RFC3339Nano)
+}
+
+// FormatDateFields converts date attributes from epoch milliseconds to ISO 8601 strings.
+// The function handles:
+//   - Numeric values, which are replaced by their ISO 8601 representation
+//   - Null and string values, which are kept unchanged
+//   - Features without date fields, which are returned as they are
+//
+// Features with converted dates get a new attribute map, so the input is not modified.
+//
+// Parameters:
+//   - features: Slice of features with Esri attributes
+//   - dateFields: Names of the date fields
+//
+// Returns:
+//   - []Feature: Features with ISO 8601 date values
+func FormatDateFields(features []Feature, dateFields []string) []Feature {
+	if len(dateFields) == 0 {
+		return features
+	}
+	result := make([]Feature, len(features))
+	for i, feature := range features {
+		result[i] = feature
+		var attributes map[string]interface{}
+		for _, field := range dateFields {
+			milliseconds, ok := feature.Attributes[field].(float64)
+			if !ok {
+				continue
+			}
+			if attributes == nil {
+				attributes = make(map[string]interface{}, len(feature.Attributes))
+				for key, value := range feature.Attributes {
+					attributes[key] = value
+				}
+			}
+			attributes[field] = EsriDateToISO(milliseconds)
+		}
+		if attributes != nil {
+			result[i].Attributes = attributes
+		}
+	}
+	return result
+}
+
+// ParseFeatureTime reads a time attribute given either in epoch milliseconds or as an
+// ISO 8601 string, as produced by FormatDateFields.
+//
+// Parameters:
+//   - value: The attribute value
+//
+// Returns:
+//   - time.Time: The time in UTC
+//   - bool: False if the value is null or not a recognizable time
+func ParseFeatureTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case float64:
+		return time.UnixMilli(int64(v)).UTC(), true
+	case int64:
+		return time.UnixMilli(v).UTC(), true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return t.UTC(), true
+			}
+		}
+	}
+	return time.Time{}, false
+}
@@ -42,16 +42,26 @@ type CRSProps struct {
 //   - ImageData: Base64-encoded image data
 //   - ContentType: The MIME type of the image
 //   - Dimensions and positioning information
+//   - Color, size, style and outline of simple marker, line and fill symbols
 type Symbol struct {
 	Type        string  `json:"type"`
 	URL         string  `json:"url"`
 	ImageData   string  `json:"imageData"`
 	ContentType string  `json:"contentType"`
-	Width       int     `json:"width"`
-	Height      int     `json:"height"`
-	XOffset     int     `json:"xoffset"`
-	YOffset     int     `json:"yoffset"`
+	Width       float64 `json:"width"`
+	Height      float64 `json:"height"`
+	XOffset     float64 `json:"xoffset"`
+	YOffset     float64 `json:"yoffset"`
 	Angle       float64 `json:"angle"`
+	// Color is an [r, g, b, a] array with components from 0 to 255; nil means no color.
+	Color []int `json:"color,omitempty"`
+	// Size is the marker size in points (esriSMS).
+	Size float64 `json:"size,omitempty"`
+	// Style is the marker shape, line dash or fill pattern, e.g. esriSMSCircle,
+	// esriSLSDash or esriSFSSolid.
+	Style string `json:"style,omitempty"`
+	// Outline is the line symbol drawn around markers and fills.
+	Outline *Symbol `json:"outline,omitempty"`
 }
 
 // Feature represents a geographic feature with attributes and geometry.
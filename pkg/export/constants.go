@@ -33,3 +33,151 @@ const (
 	MinCoordsForPolygon     = 2
 )
 
+
+const (
+	DefaultTopoJSONQuantization = 10000
+	MinTopoJSONQuantization     = 2
+	LayerObjectNameFormat       = "layer_%d"
+)
+
+const (
+	SQLitePageSize            = 4096
+	SQLitePage1HeaderSize     = 100
+	SQLiteLeafHeaderSize      = 8
+	SQLiteInteriorHeaderSize  = 12
+	SQLiteCellPointerSize     = 2
+	SQLiteMaxInteriorCellSize = 13
+	SQLiteTableLeafOverhead   = 35
+	SQLiteMaxIndexPayload     = (SQLitePageSize-12)*64/255 - 23
+	SQLiteVersionNumber       = 3040001
+	SQLitePageIndexInterior   = 0x02
+	SQLitePageTableInterior   = 0x05
+	SQLitePageIndexLeaf       = 0x0a
+	SQLitePageTableLeaf       = 0x0d
+)
+
+const (
+	DefaultMinZoom           = 0
+	DefaultMaxZoom           = 12 // Every tile is held in memory, which limits practical zoom levels
+	MaxTileZoom              = 24
+	DefaultTileExtent        = 4096
+	DefaultTileBuffer        = 64
+	DefaultSimplifyTolerance = 1.0
+	MaxMercatorLatitude      = 85.0511287798066
+	TileFieldString          = "String"
+	TileFieldNumber          = "Number"
+	TileFieldBoolean         = "Boolean"
+	MVTVersion               = 2
+	MVTTileLayers            = 3
+	MVTLayerName             = 1
+	MVTLayerFeatures         = 2
+	MVTLayerKeys             = 3
+	MVTLayerValues           = 4
+	MVTLayerExtent           = 5
+	MVTLayerVersion          = 15
+	MVTFeatureID             = 1
+	MVTFeatureTags           = 2
+	MVTFeatureType           = 3
+	MVTFeatureGeometry       = 4
+	MVTValueString           = 1
+	MVTValueDouble           = 3
+	MVTValueSint             = 6
+	MVTValueBool             = 7
+	MVTGeomPoint             = 1
+	MVTGeomLineString        = 2
+	MVTGeomPolygon           = 3
+	MVTCmdMoveTo             = 1
+	MVTCmdLineTo             = 2
+	MVTCmdClosePath          = 7
+	ProtoWireVarint          = 0
+	ProtoWireFixed64         = 1
+	ProtoWireBytes           = 2
+)
+
+const (
+	MBTilesApplicationID = 0x4d504258
+)
+
+// PMTiles constants
+const (
+	PMTilesHeaderSize      = 127   // Fixed size of a PMTiles v3 header
+	PMTilesVersion         = 3     // PMTiles specification version
+	PMTilesRootSize        = 16384 // Header and root directory must fit in this many bytes
+	PMTilesLeafEntries     = 4096  // Initial number of entries per leaf directory
+	PMTilesLeafGrowth      = 1.2   // Growth factor for leaf size when the root is too large
+	PMTilesCompressionGzip = 2     // Compression type identifier for gzip
+	PMTilesTileTypeMVT     = 1     // Tile type identifier for Mapbox Vector Tiles
+)
+
+// Esri JSON constants
+const (
+	EsriWKIDWGS84             = 4326 // Well-known ID of WGS84, the spatial reference of fetched features
+	DefaultEsriStringLength   = 255  // Length of inferred string fields
+	EsriGeometryPoint         = "esriGeometryPoint"
+	EsriGeometryMultipoint    = "esriGeometryMultipoint"
+	EsriGeometryPolyline      = "esriGeometryPolyline"
+	EsriGeometryPolygon       = "esriGeometryPolygon"
+	EsriFieldTypeOID          = "esriFieldTypeOID"
+	EsriFieldTypeString       = "esriFieldTypeString"
+	EsriFieldTypeInteger      = "esriFieldTypeInteger"
+	EsriFieldTypeSmallInteger = "esriFieldTypeSmallInteger"
+	EsriFieldTypeDouble       = "esriFieldTypeDouble"
+	EsriFieldTypeDate         = "esriFieldTypeDate"
+)
+
+// GML constants
+const (
+	GMLPrefix          = "au"
+	GMLNamespace       = "https://github.com/Sudo-Ivan/arcgis-utils"
+	GMLNamespaceGML    = "http://www.opengis.net/gml/3.2"
+	GMLSchemaGML       = "http://schemas.opengis.net/gml/3.2.1/gml.xsd"
+	GMLGeometryElement = "geometry"
+	GMLSRSNameFormat   = "http://www.opengis.net/def/crs/EPSG/0/%d"
+	MinGeographicEPSG  = 4000 // EPSG codes in this range are geographic CRSs with latitude first
+	MaxGeographicEPSG  = 4999
+)
+
+// KMZ constants
+const (
+	KMZDocument = "doc.kml" // Root KML document inside a KMZ archive
+	KMZFilesDir = "files"   // Folder for symbol images inside a KMZ archive
+)
+
+// Symbol rendering constants
+const (
+	ScreenDPI           = 96.0       // Pixels per inch assumed when converting symbol sizes
+	PointsPerInch       = 72.0       // Esri symbol sizes and widths are in points
+	KMLIconSize         = 32.0       // Icon size in pixels that KML displays at scale 1
+	DefaultMarkerSize   = 8.0        // Marker size in points when a symbol has none
+	DefaultLineWidth    = 1.5        // Line width in points when a symbol has none
+	MarkerStrokeRatio   = 8.0        // Minimum cross and x stroke width as a fraction of the size
+	MarkerSupersampling = 4          // Samples per pixel axis for anti-aliased markers
+	KMLTransparent      = "00000000" // KML color for symbols without a color
+	KMLDefaultLineColor = "ff000000" // KML color for lines without a color
+)
+
+// KML schema constants
+const (
+	KMLSchemaIDSuffix = "_schema"
+)
+
+// GPX constants
+const (
+	EarthRadiusMeters      = 6371008.8
+	GPXPolygonBoundary     = "boundary"
+	GPXPolygonRings        = "rings"
+	GPXPolygonCentroid     = "centroid"
+	GPXExtensionsPrefix    = "arcgis"
+	GPXExtensionsNamespace = "https://github.com/Sudo-Ivan/arcgis-utils/gpx/1"
+)
+
+// KML label constants
+const (
+	KMLLabelBaseSize     = 10.0
+	KMLDefaultLabelColor = "ffffffff"
+)
+
+// Vector tile memory constants
+const (
+	TileCountWarning = 100000 // Estimated tile count above which tiling warns about memory use
+)
@@ -0,0 +1,216 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package export provides functions for converting GeoJSON data to various export formats.
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/arcgis"
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/convert"
+)
+
+// EsriFeatureSet represents an Esri JSON FeatureSet as returned by a layer query
+// and accepted by applyEdits, addFeatures and ArcPy's JSONToFeatures.
+type EsriFeatureSet struct {
+	DisplayFieldName  string                   `json:"displayFieldName,omitempty"`
+	ObjectIDFieldName string                   `json:"objectIdFieldName,omitempty"`
+	GeometryType      string                   `json:"geometryType,omitempty"`
+	SpatialReference  *arcgis.SpatialReference `json:"spatialReference,omitempty"`
+	Fields            []arcgis.Field           `json:"fields"`
+	Features          []EsriFeature            `json:"features"`
+}
+
+// EsriFeature represents a single feature of an Esri JSON FeatureSet.
+type EsriFeature struct {
+	Attributes map[string]interface{} `json:"attributes"`
+	Geometry   interface{}            `json:"geometry,omitempty"`
+}
+
+// ConvertFeaturesToEsriJSON converts ArcGIS features to an Esri JSON FeatureSet.
+// The function handles:
+//   - Field definitions taken from the layer metadata, including aliases and domains
+//   - Field definitions inferred from attribute values when no metadata is available
+//   - Geometry type taken from the layer or inferred from the first geometry
+//   - Removal of symbol information, which is not part of a FeatureSet
+//
+// Geometries are expected in WGS84, as returned by the feature query.
+//
+// Parameters:
+//   - features: Slice of features with Esri JSON geometries
+//   - layer: Layer metadata providing the schema, or nil to infer it
+//
+// Returns:
+//   - string: Esri JSON FeatureSet
+//   - error: Any error that occurred during the conversion
+func ConvertFeaturesToEsriJSON(features []convert.Feature, layer *arcgis.Layer) (string, error) {
+	featureSet := BuildEsriFeatureSet(features, layer)
+	data, err := json.MarshalIndent(featureSet, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal Esri JSON: %v", err)
+	}
+	return string(data), nil
+}
+
+// BuildEsriFeatureSet assembles an Esri JSON FeatureSet from features and optional layer metadata.
+//
+// Parameters:
+//   - features: Slice of features with Esri JSON geometries in WGS84
+//   - layer: Layer metadata providing the schema, or nil to infer it
+//
+// Returns:
+//   - *EsriFeatureSet: The assembled FeatureSet
+func BuildEsriFeatureSet(features []convert.Feature, layer *arcgis.Layer) *EsriFeatureSet {
+	featureSet := &EsriFeatureSet{
+		SpatialReference: &arcgis.SpatialReference{WKID: EsriWKIDWGS84, LatestWKID: EsriWKIDWGS84},
+		Features:         make([]EsriFeature, 0, len(features)),
+	}
+	if layer != nil {
+		featureSet.DisplayFieldName = layer.DisplayField
+		featureSet.ObjectIDFieldName = layer.ObjectIDField
+		featureSet.GeometryType = layer.GeometryType
+		featureSet.Fields = layer.Fields
+	}
+
+	for _, feature := range features {
+		attributes := make(map[string]interface{}, len(feature.Attributes))
+		for key, value := range feature.Attributes {
+			if key == KeySymbol {
+				continue
+			}
+			// Esri fields have no boolean type; store flags as small integers.
+			if flag, ok := value.(bool); ok {
+				value = 0
+				if flag {
+					value = 1
+				}
+			}
+			attributes[key] = value
+		}
+		featureSet.Features = append(featureSet.Features, EsriFeature{Attributes: attributes, Geometry: feature.Geometry})
+		if featureSet.GeometryType == "" {
+			featureSet.GeometryType = esriGeometryType(feature.Geometry)
+		}
+	}
+
+	if len(featureSet.Fields) == 0 {
+		attributes := make([]map[string]interface{}, len(featureSet.Features))
+		for i, feature := range featureSet.Features {
+			attributes[i] = feature.Attributes
+		}
+		featureSet.Fields = inferEsriFields(attributes)
+	}
+	if featureSet.ObjectIDFieldName == "" {
+		for _, field := range featureSet.Fields {
+			if field.Type == EsriFieldTypeOID {
+				featureSet.ObjectIDFieldName = field.Name
+				break
+			}
+		}
+	}
+	return featureSet
+}
+
+// esriGeometryType returns the Esri geometry type of an Esri JSON geometry, or "" if unknown.
+func esriGeometryType(geometry interface{}) string {
+	geom, ok := geometry.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	switch {
+	case geom["x"] != nil:
+		return EsriGeometryPoint
+	case geom["points"] != nil:
+		return EsriGeometryMultipoint
+	case geom["paths"] != nil:
+		return EsriGeometryPolyline
+	case geom["rings"] != nil:
+		return EsriGeometryPolygon
+	}
+	return ""
+}
+
+// inferEsriFields derives field definitions from attribute values, ignoring symbol information.
+// Fields are ordered with the object ID first and the rest by name; a field whose values
+// are all integral numbers becomes an integer field, other numbers become doubles.
+func inferEsriFields(features []map[string]interface{}) []arcgis.Field {
+	type fieldStats struct {
+		isString, isNumber, fractional, large bool
+		maxLength                             int
+	}
+	stats := make(map[string]*fieldStats)
+	for _, attributes := range features {
+		for key, value := range attributes {
+			if key == KeySymbol {
+				continue
+			}
+			s, ok := stats[key]
+			if !ok {
+				s = &fieldStats{}
+				stats[key] = s
+			}
+			switch v := value.(type) {
+			case string:
+				s.isString = true
+				if len(v) > s.maxLength {
+					s.maxLength = len(v)
+				}
+			case float64:
+				s.isNumber = true
+				if v != math.Trunc(v) {
+					s.fractional = true
+				} else if v > math.MaxInt32 || v < math.MinInt32 {
+					s.large = true
+				}
+			case bool, int, int32, int64:
+				s.isNumber = true
+			case json.Number:
+				s.isNumber = true
+				if _, err := v.Int64(); err != nil {
+					s.fractional = true
+				}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == KeyOBJECTID) != (names[j] == KeyOBJECTID) {
+			return names[i] == KeyOBJECTID
+		}
+		return names[i] < names[j]
+	})
+
+	fields := make([]arcgis.Field, 0, len(names))
+	for _, name := range names {
+		s := stats[name]
+		field := arcgis.Field{Name: name, Alias: name}
+		switch {
+		case name == KeyOBJECTID && s.isNumber && !s.isString:
+			field.Type = EsriFieldTypeOID
+		case s.isString:
+			field.Type = EsriFieldTypeString
+			field.Length = DefaultEsriStringLength
+			if s.maxLength > field.Length {
+				field.Length = s.maxLength
+			}
+		case s.fractional || s.large:
+			field.Type = EsriFieldTypeDouble
+		case s.isNumber:
+			field.Type = EsriFieldTypeInteger
+		default:
+			// Only null values were seen.
+			field.Type = EsriFieldTypeString
+			field.Length = DefaultEsriStringLength
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
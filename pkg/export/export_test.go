@@ -1,9 +1,24 @@
 package export
 
 import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"image/png"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/arcgis"
 	"github.com/Sudo-Ivan/arcgis-utils/pkg/convert"
 )
 
@@ -51,6 +66,7 @@ func TestGetFeatureName(t *testing.T) {
 		{"Only Title Field", convert.GeoJSONFeature{Properties: map[string]interface{}{"title": "Only Title", "OBJECTID": 2}}, "Only Title"},
 		{"Only OBJECTID Field", convert.GeoJSONFeature{Properties: map[string]interface{}{"OBJECTID": 3}}, "3"},
 		{"Only FID Field", convert.GeoJSONFeature{Properties: map[string]interface{}{"FID": 4}}, "4"},
+		{"Large OBJECTID Field", convert.GeoJSONFeature{Properties: map[string]interface{}{"OBJECTID": 1234567.0}}, "1234567"},
 		{"No Name/Title/ID Fields", convert.GeoJSONFeature{Properties: map[string]interface{}{"attribute1": "value1"}}, "Feature"},
 		{"Empty Properties", convert.GeoJSONFeature{Properties: map[string]interface{}{}}, "Feature"},
 		{"Nil Properties", convert.GeoJSONFeature{Properties: nil}, "Feature"},
@@ -81,6 +97,7 @@ func TestFormatProperties(t *testing.T) {
 		{"Nil Properties", nil, []string{}, ""},
 		{"Geometry Property Ignored", map[string]interface{}{"Name": "Test", "geometry": map[string]interface{}{}}, []string{}, "<strong>Name</strong>: Test"},
 		{"Numeric Property", map[string]interface{}{"Count": 10.5}, []string{}, "<strong>Count</strong>: 10.5"},
+		{"Null Property Skipped", map[string]interface{}{"Name": "Test", "Note": nil}, []string{}, "<strong>Name</strong>: Test"},
 	}
 
 	for _, tt := range tests {
@@ -118,3 +135,1197 @@ func TestFormatProperties(t *testing.T) {
 		})
 	}
 }
+
+func TestConvertLayersToTopoJSON(t *testing.T) {
+	square := func(x0, y0 float64) map[string]interface{} {
+		return map[string]interface{}{
+			"type": "Polygon",
+			"coordinates": [][][]float64{{
+				{x0, y0}, {x0 + 1, y0}, {x0 + 1, y0 + 1}, {x0, y0 + 1}, {x0, y0},
+			}},
+		}
+	}
+	parcels := &convert.GeoJSON{Features: []convert.GeoJSONFeature{
+		{Type: "Feature", Properties: map[string]interface{}{"name": "A", "symbol": "ignored"}, Geometry: square(0, 0)},
+		{Type: "Feature", Properties: map[string]interface{}{"name": "B"}, Geometry: square(1, 0)},
+	}}
+	points := &convert.GeoJSON{Features: []convert.GeoJSONFeature{
+		{Type: "Feature", Properties: map[string]interface{}{"name": "P"}, Geometry: map[string]interface{}{"type": "Point", "coordinates": []float64{2, 1}}},
+	}}
+
+	out, err := ConvertLayersToTopoJSON([]TopoJSONLayer{{Name: "parcels", GeoJSON: parcels}, {Name: "points", GeoJSON: points}}, 3)
+	if err != nil {
+		t.Fatalf("ConvertLayersToTopoJSON failed: %v", err)
+	}
+
+	var topo struct {
+		Type      string `json:"type"`
+		Transform struct {
+			Scale     []float64 `json:"scale"`
+			Translate []float64 `json:"translate"`
+		} `json:"transform"`
+		Objects map[string]struct {
+			Geometries []struct {
+				Type        string                 `json:"type"`
+				Arcs        [][]int                `json:"arcs"`
+				Coordinates []int                  `json:"coordinates"`
+				Properties  map[string]interface{} `json:"properties"`
+			} `json:"geometries"`
+		} `json:"objects"`
+		Arcs [][][2]int `json:"arcs"`
+	}
+	if err := json.Unmarshal([]byte(out), &topo); err != nil {
+		t.Fatalf("Failed to parse TopoJSON output: %v", err)
+	}
+
+	if topo.Type != "Topology" {
+		t.Errorf("Expected type 'Topology', got %q", topo.Type)
+	}
+	if topo.Transform.Scale[0] != 1 || topo.Transform.Scale[1] != 0.5 {
+		t.Errorf("Unexpected transform scale %v", topo.Transform.Scale)
+	}
+	// Two squares sharing one edge: the shared edge plus one outer arc per square.
+	if len(topo.Arcs) != 3 {
+		t.Fatalf("Expected 3 arcs, got %d: %v", len(topo.Arcs), topo.Arcs)
+	}
+
+	parcelGeoms := topo.Objects["parcels"].Geometries
+	if len(parcelGeoms) != 2 {
+		t.Fatalf("Expected 2 parcel geometries, got %d", len(parcelGeoms))
+	}
+	shared := map[int]bool{}
+	for _, idx := range parcelGeoms[0].Arcs[0] {
+		shared[idx] = true
+	}
+	foundShared := false
+	for _, idx := range parcelGeoms[1].Arcs[0] {
+		if idx < 0 && shared[^idx] {
+			foundShared = true
+		}
+	}
+	if !foundShared {
+		t.Errorf("Expected second polygon to reuse a reversed arc of the first, got %v and %v", parcelGeoms[0].Arcs, parcelGeoms[1].Arcs)
+	}
+	if _, ok := parcelGeoms[0].Properties["symbol"]; ok {
+		t.Errorf("Expected symbol property to be excluded")
+	}
+
+	pointGeoms := topo.Objects["points"].Geometries
+	if len(pointGeoms) != 1 || pointGeoms[0].Type != "Point" {
+		t.Fatalf("Expected a single Point in the points object, got %+v", pointGeoms)
+	}
+	if pointGeoms[0].Coordinates[0] != 2 || pointGeoms[0].Coordinates[1] != 2 {
+		t.Errorf("Expected quantized point [2 2], got %v", pointGeoms[0].Coordinates)
+	}
+
+	if _, err := ConvertLayersToTopoJSON(nil, 1); err == nil {
+		t.Error("Expected error for quantization below the minimum")
+	}
+}
+
+func TestGenerateVectorTiles(t *testing.T) {
+	geoJSON := &convert.GeoJSON{Features: []convert.GeoJSONFeature{
+		{Type: "Feature", Properties: map[string]interface{}{"OBJECTID": 1.0, "name": "Square", "symbol": "ignored"}, Geometry: map[string]interface{}{
+			"type":        "Polygon",
+			"coordinates": [][][]float64{{{-10, -10}, {10, -10}, {10, 10}, {-10, 10}, {-10, -10}}},
+		}},
+		{Type: "Feature", Properties: map[string]interface{}{"OBJECTID": 2.0, "active": true}, Geometry: map[string]interface{}{
+			"type":        "Point",
+			"coordinates": []float64{-122.4, 37.8},
+		}},
+	}}
+
+	tileSet, err := GenerateVectorTiles(geoJSON, "test", TileOptions{MinZoom: 0, MaxZoom: 2})
+	if err != nil {
+		t.Fatalf("GenerateVectorTiles failed: %v", err)
+	}
+
+	if _, ok := tileSet.Tiles[TileID{Z: 0, X: 0, Y: 0}]; !ok {
+		t.Errorf("Expected tile 0/0/0 to be generated")
+	}
+	// The square straddles the origin, so it touches all four tiles at zoom 1.
+	for _, id := range []TileID{{1, 0, 0}, {1, 1, 0}, {1, 0, 1}, {1, 1, 1}} {
+		if _, ok := tileSet.Tiles[id]; !ok {
+			t.Errorf("Expected tile %v to be generated", id)
+		}
+	}
+	if _, ok := tileSet.Tiles[TileID{Z: 2, X: 3, Y: 3}]; ok {
+		t.Errorf("Did not expect an empty tile 2/3/3 to be generated")
+	}
+
+	expectedFields := map[string]string{"OBJECTID": "Number", "name": "String", "active": "Boolean"}
+	if len(tileSet.Fields) != len(expectedFields) {
+		t.Errorf("Expected fields %v, got %v", expectedFields, tileSet.Fields)
+	}
+	for k, v := range expectedFields {
+		if tileSet.Fields[k] != v {
+			t.Errorf("Field %s: expected type %s, got %s", k, v, tileSet.Fields[k])
+		}
+	}
+
+	filtered, err := GenerateVectorTiles(geoJSON, "test", TileOptions{MinZoom: 0, MaxZoom: 0, Attributes: []string{"name"}})
+	if err != nil {
+		t.Fatalf("GenerateVectorTiles with attribute filter failed: %v", err)
+	}
+	if len(filtered.Fields) != 1 || filtered.Fields["name"] != "String" {
+		t.Errorf("Expected only the name field after filtering, got %v", filtered.Fields)
+	}
+
+	if _, err := GenerateVectorTiles(geoJSON, "test", TileOptions{MinZoom: 5, MaxZoom: 2}); err == nil {
+		t.Error("Expected error for inverted zoom range")
+	}
+}
+
+func TestEncodeMBTiles(t *testing.T) {
+	tileSet := &TileSet{
+		LayerName: "test",
+		MinZoom:   0,
+		MaxZoom:   1,
+		Bounds:    [4]float64{-10, -10, 10, 10},
+		Fields:    map[string]string{"name": "String"},
+		Tiles: map[TileID][]byte{
+			{Z: 0, X: 0, Y: 0}: []byte("tile-0"),
+			{Z: 1, X: 1, Y: 0}: []byte("tile-1"),
+		},
+	}
+
+	data, err := EncodeMBTiles(tileSet)
+	if err != nil {
+		t.Fatalf("EncodeMBTiles failed: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "SQLite format 3\x00") {
+		t.Fatalf("Expected SQLite file header, got %q", data[:16])
+	}
+	if len(data)%SQLitePageSize != 0 {
+		t.Errorf("Expected file size to be a multiple of the page size, got %d", len(data))
+	}
+	if got := string(data[68:72]); got != "MPBX" {
+		t.Errorf("Expected MBTiles application id MPBX, got %q", got)
+	}
+	if !strings.Contains(string(data), "CREATE UNIQUE INDEX tile_index") {
+		t.Error("Expected tile index in schema")
+	}
+}
+
+func TestEncodePMTiles(t *testing.T) {
+	tileSet := &TileSet{
+		LayerName: "test",
+		MinZoom:   0,
+		MaxZoom:   1,
+		Bounds:    [4]float64{-10, -10, 10, 10},
+		Fields:    map[string]string{"name": "String"},
+		Tiles: map[TileID][]byte{
+			{Z: 0, X: 0, Y: 0}: []byte("tile-0"),
+			{Z: 1, X: 0, Y: 1}: []byte("same"),
+			{Z: 1, X: 1, Y: 1}: []byte("same"),
+		},
+	}
+
+	data, err := EncodePMTiles(tileSet)
+	if err != nil {
+		t.Fatalf("EncodePMTiles failed: %v", err)
+	}
+	if string(data[:7]) != "PMTiles" || data[7] != PMTilesVersion {
+		t.Fatalf("Expected PMTiles v3 header, got %q", data[:8])
+	}
+	if got := binary.LittleEndian.Uint64(data[8:]); got != PMTilesHeaderSize {
+		t.Errorf("Expected root directory right after the header, got offset %d", got)
+	}
+	// Tiles 1/0/1 and 1/1/1 are adjacent on the Hilbert curve and identical, so they share one run.
+	if got := binary.LittleEndian.Uint64(data[72:]); got != 3 {
+		t.Errorf("Expected 3 addressed tiles, got %d", got)
+	}
+	if got := binary.LittleEndian.Uint64(data[80:]); got != 2 {
+		t.Errorf("Expected 2 tile entries, got %d", got)
+	}
+	if got := binary.LittleEndian.Uint64(data[88:]); got != 2 {
+		t.Errorf("Expected 2 tile contents, got %d", got)
+	}
+	dataOffset := binary.LittleEndian.Uint64(data[56:])
+	dataLength := binary.LittleEndian.Uint64(data[64:])
+	if dataOffset+dataLength != uint64(len(data)) {
+		t.Errorf("Expected tile data to end the archive, got %d+%d for %d bytes", dataOffset, dataLength, len(data))
+	}
+	if got := int32(binary.LittleEndian.Uint32(data[102:])); got != -100000000 {
+		t.Errorf("Expected min longitude -100000000, got %d", got)
+	}
+}
+
+func TestZxyToTileID(t *testing.T) {
+	tests := []struct {
+		z, x, y int
+		want    uint64
+	}{
+		{0, 0, 0, 0},
+		{1, 0, 0, 1},
+		{1, 0, 1, 2},
+		{1, 1, 1, 3},
+		{1, 1, 0, 4},
+		{2, 0, 0, 5},
+	}
+	for _, tt := range tests {
+		if got := zxyToTileID(tt.z, tt.x, tt.y); got != tt.want {
+			t.Errorf("zxyToTileID(%d, %d, %d) = %d, want %d", tt.z, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestConvertFeaturesToEsriJSON(t *testing.T) {
+	features := []convert.Feature{
+		{
+			Attributes: map[string]interface{}{
+				"OBJECTID": float64(1),
+				"name":     "A",
+				"height":   2.5,
+				"active":   true,
+				"symbol":   &arcgis.Symbol{Type: "esriPMS"},
+			},
+			Geometry: map[string]interface{}{"x": 10.0, "y": 20.0},
+		},
+	}
+
+	t.Run("Inferred schema", func(t *testing.T) {
+		data, err := ConvertFeaturesToEsriJSON(features, nil)
+		if err != nil {
+			t.Fatalf("ConvertFeaturesToEsriJSON failed: %v", err)
+		}
+		var fs EsriFeatureSet
+		if err := json.Unmarshal([]byte(data), &fs); err != nil {
+			t.Fatalf("Invalid JSON output: %v", err)
+		}
+		if fs.GeometryType != EsriGeometryPoint {
+			t.Errorf("Expected geometry type %s, got %s", EsriGeometryPoint, fs.GeometryType)
+		}
+		if fs.SpatialReference == nil || fs.SpatialReference.WKID != EsriWKIDWGS84 {
+			t.Errorf("Expected WGS84 spatial reference, got %+v", fs.SpatialReference)
+		}
+		if fs.ObjectIDFieldName != "OBJECTID" {
+			t.Errorf("Expected object ID field OBJECTID, got %q", fs.ObjectIDFieldName)
+		}
+		wantTypes := map[string]string{
+			"OBJECTID": EsriFieldTypeOID,
+			"active":   EsriFieldTypeInteger,
+			"height":   EsriFieldTypeDouble,
+			"name":     EsriFieldTypeString,
+		}
+		if len(fs.Fields) != len(wantTypes) || fs.Fields[0].Name != "OBJECTID" {
+			t.Fatalf("Unexpected fields: %+v", fs.Fields)
+		}
+		for _, field := range fs.Fields {
+			if field.Type != wantTypes[field.Name] {
+				t.Errorf("Field %s: expected type %s, got %s", field.Name, wantTypes[field.Name], field.Type)
+			}
+		}
+		if _, ok := fs.Features[0].Attributes["symbol"]; ok {
+			t.Error("Expected symbol to be removed from attributes")
+		}
+	})
+
+	t.Run("Layer schema", func(t *testing.T) {
+		layer := &arcgis.Layer{
+			GeometryType:  EsriGeometryPoint,
+			ObjectIDField: "OBJECTID",
+			Fields: []arcgis.Field{
+				{Name: "OBJECTID", Type: EsriFieldTypeOID, Alias: "Object ID"},
+				{Name: "status", Type: "esriFieldTypeSmallInteger", Alias: "Status", Domain: &arcgis.Domain{
+					Type:        "codedValue",
+					Name:        "StatusDomain",
+					CodedValues: []arcgis.CodedValue{{Name: "Open", Code: 1}},
+				}},
+			},
+		}
+		data, err := ConvertFeaturesToEsriJSON(features, layer)
+		if err != nil {
+			t.Fatalf("ConvertFeaturesToEsriJSON failed: %v", err)
+		}
+		for _, want := range []string{`"alias": "Status"`, `"codedValues"`, `"StatusDomain"`} {
+			if !strings.Contains(data, want) {
+				t.Errorf("Expected output to contain %s", want)
+			}
+		}
+	})
+}
+
+func TestConvertGeoJSONToGML(t *testing.T) {
+	geoJSON := &convert.GeoJSON{Features: []convert.GeoJSONFeature{
+		{
+			Type:       "Feature",
+			Properties: map[string]interface{}{"OBJECTID": float64(1), "status": float64(1), "name": "A & B"},
+			Geometry: map[string]interface{}{
+				"type": "MultiPolygon",
+				// Exterior rings in Esri (clockwise) order.
+				"coordinates": [][][][]float64{
+					{{{0, 0}, {0, 1}, {1, 1}, {1, 0}, {0, 0}}},
+					{{{5, 5}, {5, 6}, {6, 6}, {6, 5}, {5, 5}}},
+				},
+			},
+		},
+		{
+			Type:       "Feature",
+			Properties: map[string]interface{}{"OBJECTID": float64(2)},
+			Geometry: map[string]interface{}{
+				"type":        "Polygon",
+				"coordinates": [][][]float64{{{10, 20}, {10, 21}, {11, 21}, {11, 20}, {10, 20}}},
+			},
+		},
+	}}
+	opts := GMLOptions{
+		Fields: []arcgis.Field{
+			{Name: "OBJECTID", Type: EsriFieldTypeOID},
+			{Name: "status", Type: EsriFieldTypeSmallInteger, Alias: "Status", Domain: &arcgis.Domain{
+				Type: "codedValue", Name: "StatusDomain", CodedValues: []arcgis.CodedValue{{Name: "Open", Code: 1}},
+			}},
+			{Name: "name", Type: EsriFieldTypeString, Length: 50},
+			{Name: "Shape", Type: "esriFieldTypeGeometry"},
+		},
+		SchemaLocation: "Parcels.xsd",
+	}
+
+	gml, err := ConvertGeoJSONToGML(geoJSON, "Parcel Areas", opts)
+	if err != nil {
+		t.Fatalf("ConvertGeoJSONToGML failed: %v", err)
+	}
+	if err := xml.Unmarshal([]byte(gml), new(interface{})); err != nil {
+		t.Fatalf("GML is not well-formed: %v", err)
+	}
+	for _, want := range []string{
+		`xsi:schemaLocation="` + GMLNamespace + ` Parcels.xsd`,
+		`<au:Parcel_Areas gml:id="Parcel_Areas.1">`,
+		`srsName="http://www.opengis.net/def/crs/EPSG/0/4326"`,
+		`<gml:surfaceMember><gml:Polygon gml:id="Parcel_Areas.1.geom.2">`,
+		// The single polygon is promoted to match the MultiSurface schema type.
+		`<gml:MultiSurface gml:id="Parcel_Areas.2.geom"`,
+		// Latitude first and the exterior ring reversed to counter-clockwise.
+		`<gml:posList>20 10 20 11 21 11 21 10 20 10</gml:posList>`,
+		`<au:name>A &amp; B</au:name>`,
+	} {
+		if !strings.Contains(gml, want) {
+			t.Errorf("Expected GML to contain %s", want)
+		}
+	}
+	if strings.Contains(gml, "<au:Shape>") {
+		t.Error("Expected geometry fields to be left out of the attributes")
+	}
+
+	schema, err := GenerateGMLSchema(geoJSON, "Parcel Areas", opts)
+	if err != nil {
+		t.Fatalf("GenerateGMLSchema failed: %v", err)
+	}
+	if err := xml.Unmarshal([]byte(schema), new(interface{})); err != nil {
+		t.Fatalf("Schema is not well-formed: %v", err)
+	}
+	for _, want := range []string{
+		`<xs:element name="Parcel_Areas" type="au:Parcel_AreasType" substitutionGroup="gml:AbstractFeature"/>`,
+		`type="gml:MultiSurfacePropertyType"`,
+		`<xs:element name="OBJECTID" type="xs:long"`,
+		`<xs:enumeration value="1">`,
+		`<xs:maxLength value="50"/>`,
+	} {
+		if !strings.Contains(schema, want) {
+			t.Errorf("Expected schema to contain %s", want)
+		}
+	}
+}
+
+func TestKMLFolders(t *testing.T) {
+	point := func(kind string) convert.GeoJSONFeature {
+		return convert.GeoJSONFeature{
+			Type:       "Feature",
+			Properties: map[string]interface{}{"Name": kind + " site", "KIND": kind},
+			Geometry:   map[string]interface{}{"type": "Point", "coordinates": []float64{1, 2}},
+		}
+	}
+	byKind := func(feature *convert.GeoJSONFeature) string {
+		kind, _ := feature.Properties["KIND"].(string)
+		return kind
+	}
+
+	type folder struct {
+		Name       string   `xml:"name"`
+		Placemarks []string `xml:"Placemark>name"`
+		Folders    []folder `xml:"Folder"`
+	}
+	var flatten func(f folder, prefix string, out *[]string)
+	flatten = func(f folder, prefix string, out *[]string) {
+		if len(f.Placemarks) > 0 {
+			*out = append(*out, prefix+": "+strings.Join(f.Placemarks, ","))
+		}
+		for _, child := range f.Folders {
+			flatten(child, prefix+"/"+child.Name, out)
+		}
+	}
+	// folderTree flattens a KML document into "path: placemark names" entries.
+	folderTree := func(t *testing.T, kml string) []string {
+		var doc struct {
+			Document folder `xml:"Document"`
+		}
+		if err := xml.Unmarshal([]byte(kml), &doc); err != nil {
+			t.Fatalf("Invalid KML: %v", err)
+		}
+		var out []string
+		flatten(doc.Document, "", &out)
+		return out
+	}
+
+	layer := &convert.GeoJSON{Type: "FeatureCollection", Features: []convert.GeoJSONFeature{point("Well"), point("Tank"), point("Well"), point("")}}
+	kml, err := ConvertGeoJSONToKMLWithOptions(layer, "Assets", KMLOptions{FolderPath: []string{"Utilities", "Water"}, FolderFor: byKind})
+	if err != nil {
+		t.Fatalf("ConvertGeoJSONToKMLWithOptions failed: %v", err)
+	}
+	want := []string{"/Utilities/Water:  site", "/Utilities/Water/Well: Well site,Well site", "/Utilities/Water/Tank: Tank site"}
+	if got := folderTree(t, kml); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Single layer folders = %q, want %q", got, want)
+	}
+
+	other := &convert.GeoJSON{Type: "FeatureCollection", Features: []convert.GeoJSONFeature{point("Pump")}}
+	kml, err = ConvertLayersToKML("Merged", []KMLLayer{
+		{Name: "Assets", GeoJSON: layer, FolderPath: []string{"Utilities"}},
+		{Name: "Pumps", GeoJSON: other, FolderPath: []string{"Utilities"}, FolderFor: byKind},
+		{Name: "Other", GeoJSON: other},
+	}, KMLOptions{})
+	if err != nil {
+		t.Fatalf("ConvertLayersToKML failed: %v", err)
+	}
+	want = []string{"/Utilities/Assets: Well site,Tank site,Well site, site", "/Utilities/Pumps/Pump: Pump site", "/Other: Pump site"}
+	if got := folderTree(t, kml); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Merged layer folders = %q, want %q", got, want)
+	}
+}
+
+func TestKMLExtendedData(t *testing.T) {
+	geoJSON := &convert.GeoJSON{Type: "FeatureCollection", Features: []convert.GeoJSONFeature{{
+		Type:       "Feature",
+		Properties: map[string]interface{}{"OBJECTID": 7.0, "NAME": "Tom's <Diner>", "RATING": 4.5, "NOTES": nil, "symbol": "ignored"},
+		Geometry:   map[string]interface{}{"type": "Point", "coordinates": []float64{1, 2}},
+	}}}
+	fields := []arcgis.Field{
+		{Name: "OBJECTID", Type: EsriFieldTypeOID},
+		{Name: "NAME", Type: EsriFieldTypeString, Alias: "Business name"},
+		{Name: "RATING", Type: EsriFieldTypeDouble},
+		{Name: "NOTES", Type: EsriFieldTypeString},
+		{Name: "SHAPE", Type: "esriFieldTypeGeometry"},
+	}
+	template := "<h3>$[NAME]</h3><p>$[RATING] stars $[NOTES]</p>$[geDirections]]]>"
+	kml, err := ConvertGeoJSONToKMLWithOptions(geoJSON, "Places", KMLOptions{Fields: fields, BalloonTemplate: template})
+	if err != nil {
+		t.Fatalf("ConvertGeoJSONToKMLWithOptions failed: %v", err)
+	}
+
+	var doc struct {
+		Document struct {
+			Schema struct {
+				ID     string `xml:"id,attr"`
+				Fields []struct {
+					Name        string `xml:"name,attr"`
+					Type        string `xml:"type,attr"`
+					DisplayName string `xml:"displayName"`
+				} `xml:"SimpleField"`
+			} `xml:"Schema"`
+			Placemark struct {
+				Description string `xml:"description"`
+				SchemaData  struct {
+					SchemaURL string `xml:"schemaUrl,attr"`
+					Data      []struct {
+						Name  string `xml:"name,attr"`
+						Value string `xml:",chardata"`
+					} `xml:"SimpleData"`
+				} `xml:"ExtendedData>SchemaData"`
+			} `xml:"Placemark"`
+		} `xml:"Document"`
+	}
+	if err := xml.Unmarshal([]byte(kml), &doc); err != nil {
+		t.Fatalf("Invalid KML: %v", err)
+	}
+
+	schema := doc.Document.Schema
+	var schemaFields []string
+	for _, f := range schema.Fields {
+		schemaFields = append(schemaFields, f.Name+":"+f.Type+":"+f.DisplayName)
+	}
+	if got, want := strings.Join(schemaFields, ","), "OBJECTID:int:,NAME:string:Business name,RATING:double:,NOTES:string:"; got != want {
+		t.Errorf("Schema fields = %s, want %s", got, want)
+	}
+
+	placemark := doc.Document.Placemark
+	if placemark.SchemaData.SchemaURL != "#"+schema.ID || schema.ID != "Places_schema" {
+		t.Errorf("SchemaData refers to %q, schema ID is %q", placemark.SchemaData.SchemaURL, schema.ID)
+	}
+	var data []string
+	for _, d := range placemark.SchemaData.Data {
+		data = append(data, d.Name+"="+d.Value)
+	}
+	if got, want := strings.Join(data, ","), "OBJECTID=7,NAME=Tom's <Diner>,RATING=4.5"; got != want {
+		t.Errorf("SimpleData = %s, want %s", got, want)
+	}
+	if want := "<h3>Tom&#39;s &lt;Diner&gt;</h3><p>4.5 stars </p>$[geDirections]]]>"; placemark.Description != want {
+		t.Errorf("Description = %q, want %q", placemark.Description, want)
+	}
+}
+
+func TestTimeAwareKMLAndGPX(t *testing.T) {
+	geoJSON := &convert.GeoJSON{Type: "FeatureCollection", Features: []convert.GeoJSONFeature{
+		{
+			Type:       "Feature",
+			Properties: map[string]interface{}{"Name": "Report", "START": "2024-03-01T12:00:00Z"},
+			Geometry:   map[string]interface{}{"type": "Point", "coordinates": []float64{1, 2}},
+		},
+		{
+			Type:       "Feature",
+			Properties: map[string]interface{}{"Name": "Patrol", "START": 1709294400000.0, "END": 1709298000000.0},
+			Geometry:   map[string]interface{}{"type": "LineString", "coordinates": [][]float64{{0, 0}, {1, 0}, {3, 0}}},
+		},
+		{
+			Type:       "Feature",
+			Properties: map[string]interface{}{"Name": "Undated", "START": nil},
+			Geometry:   map[string]interface{}{"type": "Point", "coordinates": []float64{3, 4}},
+		},
+	}}
+
+	kml, err := ConvertGeoJSONToKMLWithOptions(geoJSON, "Incidents", KMLOptions{TimeField: "START", EndTimeField: "END"})
+	if err != nil {
+		t.Fatalf("ConvertGeoJSONToKMLWithOptions failed: %v", err)
+	}
+	for _, want := range []string{
+		"<TimeStamp><when>2024-03-01T12:00:00Z</when></TimeStamp>",
+		"<TimeSpan><begin>2024-03-01T12:00:00Z</begin><end>2024-03-01T13:00:00Z</end></TimeSpan>",
+	} {
+		if !strings.Contains(kml, want) {
+			t.Errorf("Expected KML to contain %s", want)
+		}
+	}
+	if strings.Count(kml, "<TimeStamp>")+strings.Count(kml, "<TimeSpan>") != 2 {
+		t.Error("Expected no time for the undated placemark")
+	}
+
+	gpx, err := ConvertGeoJSONToGPXWithOptions(geoJSON, "Incidents", GPXOptions{TimeField: "START", EndTimeField: "END"})
+	if err != nil {
+		t.Fatalf("ConvertGeoJSONToGPXWithOptions failed: %v", err)
+	}
+	var doc struct {
+		Waypoints []struct {
+			Time string `xml:"time"`
+		} `xml:"wpt"`
+		TrackPoints []struct {
+			Time string `xml:"time"`
+		} `xml:"trk>trkseg>trkpt"`
+	}
+	if err := xml.Unmarshal([]byte(gpx), &doc); err != nil {
+		t.Fatalf("Invalid GPX: %v", err)
+	}
+	if len(doc.Waypoints) != 2 || doc.Waypoints[0].Time != "2024-03-01T12:00:00Z" || doc.Waypoints[1].Time != "" {
+		t.Errorf("Unexpected waypoint times %+v", doc.Waypoints)
+	}
+	// The second point is a third of the way along the track.
+	want := []string{"2024-03-01T12:00:00Z", "2024-03-01T12:20:00Z", "2024-03-01T13:00:00Z"}
+	for i, pt := range doc.TrackPoints {
+		if i >= len(want) || pt.Time != want[i] {
+			t.Errorf("Trackpoint %d time = %s, want %v", i, pt.Time, want)
+		}
+	}
+}
+
+func TestKMLLabels(t *testing.T) {
+	geoJSON := &convert.GeoJSON{Type: "FeatureCollection", Features: []convert.GeoJSONFeature{
+		{
+			Type:       "Feature",
+			Properties: map[string]interface{}{"Name": "Unlabeled", "ROUTE": "A1"},
+			Geometry:   map[string]interface{}{"type": "Point", "coordinates": []float64{1, 2}},
+			Symbol:     &convert.Symbol{Type: "esriSMS", Style: "esriSMSCircle", Color: []int{255, 0, 0, 255}, Size: 8},
+		},
+		{
+			Type:       "Feature",
+			Properties: map[string]interface{}{"Name": "Plain", "ROUTE": "B2"},
+			Geometry:   map[string]interface{}{"type": "Point", "coordinates": []float64{3, 4}},
+		},
+	}}
+	opts := KMLOptions{
+		NameFor: func(feature *convert.GeoJSONFeature) string {
+			return "Route " + feature.Properties["ROUTE"].(string)
+		},
+		LabelColor: []int{0, 0, 255, 255},
+		LabelSize:  15,
+	}
+
+	kml, err := ConvertGeoJSONToKMLWithOptions(geoJSON, "Routes", opts)
+	if err != nil {
+		t.Fatalf("ConvertGeoJSONToKMLWithOptions failed: %v", err)
+	}
+	for _, want := range []string{"<name>Route A1</name>", "<name>Route B2</name>"} {
+		if !strings.Contains(kml, want) {
+			t.Errorf("Expected KML to contain %s", want)
+		}
+	}
+	if strings.Contains(kml, "<name>Unlabeled</name>") {
+		t.Error("Expected the label expression to replace the feature name")
+	}
+	// Both the symbol style and the default style carry the label style.
+	if got := strings.Count(kml, "<color>ffff0000</color>\n                <scale>1.5</scale>"); got != 2 {
+		t.Errorf("Expected 2 label styles with the text symbol color and scale, got %d", got)
+	}
+}
+
+func TestGPXOptions(t *testing.T) {
+	square := [][]float64{{0, 0}, {4, 0}, {4, 4}, {0, 4}, {0, 0}}
+	hole := [][]float64{{0, 0}, {0, 2}, {2, 2}, {2, 0}, {0, 0}}
+	geoJSON := &convert.GeoJSON{Type: "FeatureCollection", Features: []convert.GeoJSONFeature{
+		{
+			Type:       "Feature",
+			Properties: map[string]interface{}{"Name": "Hydrant", "TYPE": "Dry", "NOTE": nil},
+			Geometry:   map[string]interface{}{"type": "Point", "coordinates": []float64{1, 2}},
+		},
+		{
+			Type:       "Feature",
+			Properties: map[string]interface{}{"Name": "Trail"},
+			Geometry:   map[string]interface{}{"type": "MultiLineString", "coordinates": [][][]float64{{{0, 0}, {1, 1}}, {{2, 2}, {3, 3}}}},
+		},
+		{
+			Type:       "Feature",
+			Properties: map[string]interface{}{"Name": "Park"},
+			Geometry:   map[string]interface{}{"type": "Polygon", "coordinates": [][][]float64{square, hole}},
+		},
+	}}
+	type gpxDoc struct {
+		Waypoints []struct {
+			Lat        float64 `xml:"lat,attr"`
+			Lon        float64 `xml:"lon,attr"`
+			Name       string  `xml:"name"`
+			Sym        string  `xml:"sym"`
+			Attributes []struct {
+				Name  string `xml:"name,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"extensions>attributes>attribute"`
+		} `xml:"wpt"`
+		Routes []struct {
+			Points []struct{} `xml:"rtept"`
+		} `xml:"rte"`
+		Tracks []struct {
+			Name     string `xml:"name"`
+			Segments []struct {
+				Points []struct{} `xml:"trkpt"`
+			} `xml:"trkseg"`
+		} `xml:"trk"`
+	}
+	convertGPX := func(opts GPXOptions) gpxDoc {
+		t.Helper()
+		gpx, err := ConvertGeoJSONToGPXWithOptions(geoJSON, "Parks", opts)
+		if err != nil {
+			t.Fatalf("ConvertGeoJSONToGPXWithOptions failed: %v", err)
+		}
+		var doc gpxDoc
+		if err := xml.Unmarshal([]byte(gpx), &doc); err != nil {
+			t.Fatalf("Invalid GPX: %v", err)
+		}
+		return doc
+	}
+
+	doc := convertGPX(GPXOptions{})
+	if len(doc.Waypoints) != 1 || len(doc.Routes) != 0 || len(doc.Tracks) != 2 {
+		t.Fatalf("Expected 1 waypoint and 2 tracks by default, got %+v", doc)
+	}
+	if len(doc.Tracks[0].Segments) != 2 || doc.Tracks[1].Name != "Park (Boundary)" || len(doc.Tracks[1].Segments) != 1 {
+		t.Errorf("Expected a segment per line part and the polygon boundary, got %+v", doc.Tracks)
+	}
+	if doc.Waypoints[0].Sym != "" || len(doc.Waypoints[0].Attributes) != 0 {
+		t.Errorf("Expected no symbol or extensions by default, got %+v", doc.Waypoints[0])
+	}
+
+	doc = convertGPX(GPXOptions{
+		Routes:      true,
+		PolygonMode: GPXPolygonRings,
+		Extensions:  true,
+		SymFor: func(feature *convert.GeoJSONFeature) string {
+			return "Fire " + feature.Properties["Name"].(string)
+		},
+	})
+	if len(doc.Routes) != 2 || len(doc.Routes[0].Points) != 2 {
+		t.Errorf("Expected a route per line part, got %+v", doc.Routes)
+	}
+	if len(doc.Tracks) != 1 || len(doc.Tracks[0].Segments) != 2 {
+		t.Errorf("Expected a track segment per polygon ring, got %+v", doc.Tracks)
+	}
+	wpt := doc.Waypoints[0]
+	if wpt.Sym != "Fire Hydrant" {
+		t.Errorf("Waypoint sym = %q, want %q", wpt.Sym, "Fire Hydrant")
+	}
+	if len(wpt.Attributes) != 2 || wpt.Attributes[0].Name != "Name" || wpt.Attributes[1].Name != "TYPE" || wpt.Attributes[1].Value != "Dry" {
+		t.Errorf("Expected sorted non-null attribute extensions, got %+v", wpt.Attributes)
+	}
+
+	doc = convertGPX(GPXOptions{PolygonMode: GPXPolygonCentroid})
+	if len(doc.Tracks) != 1 || len(doc.Waypoints) != 2 {
+		t.Fatalf("Expected the polygon as a waypoint, got %+v", doc)
+	}
+	// The hole in the lower left quarter moves the centroid to (7/3, 7/3).
+	if c := doc.Waypoints[1]; math.Abs(c.Lon-7.0/3) > 1e-9 || math.Abs(c.Lat-7.0/3) > 1e-9 || c.Name != "Park" {
+		t.Errorf("Unexpected centroid waypoint %+v", c)
+	}
+
+	if _, err := ConvertGeoJSONToGPXWithOptions(geoJSON, "Parks", GPXOptions{PolygonMode: "hull"}); err == nil {
+		t.Error("Expected an error for an unsupported polygon mode")
+	}
+}
+
+// updateGolden rewrites the golden files in testdata/golden from the current output.
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+func TestGoldenOutputs(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "features.json"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	var layer arcgis.Layer
+	var featureSet struct {
+		Features []arcgis.Feature `json:"features"`
+	}
+	if err := json.Unmarshal(fixture, &layer); err != nil {
+		t.Fatalf("Invalid fixture: %v", err)
+	}
+	if err := json.Unmarshal(fixture, &featureSet); err != nil {
+		t.Fatalf("Invalid fixture: %v", err)
+	}
+	features := make([]convert.Feature, len(featureSet.Features))
+	for i, f := range featureSet.Features {
+		features[i] = convert.Feature{Attributes: f.Attributes, Geometry: f.Geometry}
+	}
+	exportFeatures := convert.FormatDateFields(features, []string{"OPENED"})
+	symbols := []*convert.Symbol{
+		{Type: "esriSMS", Style: "esriSMSSquare", Color: []int{0, 112, 255, 255}, Size: 10},
+		{Type: "esriSLS", Style: "esriSLSSolid", Color: []int{255, 0, 0, 255}, Width: 2},
+		{Type: "esriSFS", Style: "esriSFSSolid", Color: []int{0, 255, 0, 128}, Outline: &convert.Symbol{Type: "esriSLS", Color: []int{0, 0, 0, 255}, Width: 1}},
+	}
+	geoJSON := func() *convert.GeoJSON {
+		geo, err := convert.ToGeoJSON(exportFeatures)
+		if err != nil {
+			t.Fatalf("ToGeoJSON failed: %v", err)
+		}
+		for i := range geo.Features {
+			geo.Features[i].Symbol = symbols[i%len(symbols)]
+		}
+		return geo
+	}
+
+	tests := []struct {
+		file    string
+		convert func() ([]byte, error)
+	}{
+		{"stations.geojson", func() ([]byte, error) {
+			var out bytes.Buffer
+			err := convert.WriteGeoJSON(&out, geoJSON())
+			return out.Bytes(), err
+		}},
+		{"stations.esrijson", func() ([]byte, error) {
+			out, err := ConvertFeaturesToEsriJSON(features, &layer)
+			return []byte(out), err
+		}},
+		{"stations.csv", func() ([]byte, error) {
+			out, err := convert.FeaturesToCSV(exportFeatures)
+			return []byte(out), err
+		}},
+		{"stations.txt", func() ([]byte, error) {
+			out, err := convert.FeaturesToText(exportFeatures, "Stations")
+			return []byte(out), err
+		}},
+		{"stations.kml", func() ([]byte, error) {
+			out, err := ConvertGeoJSONToKML(geoJSON(), "Stations")
+			return []byte(out), err
+		}},
+		{"stations.gpx", func() ([]byte, error) {
+			out, err := ConvertGeoJSONToGPXWithOptions(geoJSON(), "Stations", GPXOptions{Extensions: true})
+			return []byte(out), err
+		}},
+		{"stations.gml", func() ([]byte, error) {
+			out, err := ConvertGeoJSONToGML(geoJSON(), "Stations", GMLOptions{Fields: layer.Fields})
+			return []byte(out), err
+		}},
+		{"stations.topojson", func() ([]byte, error) {
+			out, err := ConvertGeoJSONToTopoJSON(geoJSON(), "Stations")
+			return []byte(out), err
+		}},
+		{"stations.kmz", func() ([]byte, error) { return ConvertGeoJSONToKMZ(geoJSON(), "Stations") }},
+		{"stations.mbtiles", func() ([]byte, error) {
+			return ConvertGeoJSONToMBTiles(geoJSON(), "Stations", TileOptions{MaxZoom: 4})
+		}},
+		{"stations.pmtiles", func() ([]byte, error) {
+			return ConvertGeoJSONToPMTiles(geoJSON(), "Stations", TileOptions{MaxZoom: 4})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := tt.convert()
+			if err != nil {
+				t.Fatalf("Conversion failed: %v", err)
+			}
+			// Map iteration order changes between runs, so repeated conversions catch any
+			// output that depends on it.
+			for run := 0; run < 5; run++ {
+				again, err := tt.convert()
+				if err != nil {
+					t.Fatalf("Conversion failed: %v", err)
+				}
+				if !bytes.Equal(got, again) {
+					t.Fatalf("Repeated conversion gave different output")
+				}
+			}
+
+			golden := filepath.Join("testdata", "golden", tt.file)
+			if *updateGolden {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatalf("Failed to update golden file: %v", err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("Failed to read golden file (run with -update to create it): %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Output differs from %s; run go test with -update if the change is intended", golden)
+			}
+		})
+	}
+}
+
+func TestConvertGeoJSONToKMZ(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\nfake-image-data")
+	imageData := base64.StdEncoding.EncodeToString(png)
+	point := func(x float64, symbol *convert.Symbol) convert.GeoJSONFeature {
+		return convert.GeoJSONFeature{
+			Type:       "Feature",
+			Properties: map[string]interface{}{"name": "P"},
+			Geometry:   map[string]interface{}{"type": "Point", "coordinates": []float64{x, 0}},
+			Symbol:     symbol,
+		}
+	}
+	symbol := &convert.Symbol{Type: "esriPMS", ImageData: imageData, ContentType: "image/png", Width: 16, Height: 16}
+	geoJSON := &convert.GeoJSON{Features: []convert.GeoJSONFeature{point(0, symbol), point(1, symbol)}}
+
+	data, err := ConvertGeoJSONToKMZ(geoJSON, "Test Layer")
+	if err != nil {
+		t.Fatalf("ConvertGeoJSONToKMZ failed: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Invalid KMZ archive: %v", err)
+	}
+	if len(zr.File) != 2 || zr.File[0].Name != KMZDocument || zr.File[1].Name != "files/symbol_1.png" {
+		names := make([]string, len(zr.File))
+		for i, f := range zr.File {
+			names[i] = f.Name
+		}
+		t.Fatalf("Expected doc.kml and one image, got %v", names)
+	}
+
+	readEntry := func(f *zip.File) string {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", f.Name, err)
+		}
+		defer rc.Close()
+		content, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", f.Name, err)
+		}
+		return string(content)
+	}
+	kml := readEntry(zr.File[0])
+	if !strings.Contains(kml, "<href>files/symbol_1.png</href>") {
+		t.Error("Expected icon href to point at the bundled image")
+	}
+	if strings.Contains(kml, "data:image") {
+		t.Error("Expected no data: URIs in the KMZ document")
+	}
+	if got := readEntry(zr.File[1]); got != string(png) {
+		t.Errorf("Expected decoded image bytes, got %q", got)
+	}
+	if symbol.URL != "" {
+		t.Errorf("Expected the input symbol to be left unchanged, got URL %q", symbol.URL)
+	}
+}
+
+func TestKMLColor(t *testing.T) {
+	tests := []struct {
+		name string
+		rgba []int
+		want string
+	}{
+		{"Opaque red", []int{255, 0, 0, 255}, "ff0000ff"},
+		{"Translucent blue", []int{0, 0, 255, 128}, "80ff0000"},
+		{"Missing alpha", []int{1, 2, 3}, "ff030201"},
+		{"Out of range", []int{300, -5, 16, 64}, "401000ff"},
+		{"No color", nil, KMLTransparent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := kmlColor(tt.rgba, KMLTransparent); got != tt.want {
+				t.Errorf("kmlColor(%v) = %s, want %s", tt.rgba, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimpleSymbolStyles(t *testing.T) {
+	fill := generateKMLStyle(&convert.Symbol{
+		Type:    "esriSFS",
+		Style:   "esriSFSSolid",
+		Color:   []int{0, 255, 0, 128},
+		Outline: &convert.Symbol{Type: "esriSLS", Style: "esriSLSSolid", Color: []int{0, 0, 255, 255}, Width: 1.5},
+	})
+	for _, want := range []string{"<color>8000ff00</color>", "<fill>1</fill>", "<outline>1</outline>", "<width>2</width>", "<color>ffff0000</color>"} {
+		if !strings.Contains(fill, want) {
+			t.Errorf("Expected fill style to contain %s, got %s", want, fill)
+		}
+	}
+
+	hollow := generateKMLStyle(&convert.Symbol{Type: "esriSFS", Style: "esriSFSNull"})
+	if !strings.Contains(hollow, "<fill>0</fill>") || !strings.Contains(hollow, "<outline>0</outline>") {
+		t.Errorf("Expected no fill and no outline, got %s", hollow)
+	}
+
+	line := generateKMLStyle(&convert.Symbol{Type: "esriSLS", Style: "esriSLSSolid", Color: []int{255, 255, 0, 255}, Width: 3})
+	if !strings.Contains(line, "<color>ff00ffff</color>") || !strings.Contains(line, "<width>4</width>") {
+		t.Errorf("Unexpected line style %s", line)
+	}
+
+	marker := &convert.Symbol{Type: "esriSMS", Style: "esriSMSTriangle", Color: []int{255, 0, 0, 255}, Size: 12}
+	icon := kmlIconSymbol(marker, KMLOptions{})
+	if !strings.HasPrefix(icon.URL, "data:image/png;base64,") {
+		t.Fatalf("Expected a rendered PNG icon, got %q", icon.URL)
+	}
+	img, err := png.Decode(base64.NewDecoder(base64.StdEncoding, strings.NewReader(icon.ImageData)))
+	if err != nil {
+		t.Fatalf("Rendered icon is not a PNG: %v", err)
+	}
+	bounds := img.Bounds()
+	if _, _, _, a := img.At(bounds.Dx()/2, bounds.Dy()*2/3).RGBA(); a == 0 {
+		t.Error("Expected the inside of the triangle to be filled")
+	}
+	if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+		t.Error("Expected the corner of the icon to be transparent")
+	}
+	if marker.ImageData != "" {
+		t.Error("Expected the input symbol to be left unchanged")
+	}
+}
+
+func TestAppendSQLiteVarint(t *testing.T) {
+	tests := []struct {
+		value uint64
+		want  []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x81, 0x00}},
+		{16383, []byte{0xff, 0x7f}},
+		{16384, []byte{0x81, 0x80, 0x00}},
+	}
+	for _, tt := range tests {
+		if got := appendSQLiteVarint(nil, tt.value); string(got) != string(tt.want) {
+			t.Errorf("appendSQLiteVarint(%d) = %x, want %x", tt.value, got, tt.want)
+		}
+	}
+}
+
+// readSQLiteTable reads every row of a table from an SQLite database file, following the
+// file format independently of the writer: it walks the table b-tree from the root page
+// listed in sqlite_schema and reassembles payloads spilled to overflow pages.
+func readSQLiteTable(t *testing.T, data []byte, table string) [][]interface{} {
+	t.Helper()
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	usable := pageSize - int(data[20])
+	page := func(n int) []byte {
+		if n < 1 || n*pageSize > len(data) {
+			t.Fatalf("Page %d out of range", n)
+		}
+		return data[(n-1)*pageSize : n*pageSize]
+	}
+	varint := func(b []byte) (uint64, int) {
+		var v uint64
+		for i := 0; i < 8; i++ {
+			v = v<<7 | uint64(b[i]&0x7f)
+			if b[i]&0x80 == 0 {
+				return v, i + 1
+			}
+		}
+		return v<<8 | uint64(b[8]), 9
+	}
+	record := func(payload []byte) []interface{} {
+		headerSize, n := varint(payload)
+		var types []uint64
+		for pos := n; pos < int(headerSize); {
+			serialType, n := varint(payload[pos:])
+			types = append(types, serialType)
+			pos += n
+		}
+		body := payload[headerSize:]
+		var values []interface{}
+		for _, serialType := range types {
+			switch {
+			case serialType == 0:
+				values = append(values, nil)
+			case serialType >= 1 && serialType <= 6:
+				size := []int{0, 1, 2, 3, 4, 6, 8}[serialType]
+				v := int64(int8(body[0]))
+				for _, b := range body[1:size] {
+					v = v<<8 | int64(b)
+				}
+				values = append(values, v)
+				body = body[size:]
+			case serialType == 7:
+				values = append(values, math.Float64frombits(binary.BigEndian.Uint64(body)))
+				body = body[8:]
+			case serialType == 8, serialType == 9:
+				values = append(values, int64(serialType-8))
+			case serialType >= 12 && serialType%2 == 0:
+				size := int(serialType-12) / 2
+				values = append(values, append([]byte{}, body[:size]...))
+				body = body[size:]
+			default:
+				size := int(serialType-13) / 2
+				values = append(values, string(body[:size]))
+				body = body[size:]
+			}
+		}
+		return values
+	}
+
+	var rows [][]interface{}
+	var walk func(n int)
+	walk = func(n int) {
+		p := page(n)
+		offset := 0
+		if n == 1 {
+			offset = 100
+		}
+		cellCount := int(binary.BigEndian.Uint16(p[offset+3:]))
+		switch p[offset] {
+		case 0x05: // Table interior page
+			for i := 0; i < cellCount; i++ {
+				cell := int(binary.BigEndian.Uint16(p[offset+12+2*i:]))
+				walk(int(binary.BigEndian.Uint32(p[cell:])))
+			}
+			walk(int(binary.BigEndian.Uint32(p[offset+8:])))
+		case 0x0d: // Table leaf page
+			for i := 0; i < cellCount; i++ {
+				cell := int(binary.BigEndian.Uint16(p[offset+8+2*i:]))
+				size, n := varint(p[cell:])
+				_, m := varint(p[cell+n:])
+				start := cell + n + m
+				payloadSize := int(size)
+				maxLocal := usable - 35
+				local := payloadSize
+				if payloadSize > maxLocal {
+					minLocal := (usable-12)*32/255 - 23
+					local = minLocal + (payloadSize-minLocal)%(usable-4)
+					if local > maxLocal {
+						local = minLocal
+					}
+				}
+				payload := append([]byte{}, p[start:start+local]...)
+				if local < payloadSize {
+					for next := int(binary.BigEndian.Uint32(p[start+local:])); next != 0; {
+						overflow := page(next)
+						chunk := min(payloadSize-len(payload), usable-4)
+						payload = append(payload, overflow[4:4+chunk]...)
+						next = int(binary.BigEndian.Uint32(overflow))
+					}
+				}
+				if len(payload) != payloadSize {
+					t.Fatalf("Payload of %d bytes reassembled as %d", payloadSize, len(payload))
+				}
+				rows = append(rows, record(payload))
+			}
+		default:
+			t.Fatalf("Unexpected page type 0x%02x on page %d", p[offset], n)
+		}
+	}
+
+	walk(1)
+	schema := rows
+	for _, entry := range schema {
+		if entry[0] == "table" && entry[1] == table {
+			rows = nil
+			walk(int(entry[3].(int64)))
+			return rows
+		}
+	}
+	t.Fatalf("Table %s not found in schema", table)
+	return nil
+}
+
+func TestMBTilesReadBack(t *testing.T) {
+	geoJSON := &convert.GeoJSON{Type: "FeatureCollection", Features: []convert.GeoJSONFeature{
+		{Type: "Feature", Properties: map[string]interface{}{"name": "region"}, Geometry: map[string]interface{}{
+			"type":        "Polygon",
+			"coordinates": [][][]float64{{{-20, -15}, {25, -15}, {25, 30}, {-20, 30}, {-20, -15}}},
+		}},
+		{Type: "Feature", Properties: map[string]interface{}{"name": "site"}, Geometry: map[string]interface{}{
+			"type": "Point", "coordinates": []float64{100, -40},
+		}},
+	}}
+	tileSet, err := GenerateVectorTiles(geoJSON, "regions", TileOptions{MinZoom: 0, MaxZoom: 6})
+	if err != nil {
+		t.Fatalf("GenerateVectorTiles failed: %v", err)
+	}
+	// An incompressible tile spills onto overflow pages.
+	noise := make([]byte, 3*SQLitePageSize)
+	rand.New(rand.NewSource(1)).Read(noise)
+	tileSet.Tiles[TileID{Z: 6, X: 63, Y: 0}] = noise
+
+	data, err := EncodeMBTiles(tileSet)
+	if err != nil {
+		t.Fatalf("EncodeMBTiles failed: %v", err)
+	}
+
+	rows := readSQLiteTable(t, data, "tiles")
+	if len(rows) != len(tileSet.Tiles) || len(rows) < 100 {
+		t.Fatalf("Read %d tiles back, wrote %d", len(rows), len(tileSet.Tiles))
+	}
+	seen := make(map[TileID]bool)
+	spilled := false
+	for _, row := range rows {
+		z, x, tmsRow := int(row[0].(int64)), int(row[1].(int64)), int(row[2].(int64))
+		id := TileID{Z: z, X: x, Y: (1 << z) - 1 - tmsRow}
+		want, ok := tileSet.Tiles[id]
+		if !ok || seen[id] {
+			t.Fatalf("Unexpected tile %d/%d/%d", id.Z, id.X, id.Y)
+		}
+		seen[id] = true
+		spilled = spilled || len(row[3].([]byte)) > SQLitePageSize
+		reader, err := gzip.NewReader(bytes.NewReader(row[3].([]byte)))
+		if err != nil {
+			t.Fatalf("Tile %d/%d/%d is not gzip data: %v", id.Z, id.X, id.Y, err)
+		}
+		got, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("Tile %d/%d/%d: %v", id.Z, id.X, id.Y, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("Tile %d/%d/%d differs from the generated tile", id.Z, id.X, id.Y)
+		}
+	}
+	if !spilled {
+		t.Error("Expected a tile larger than a page to be read back from overflow pages")
+	}
+
+	metadata := make(map[string]string)
+	for _, row := range readSQLiteTable(t, data, "metadata") {
+		metadata[row[0].(string)] = row[1].(string)
+	}
+	if metadata["name"] != "regions" || metadata["maxzoom"] != "6" || !strings.Contains(metadata["json"], `"id":"regions"`) {
+		t.Errorf("Unexpected metadata %v", metadata)
+	}
+}
+
+func TestEstimateTileCount(t *testing.T) {
+	features := []tileFeature{{bbox: [4]float64{0.25, 0.25, 0.75, 0.75}}}
+	// One tile at z0, four at z1 and nine at z2, as the bounds end on tile edges.
+	if got := estimateTileCount(features, TileOptions{MinZoom: 0, MaxZoom: 2}); got != 14 {
+		t.Errorf("estimateTileCount() = %d, want 14", got)
+	}
+	world := []tileFeature{{bbox: [4]float64{0, 0, 1, 1}}}
+	if got := estimateTileCount(world, TileOptions{MinZoom: 0, MaxZoom: DefaultMaxZoom}); got <= TileCountWarning {
+		t.Errorf("Expected a world layer at the default zooms to exceed the warning threshold, got %d", got)
+	}
+}
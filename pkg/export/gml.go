@@ -0,0 +1,450 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package export provides functions for converting GeoJSON data to various export formats.
+package export
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/arcgis"
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/convert"
+)
+
+// GMLOptions configures GML output.
+type GMLOptions struct {
+	// Fields is the attribute schema; it is inferred from feature properties when empty.
+	Fields []arcgis.Field
+	// SpatialReference is the spatial reference of the coordinates (default WGS84).
+	SpatialReference *arcgis.SpatialReference
+	// SchemaLocation is the location of the application schema referenced by the document,
+	// usually the file name of the output of GenerateGMLSchema.
+	SchemaLocation string
+}
+
+// gmlLayout describes the feature type shared by a GML document and its schema.
+type gmlLayout struct {
+	TypeName     string
+	Fields       []gmlField
+	GeometryType string // GML property type of the geometry element
+	Multi        bool   // Write single-part geometries as one-member multi-geometries
+	SRSName      string
+	SwapAxes     bool // Write latitude before longitude, as geographic EPSG codes require
+}
+
+// gmlField maps a layer field to its element name.
+type gmlField struct {
+	Element string
+	Field   arcgis.Field
+}
+
+var (
+	invalidNCNameChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+	validNCNameStart   = regexp.MustCompile(`^[A-Za-z_]`)
+)
+
+// ConvertGeoJSONToGML converts a GeoJSON FeatureCollection to a GML 3.2 document.
+// The function handles:
+//   - A FeatureCollection with one featureMember per feature and gml:id identifiers
+//   - Point, LineString and Polygon geometries and their multi-part variants
+//     (MultiPoint, MultiCurve, MultiSurface)
+//   - srsName and axis order derived from the spatial reference of the coordinates
+//   - Attribute elements in schema order, with dates written as xs:dateTime
+//
+// Parameters:
+//   - geoJSON: Pointer to a GeoJSON FeatureCollection
+//   - layerName: Name of the layer, used as the feature type name
+//   - opts: Schema, spatial reference and schema location
+//
+// Returns:
+//   - string: GML document as a string
+//   - error: Any error that occurred during conversion
+func ConvertGeoJSONToGML(geoJSON *convert.GeoJSON, layerName string, opts GMLOptions) (string, error) {
+	if geoJSON == nil {
+		return "", fmt.Errorf("no GeoJSON data to convert")
+	}
+	layout := newGMLLayout(geoJSON, layerName, opts)
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(fmt.Sprintf(`<%s:FeatureCollection xmlns:%s="%s" xmlns:gml="%s" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"`,
+		GMLPrefix, GMLPrefix, GMLNamespace, GMLNamespaceGML))
+	if opts.SchemaLocation != "" {
+		b.WriteString(fmt.Sprintf(` xsi:schemaLocation="%s %s %s %s"`, GMLNamespace, escapeXML(opts.SchemaLocation), GMLNamespaceGML, GMLSchemaGML))
+	}
+	b.WriteString(fmt.Sprintf(` gml:id="%s.collection">`, layout.TypeName))
+
+	for i, feature := range geoJSON.Features {
+		id := fmt.Sprintf("%s.%d", layout.TypeName, i+1)
+		b.WriteString(fmt.Sprintf("\n  <%s:featureMember>\n    <%s:%s gml:id=\"%s\">", GMLPrefix, GMLPrefix, layout.TypeName, id))
+
+		if geometry := layout.geometry(feature.Geometry, id+".geom"); geometry != "" {
+			b.WriteString(fmt.Sprintf("\n      <%s:%s>%s</%s:%s>", GMLPrefix, GMLGeometryElement, geometry, GMLPrefix, GMLGeometryElement))
+		}
+		for _, field := range layout.Fields {
+			value, ok := feature.Properties[field.Field.Name]
+			if !ok || value == nil {
+				continue
+			}
+			b.WriteString(fmt.Sprintf("\n      <%s:%s>%s</%s:%s>", GMLPrefix, field.Element, escapeXML(gmlValue(value, field.Field.Type)), GMLPrefix, field.Element))
+		}
+		b.WriteString(fmt.Sprintf("\n    </%s:%s>\n  </%s:featureMember>", GMLPrefix, layout.TypeName, GMLPrefix))
+	}
+	b.WriteString(fmt.Sprintf("\n</%s:FeatureCollection>\n", GMLPrefix))
+	return b.String(), nil
+}
+
+// GenerateGMLSchema generates the XML Schema (XSD) for the GML written by ConvertGeoJSONToGML.
+// The function handles:
+//   - The FeatureCollection element and its featureMember property
+//   - A feature type with a geometry property matching the layer's geometries
+//   - One element per field with its XSD type, string length, alias as documentation,
+//     and coded value or range domains as enumerations or bounds
+//
+// Parameters:
+//   - geoJSON: Pointer to the GeoJSON FeatureCollection being exported
+//   - layerName: Name of the layer, used as the feature type name
+//   - opts: Schema options; SchemaLocation is ignored
+//
+// Returns:
+//   - string: XSD document as a string
+//   - error: Any error that occurred while generating the schema
+func GenerateGMLSchema(geoJSON *convert.GeoJSON, layerName string, opts GMLOptions) (string, error) {
+	if geoJSON == nil {
+		return "", fmt.Errorf("no GeoJSON data to describe")
+	}
+	layout := newGMLLayout(geoJSON, layerName, opts)
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(fmt.Sprintf(`<xs:schema targetNamespace="%s" xmlns:%s="%s" xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns:gml="%s" elementFormDefault="qualified" version="1.0">`,
+		GMLNamespace, GMLPrefix, GMLNamespace, GMLNamespaceGML))
+	b.WriteString(fmt.Sprintf("\n  <xs:import namespace=\"%s\" schemaLocation=\"%s\"/>", GMLNamespaceGML, GMLSchemaGML))
+	b.WriteString(fmt.Sprintf(`
+  <xs:element name="FeatureCollection" type="%[1]s:FeatureCollectionType" substitutionGroup="gml:AbstractFeature"/>
+  <xs:complexType name="FeatureCollectionType">
+    <xs:complexContent>
+      <xs:extension base="gml:AbstractFeatureType">
+        <xs:sequence minOccurs="0" maxOccurs="unbounded">
+          <xs:element name="featureMember">
+            <xs:complexType>
+              <xs:complexContent>
+                <xs:extension base="gml:AbstractFeatureMemberType">
+                  <xs:sequence>
+                    <xs:element ref="gml:AbstractFeature"/>
+                  </xs:sequence>
+                </xs:extension>
+              </xs:complexContent>
+            </xs:complexType>
+          </xs:element>
+        </xs:sequence>
+      </xs:extension>
+    </xs:complexContent>
+  </xs:complexType>`, GMLPrefix))
+
+	b.WriteString(fmt.Sprintf(`
+  <xs:element name="%[2]s" type="%[1]s:%[2]sType" substitutionGroup="gml:AbstractFeature"/>
+  <xs:complexType name="%[2]sType">
+    <xs:complexContent>
+      <xs:extension base="gml:AbstractFeatureType">
+        <xs:sequence>
+          <xs:element name="%[3]s" type="gml:%[4]s" nillable="true" minOccurs="0" maxOccurs="1"/>`,
+		GMLPrefix, layout.TypeName, GMLGeometryElement, layout.GeometryType))
+	for _, field := range layout.Fields {
+		b.WriteString(gmlSchemaElement(field))
+	}
+	b.WriteString(`
+        </xs:sequence>
+      </xs:extension>
+    </xs:complexContent>
+  </xs:complexType>
+</xs:schema>
+`)
+	return b.String(), nil
+}
+
+// newGMLLayout derives the feature type, fields, geometry property type and
+// spatial reference settings shared by the document and its schema.
+func newGMLLayout(geoJSON *convert.GeoJSON, layerName string, opts GMLOptions) *gmlLayout {
+	layout := &gmlLayout{TypeName: gmlName(layerName, "Layer")}
+
+	fields := opts.Fields
+	if len(fields) == 0 {
+		attributes := make([]map[string]interface{}, len(geoJSON.Features))
+		for i, feature := range geoJSON.Features {
+			attributes[i] = feature.Properties
+		}
+		fields = inferEsriFields(attributes)
+	}
+	used := map[string]bool{GMLGeometryElement: true}
+	for _, field := range fields {
+		if _, ok := gmlFieldTypes[field.Type]; !ok {
+			continue // Shape, blob and raster fields have no attribute representation
+		}
+		element := gmlName(field.Name, "field")
+		for base, n := element, 2; used[element]; n++ {
+			element = fmt.Sprintf("%s_%d", base, n)
+		}
+		used[element] = true
+		layout.Fields = append(layout.Fields, gmlField{Element: element, Field: field})
+	}
+
+	// Geometry families present in the layer; a family containing any multi-part
+	// geometry is written entirely as multi-geometries so every feature matches one type.
+	families := make(map[string]bool)
+	for _, feature := range geoJSON.Features {
+		geometryMap, ok := feature.Geometry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		geometryType, _ := geometryMap[KeyType].(string)
+		families[strings.TrimPrefix(geometryType, "Multi")] = true
+		if strings.HasPrefix(geometryType, "Multi") {
+			layout.Multi = true
+		}
+	}
+	layout.GeometryType = "GeometryPropertyType"
+	if len(families) == 1 {
+		for family := range families {
+			switch {
+			case family == "Point" && layout.Multi:
+				layout.GeometryType = "MultiPointPropertyType"
+			case family == "Point":
+				layout.GeometryType = "PointPropertyType"
+			case family == "LineString" && layout.Multi:
+				layout.GeometryType = "MultiCurvePropertyType"
+			case family == "LineString":
+				layout.GeometryType = "CurvePropertyType"
+			case family == "Polygon" && layout.Multi:
+				layout.GeometryType = "MultiSurfacePropertyType"
+			case family == "Polygon":
+				layout.GeometryType = "SurfacePropertyType"
+			}
+		}
+	} else {
+		layout.Multi = false
+	}
+
+	wkid := EsriWKIDWGS84
+	if sr := opts.SpatialReference; sr != nil {
+		switch {
+		case sr.LatestWKID != 0:
+			wkid = sr.LatestWKID
+		case sr.WKID != 0:
+			wkid = sr.WKID
+		}
+	}
+	layout.SRSName = fmt.Sprintf(GMLSRSNameFormat, wkid)
+	layout.SwapAxes = wkid >= MinGeographicEPSG && wkid <= MaxGeographicEPSG
+	return layout
+}
+
+// geometry converts a GeoJSON geometry to a GML geometry element with the given gml:id.
+func (l *gmlLayout) geometry(geometry interface{}, id string) string {
+	geometryMap, ok := geometry.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	geometryType, _ := geometryMap[KeyType].(string)
+	coordinates := geometryMap["coordinates"]
+
+	// Promote single-part geometries when the schema expects multi-geometries.
+	if l.Multi {
+		switch geometryType {
+		case "Point":
+			if c, ok := coordinates.([]float64); ok {
+				geometryType, coordinates = "MultiPoint", [][]float64{c}
+			}
+		case "LineString":
+			if c, ok := coordinates.([][]float64); ok {
+				geometryType, coordinates = "MultiLineString", [][][]float64{c}
+			}
+		case "Polygon":
+			if c, ok := coordinates.([][][]float64); ok {
+				geometryType, coordinates = "MultiPolygon", [][][][]float64{c}
+			}
+		}
+	}
+
+	srs := fmt.Sprintf(` gml:id="%s" srsName="%s" srsDimension="2"`, id, l.SRSName)
+	switch geometryType {
+	case "Point":
+		if c, ok := coordinates.([]float64); ok && len(c) >= 2 {
+			return fmt.Sprintf("<gml:Point%s><gml:pos>%s</gml:pos></gml:Point>", srs, l.posList([][]float64{c}))
+		}
+	case "LineString":
+		if c, ok := coordinates.([][]float64); ok && len(c) > 0 {
+			return fmt.Sprintf("<gml:LineString%s><gml:posList>%s</gml:posList></gml:LineString>", srs, l.posList(c))
+		}
+	case "Polygon":
+		if c, ok := coordinates.([][][]float64); ok && len(c) > 0 {
+			return fmt.Sprintf("<gml:Polygon%s>%s</gml:Polygon>", srs, l.rings(c))
+		}
+	case "MultiPoint":
+		if c, ok := coordinates.([][]float64); ok && len(c) > 0 {
+			var members strings.Builder
+			for i, point := range c {
+				members.WriteString(fmt.Sprintf(`<gml:pointMember><gml:Point gml:id="%s.%d"><gml:pos>%s</gml:pos></gml:Point></gml:pointMember>`, id, i+1, l.posList([][]float64{point})))
+			}
+			return fmt.Sprintf("<gml:MultiPoint%s>%s</gml:MultiPoint>", srs, members.String())
+		}
+	case "MultiLineString":
+		if c, ok := coordinates.([][][]float64); ok && len(c) > 0 {
+			var members strings.Builder
+			for i, line := range c {
+				members.WriteString(fmt.Sprintf(`<gml:curveMember><gml:LineString gml:id="%s.%d"><gml:posList>%s</gml:posList></gml:LineString></gml:curveMember>`, id, i+1, l.posList(line)))
+			}
+			return fmt.Sprintf("<gml:MultiCurve%s>%s</gml:MultiCurve>", srs, members.String())
+		}
+	case "MultiPolygon":
+		if c, ok := coordinates.([][][][]float64); ok && len(c) > 0 {
+			var members strings.Builder
+			for i, polygon := range c {
+				members.WriteString(fmt.Sprintf(`<gml:surfaceMember><gml:Polygon gml:id="%s.%d">%s</gml:Polygon></gml:surfaceMember>`, id, i+1, l.rings(polygon)))
+			}
+			return fmt.Sprintf("<gml:MultiSurface%s>%s</gml:MultiSurface>", srs, members.String())
+		}
+	default:
+		fmt.Fprintf(Output, "  Warning: Unsupported geometry type for GML conversion: %s\n", geometryType)
+	}
+	return ""
+}
+
+// rings writes polygon rings as exterior and interior boundaries.
+// GML expects exterior rings counter-clockwise and interior rings clockwise, the
+// opposite of Esri, so rings are reversed where needed.
+func (l *gmlLayout) rings(rings [][][]float64) string {
+	var b strings.Builder
+	for i, ring := range rings {
+		exterior := i == 0
+		if (signedRingArea(ring) > 0) != exterior {
+			ring = reversedRing(ring)
+		}
+		boundary := "interior"
+		if exterior {
+			boundary = "exterior"
+		}
+		b.WriteString(fmt.Sprintf("<gml:%s><gml:LinearRing><gml:posList>%s</gml:posList></gml:LinearRing></gml:%s>", boundary, l.posList(ring), boundary))
+	}
+	return b.String()
+}
+
+// posList formats positions in the axis order of the spatial reference.
+func (l *gmlLayout) posList(coords [][]float64) string {
+	parts := make([]string, 0, len(coords)*2)
+	for _, c := range coords {
+		x, y := c[0], c[1]
+		if l.SwapAxes {
+			x, y = y, x
+		}
+		parts = append(parts, strconv.FormatFloat(x, 'f', -1, 64), strconv.FormatFloat(y, 'f', -1, 64))
+	}
+	return strings.Join(parts, " ")
+}
+
+// gmlSchemaElement writes the XSD element declaration for a field.
+func gmlSchemaElement(field gmlField) string {
+	var restriction strings.Builder
+	if field.Field.Length > 0 && gmlFieldTypes[field.Field.Type] == "xs:string" {
+		restriction.WriteString(fmt.Sprintf("\n                <xs:maxLength value=\"%d\"/>", field.Field.Length))
+	}
+	if domain := field.Field.Domain; domain != nil {
+		for _, cv := range domain.CodedValues {
+			restriction.WriteString(fmt.Sprintf("\n                <xs:enumeration value=\"%s\"><xs:annotation><xs:documentation>%s</xs:documentation></xs:annotation></xs:enumeration>",
+				escapeXML(fmt.Sprintf("%v", cv.Code)), escapeXML(cv.Name)))
+		}
+		if len(domain.Range) == 2 {
+			restriction.WriteString(fmt.Sprintf("\n                <xs:minInclusive value=\"%s\"/>\n                <xs:maxInclusive value=\"%s\"/>",
+				strconv.FormatFloat(domain.Range[0], 'f', -1, 64), strconv.FormatFloat(domain.Range[1], 'f', -1, 64)))
+		}
+	}
+
+	annotation := ""
+	if field.Field.Alias != "" && field.Field.Alias != field.Field.Name {
+		annotation = fmt.Sprintf("\n            <xs:annotation><xs:documentation>%s</xs:documentation></xs:annotation>", escapeXML(field.Field.Alias))
+	}
+
+	xsdType := gmlFieldTypes[field.Field.Type]
+	if restriction.Len() == 0 && annotation == "" {
+		return fmt.Sprintf("\n          <xs:element name=\"%s\" type=\"%s\" nillable=\"true\" minOccurs=\"0\" maxOccurs=\"1\"/>", field.Element, xsdType)
+	}
+	if restriction.Len() == 0 {
+		return fmt.Sprintf("\n          <xs:element name=\"%s\" type=\"%s\" nillable=\"true\" minOccurs=\"0\" maxOccurs=\"1\">%s\n          </xs:element>", field.Element, xsdType, annotation)
+	}
+	return fmt.Sprintf(`
+          <xs:element name="%s" nillable="true" minOccurs="0" maxOccurs="1">%s
+            <xs:simpleType>
+              <xs:restriction base="%s">%s
+              </xs:restriction>
+            </xs:simpleType>
+          </xs:element>`, field.Element, annotation, xsdType, restriction.String())
+}
+
+// gmlValue formats an attribute value for its field type; Esri dates (epoch
+// milliseconds) become xs:dateTime values in UTC.
+func gmlValue(value interface{}, fieldType string) string {
+	switch v := value.(type) {
+	case float64:
+		if fieldType == EsriFieldTypeDate {
+			return convert.EsriDateToISO(v)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// gmlName turns a layer or field name into a valid XML element name (NCName).
+func gmlName(name, fallback string) string {
+	name = invalidNCNameChars.ReplaceAllString(strings.ReplaceAll(strings.TrimSpace(name), " ", "_"), "")
+	if name == "" {
+		return fallback
+	}
+	if !validNCNameStart.MatchString(name) {
+		name = "_" + name
+	}
+	return name
+}
+
+// signedRingArea returns the signed area of a ring; counter-clockwise rings are positive.
+func signedRingArea(ring [][]float64) float64 {
+	area := 0.0
+	for i := 0; i+1 < len(ring); i++ {
+		area += ring[i][0]*ring[i+1][1] - ring[i+1][0]*ring[i][1]
+	}
+	return area / 2
+}
+
+// reversedRing returns a copy of a ring with its vertex order reversed.
+func reversedRing(ring [][]float64) [][]float64 {
+	reversed := make([][]float64, len(ring))
+	for i, c := range ring {
+		reversed[len(ring)-1-i] = c
+	}
+	return reversed
+}
+
+// gmlFieldTypes maps Esri field types to XSD types. Types missing from the map are not written.
+var gmlFieldTypes = map[string]string{
+	EsriFieldTypeOID:               "xs:long",
+	EsriFieldTypeInteger:           "xs:int",
+	EsriFieldTypeSmallInteger:      "xs:short",
+	"esriFieldTypeBigInteger":      "xs:long",
+	EsriFieldTypeDouble:            "xs:double",
+	"esriFieldTypeSingle":          "xs:float",
+	EsriFieldTypeString:            "xs:string",
+	EsriFieldTypeDate:              "xs:dateTime",
+	"esriFieldTypeDateOnly":        "xs:date",
+	"esriFieldTypeTimeOnly":        "xs:time",
+	"esriFieldTypeGUID":            "xs:string",
+	"esriFieldTypeGlobalID":        "xs:string",
+	"esriFieldTypeXML":             "xs:string",
+	"esriFieldTypeTimestampOffset": "xs:dateTime",
+}
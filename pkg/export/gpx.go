@@ -6,16 +6,39 @@ package export
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/Sudo-Ivan/arcgis-utils/pkg/convert"
 )
 
+// GPXOptions configures GPX output.
+type GPXOptions struct {
+	// TimeField names the date attribute written as the time of waypoints and trackpoints.
+	TimeField string
+	// EndTimeField names the date attribute holding the time at the end of a track.
+	// Trackpoint times are then interpolated along the track by distance.
+	EndTimeField string
+	// Routes writes lines as routes, one per line part, instead of tracks.
+	Routes bool
+	// PolygonMode selects how polygons are written: as a track along their outer boundaries
+	// (GPXPolygonBoundary, the default), as a track with a segment per ring (GPXPolygonRings),
+	// or as a waypoint at their centroid (GPXPolygonCentroid).
+	PolygonMode string
+	// Extensions writes each feature's attributes into the <extensions> element of its
+	// waypoints, routes and tracks.
+	Extensions bool
+	// SymFor returns the symbol name written as a waypoint's <sym>, such as its renderer class.
+	SymFor func(feature *convert.GeoJSONFeature) string
+}
+
 // ConvertGeoJSONToGPX converts a GeoJSON FeatureCollection to a GPX string.
 // The function handles:
-//   - Point geometries as waypoints
-//   - LineString geometries as tracks
-//   - Polygon geometries as track boundaries
+//   - Point and MultiPoint geometries as waypoints
+//   - LineString and MultiLineString geometries as tracks with one segment per part
+//   - Polygon and MultiPolygon geometries as tracks along their outer boundaries
 //
 // Parameters:
 //   - geoJSON: Pointer to a GeoJSON FeatureCollection
@@ -25,78 +48,335 @@ import (
 //   - string: GPX document as a string
 //   - error: Any error that occurred during conversion
 func ConvertGeoJSONToGPX(geoJSON *convert.GeoJSON, layerName string) (string, error) {
+	return ConvertGeoJSONToGPXWithOptions(geoJSON, layerName, GPXOptions{})
+}
+
+// ConvertGeoJSONToGPXWithOptions converts a GeoJSON FeatureCollection to a GPX string
+// using the given options. See ConvertGeoJSONToGPX for the handled geometries.
+// The function handles:
+//   - <time> elements on waypoints and trackpoints from a time field
+//   - Lines as routes instead of tracks
+//   - Polygons as boundaries, all rings, or centroid waypoints
+//   - Feature attributes as GPX extensions and waypoint symbols as <sym>
+//
+// Parameters:
+//   - geoJSON: Pointer to a GeoJSON FeatureCollection
+//   - layerName: Name of the layer to be used in the GPX metadata
+//   - opts: Options selecting the time attributes and how geometries are written
+//
+// Returns:
+//   - string: GPX document as a string
+//   - error: Any error that occurred during conversion
+func ConvertGeoJSONToGPXWithOptions(geoJSON *convert.GeoJSON, layerName string, opts GPXOptions) (string, error) {
+	switch opts.PolygonMode {
+	case "", GPXPolygonBoundary, GPXPolygonRings, GPXPolygonCentroid:
+	default:
+		return "", fmt.Errorf("unsupported GPX polygon mode: %s", opts.PolygonMode)
+	}
+
 	var waypoints strings.Builder
+	var routes strings.Builder
 	var tracks strings.Builder
 
-	for _, feature := range geoJSON.Features {
+	for i := range geoJSON.Features {
+		feature := &geoJSON.Features[i]
 		if feature.Geometry == nil {
 			continue
 		}
 
-		name := getFeatureName(feature)
+		name := getFeatureName(*feature)
 		desc := formatProperties(feature.Properties, ", ")
+		extensions := ""
+		if opts.Extensions {
+			extensions = gpxExtensions(feature.Properties)
+		}
+		sym := ""
+		if opts.SymFor != nil {
+			sym = opts.SymFor(feature)
+		}
 
 		geometryMap := feature.Geometry.(map[string]interface{})
 		geometryType := geometryMap["type"].(string)
 		coordinates := geometryMap["coordinates"]
 
+		var start, end *time.Time
+		if t, ok := convert.ParseFeatureTime(feature.Properties[opts.TimeField]); ok && opts.TimeField != "" {
+			start = &t
+		}
+		if t, ok := convert.ParseFeatureTime(feature.Properties[opts.EndTimeField]); ok && opts.EndTimeField != "" && start != nil {
+			end = &t
+		}
+
+		// lines writes line parts as routes or as one track
+		lines := func(parts [][][]float64) {
+			if !opts.Routes {
+				tracks.WriteString(gpxTrack(name, desc, extensions, parts, start, end))
+				return
+			}
+			for _, part := range parts {
+				routes.WriteString(gpxRoute(name, desc, extensions, part, start, end))
+			}
+		}
+		// polygons writes polygons in the selected polygon mode
+		polygons := func(parts [][][][]float64) {
+			switch opts.PolygonMode {
+			case GPXPolygonCentroid:
+				if c := polygonCentroid(parts); c != nil {
+					waypoints.WriteString(gpxWaypoint(c, start, name, desc, sym, extensions))
+				}
+			case GPXPolygonRings:
+				var rings [][][]float64
+				for _, polygon := range parts {
+					rings = append(rings, polygon...)
+				}
+				tracks.WriteString(gpxTrack(name, desc, extensions, rings, start, end))
+			default:
+				boundaries := make([][][]float64, 0, len(parts))
+				for _, polygon := range parts {
+					if len(polygon) > 0 {
+						boundaries = append(boundaries, polygon[0])
+					}
+				}
+				tracks.WriteString(gpxTrack(name+" (Boundary)", desc, extensions, boundaries, start, end))
+			}
+		}
+
 		switch geometryType {
 		case "Point":
 			coords, ok := coordinates.([]float64)
 			if ok && len(coords) >= 2 {
-				waypoints.WriteString(fmt.Sprintf(`
-    <wpt lat="%.10f" lon="%.10f">
-        <name>%s</name>
-        <desc>%s</desc>
-    </wpt>`, coords[1], coords[0], escapeXML(name), escapeXML(desc)))
+				waypoints.WriteString(gpxWaypoint(coords, start, name, desc, sym, extensions))
 			}
-		case "LineString":
+		case "MultiPoint":
 			coords, ok := coordinates.([][]float64)
-			if ok && len(coords) > 0 {
-				tracks.WriteString(fmt.Sprintf(`
-    <trk>
-        <name>%s</name>
-        <desc>%s</desc>
-        <trkseg>`, escapeXML(name), escapeXML(desc)))
+			if ok {
 				for _, c := range coords {
-					tracks.WriteString(fmt.Sprintf(`<trkpt lat="%.10f" lon="%.10f"></trkpt>`, c[1], c[0]))
+					waypoints.WriteString(gpxWaypoint(c, start, name, desc, sym, extensions))
 				}
-				tracks.WriteString(`
-        </trkseg>
-    </trk>`)
+			}
+		case "LineString":
+			coords, ok := coordinates.([][]float64)
+			if ok && len(coords) > 0 {
+				lines([][][]float64{coords})
+			}
+		case "MultiLineString":
+			coords, ok := coordinates.([][][]float64)
+			if ok && len(coords) > 0 {
+				lines(coords)
 			}
 		case "Polygon":
 			coords, ok := coordinates.([][][]float64)
 			if ok && len(coords) > 0 {
-				outerRing := coords[0]
-				tracks.WriteString(fmt.Sprintf(`
-    <trk>
-        <name>%s (Boundary)</name>
-        <desc>%s</desc>
-        <trkseg>`, escapeXML(name), escapeXML(desc)))
-				for _, c := range outerRing {
-					tracks.WriteString(fmt.Sprintf(`<trkpt lat="%.10f" lon="%.10f"></trkpt>`, c[1], c[0]))
-				}
-				tracks.WriteString(`
-        </trkseg>
-    </trk>`)
+				polygons([][][][]float64{coords})
+			}
+		case "MultiPolygon":
+			coords, ok := coordinates.([][][][]float64)
+			if ok && len(coords) > 0 {
+				polygons(coords)
 			}
 		default:
-			fmt.Printf("  Warning: Unsupported geometry type for GPX conversion: %s\n", geometryType)
+			fmt.Fprintf(Output, "  Warning: Unsupported geometry type for GPX conversion: %s\n", geometryType)
 		}
 	}
 
-	gpxContent := waypoints.String() + tracks.String()
+	// GPX requires waypoints, then routes, then tracks
+	gpxContent := waypoints.String() + routes.String() + tracks.String()
+
+	namespaces := ""
+	if opts.Extensions {
+		namespaces = fmt.Sprintf(`
+    xmlns:%s="%s"`, GPXExtensionsPrefix, GPXExtensionsNamespace)
+	}
 
 	gpx := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
 <gpx version="1.1" creator="arcgis-utils-go"
-    xmlns="http://www.topografix.com/GPX/1/1"
+    xmlns="http://www.topografix.com/GPX/1/1"%s
     xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"
     xsi:schemaLocation="http://www.topografix.com/GPX/1/1 http://www.topografix.com/GPX/1/1/gpx.xsd">
     <metadata>
         <name>%s</name>
     </metadata>%s
-</gpx>`, escapeXML(layerName), gpxContent)
+</gpx>`, namespaces, escapeXML(layerName), gpxContent)
 
 	return gpx, nil
 }
+
+// gpxWaypoint writes a GPX waypoint, with its time when t is set and its symbol when sym is set.
+func gpxWaypoint(c []float64, t *time.Time, name, desc, sym, extensions string) string {
+	var wpt strings.Builder
+	wpt.WriteString(fmt.Sprintf(`
+    <wpt lat="%.10f" lon="%.10f">`, c[1], c[0]))
+	if t != nil {
+		wpt.WriteString(fmt.Sprintf(`
+        <time>%s</time>`, t.Format(time.RFC3339Nano)))
+	}
+	wpt.WriteString(fmt.Sprintf(`
+        <name>%s</name>
+        <desc>%s</desc>`, escapeXML(name), escapeXML(desc)))
+	if sym != "" {
+		wpt.WriteString(fmt.Sprintf(`
+        <sym>%s</sym>`, escapeXML(sym)))
+	}
+	wpt.WriteString(extensions)
+	wpt.WriteString(`
+    </wpt>`)
+	return wpt.String()
+}
+
+// gpxRoute writes a GPX route through the points, timed like a single-segment track.
+func gpxRoute(name, desc, extensions string, points [][]float64, start, end *time.Time) string {
+	times := gpxTrackTimes([][][]float64{points}, start, end)
+	var route strings.Builder
+	route.WriteString(fmt.Sprintf(`
+    <rte>
+        <name>%s</name>
+        <desc>%s</desc>%s`, escapeXML(name), escapeXML(desc), extensions))
+	for i, c := range points {
+		timeElement := ""
+		if len(times) > 0 {
+			timeElement = fmt.Sprintf("<time>%s</time>", times[i].Format(time.RFC3339Nano))
+		}
+		route.WriteString(fmt.Sprintf(`
+        <rtept lat="%.10f" lon="%.10f">%s</rtept>`, c[1], c[0], timeElement))
+	}
+	route.WriteString(`
+    </rte>`)
+	return route.String()
+}
+
+// gpxTrack writes a GPX track with one track segment per coordinate list. Trackpoints
+// get the start time, or with an end time a time interpolated by distance along the track.
+func gpxTrack(name, desc, extensions string, segments [][][]float64, start, end *time.Time) string {
+	times := gpxTrackTimes(segments, start, end)
+	var track strings.Builder
+	track.WriteString(fmt.Sprintf(`
+    <trk>
+        <name>%s</name>
+        <desc>%s</desc>%s`, escapeXML(name), escapeXML(desc), extensions))
+	for _, segment := range segments {
+		track.WriteString(`
+        <trkseg>`)
+		for _, c := range segment {
+			timeElement := ""
+			if len(times) > 0 {
+				timeElement = fmt.Sprintf("<time>%s</time>", times[0].Format(time.RFC3339Nano))
+				times = times[1:]
+			}
+			track.WriteString(fmt.Sprintf(`<trkpt lat="%.10f" lon="%.10f">%s</trkpt>`, c[1], c[0], timeElement))
+		}
+		track.WriteString(`
+        </trkseg>`)
+	}
+	track.WriteString(`
+    </trk>`)
+	return track.String()
+}
+
+// gpxExtensions writes feature attributes, sorted by name, as a GPX <extensions> element.
+// Null attributes are left out.
+func gpxExtensions(props map[string]interface{}) string {
+	names := make([]string, 0, len(props))
+	for name, value := range props {
+		if value == nil || name == "geometry" || name == "symbol" {
+			continue
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	var ext strings.Builder
+	ext.WriteString(fmt.Sprintf(`
+        <extensions>
+            <%s:attributes>`, GPXExtensionsPrefix))
+	for _, name := range names {
+		ext.WriteString(fmt.Sprintf(`
+                <%[1]s:attribute name="%[2]s">%[3]s</%[1]s:attribute>`, GPXExtensionsPrefix, escapeXML(name), escapeXML(gmlValue(props[name], ""))))
+	}
+	ext.WriteString(fmt.Sprintf(`
+            </%s:attributes>
+        </extensions>`, GPXExtensionsPrefix))
+	return ext.String()
+}
+
+// polygonCentroid returns the area-weighted centroid of polygons, with holes subtracted,
+// or the mean of the outer ring vertices when the polygons have no area.
+func polygonCentroid(polygons [][][][]float64) []float64 {
+	var area, x, y float64
+	var vertices [][]float64
+	for _, polygon := range polygons {
+		for r, ring := range polygon {
+			if r == 0 {
+				vertices = append(vertices, ring...)
+			}
+			ringArea := signedRingArea(ring)
+			if ringArea == 0 {
+				continue
+			}
+			cx, cy := 0.0, 0.0
+			for i := 0; i+1 < len(ring); i++ {
+				cross := ring[i][0]*ring[i+1][1] - ring[i+1][0]*ring[i][1]
+				cx += (ring[i][0] + ring[i+1][0]) * cross
+				cy += (ring[i][1] + ring[i+1][1]) * cross
+			}
+			cx /= 6 * ringArea
+			cy /= 6 * ringArea
+			weight := math.Abs(ringArea)
+			if r > 0 {
+				weight = -weight
+			}
+			area += weight
+			x += cx * weight
+			y += cy * weight
+		}
+	}
+	if area != 0 {
+		return []float64{x / area, y / area}
+	}
+	if len(vertices) == 0 {
+		return nil
+	}
+	for _, c := range vertices {
+		x += c[0]
+		y += c[1]
+	}
+	return []float64{x / float64(len(vertices)), y / float64(len(vertices))}
+}
+
+// gpxTrackTimes returns the time of every trackpoint in order, or nil without a start time.
+// Between a start and end time, each point's time is proportional to its distance along
+// the track, with gaps between segments not counted.
+func gpxTrackTimes(segments [][][]float64, start, end *time.Time) []time.Time {
+	if start == nil {
+		return nil
+	}
+	var distances []float64
+	total := 0.0
+	for _, segment := range segments {
+		for i, c := range segment {
+			if i > 0 {
+				total += haversineDistance(segment[i-1], c)
+			}
+			distances = append(distances, total)
+		}
+	}
+	times := make([]time.Time, len(distances))
+	for i, d := range distances {
+		times[i] = *start
+		if end != nil && total > 0 {
+			offset := time.Duration(float64(end.Sub(*start)) * d / total)
+			times[i] = start.Add(offset).Truncate(time.Millisecond)
+		}
+	}
+	return times
+}
+
+// haversineDistance returns the great-circle distance in meters between two lon/lat positions.
+func haversineDistance(a, b []float64) float64 {
+	lat1, lat2 := a[1]*math.Pi/180, b[1]*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b[0] - a[0]) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(h)))
+}
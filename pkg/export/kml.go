@@ -7,15 +7,77 @@ package export
 import (
 	"encoding/base64"
 	"fmt"
+	"hash/fnv"
+	"html"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/arcgis"
 	"github.com/Sudo-Ivan/arcgis-utils/pkg/convert"
 )
 
+// KMLOptions configures KML output.
+type KMLOptions struct {
+	// ImageHref returns the href used for a symbol's embedded image. When it is nil or
+	// returns an empty string, the image is embedded as a data: URI.
+	ImageHref func(symbol *convert.Symbol) string
+	// FolderPath nests the layer's placemarks in folders, outermost first,
+	// such as the group path of a Web Map layer.
+	FolderPath []string
+	// FolderFor returns the folder a placemark is grouped in within the layer, such as
+	// its renderer class label or an attribute value. An empty name leaves the placemark
+	// outside any class folder.
+	FolderFor func(feature *convert.GeoJSONFeature) string
+	// Fields defines the typed attribute schema written as a KML Schema with
+	// SchemaData on each placemark. When empty, the schema is inferred from the attributes.
+	Fields []arcgis.Field
+	// BalloonTemplate is HTML used as each placemark's description, with $[FIELD]
+	// placeholders replaced by the escaped attribute values. Placeholders that are not
+	// attributes, such as $[name], are left for the KML viewer to fill in.
+	BalloonTemplate string
+	// TimeField and EndTimeField name the date attributes giving each placemark a
+	// TimeStamp, or a TimeSpan when an end time is present.
+	TimeField    string
+	EndTimeField string
+	// NameFor returns a placemark's name, such as its evaluated label expression.
+	// When it is nil or returns an empty string, the name is taken from common name fields.
+	NameFor func(feature *convert.GeoJSONFeature) string
+	// LabelColor and LabelSize, in points, style placemark labels like the layer's text symbol.
+	LabelColor []int
+	LabelSize  float64
+}
+
+// KMLLayer is one layer of a KML document with several layers.
+type KMLLayer struct {
+	// Name is the layer name, used for the layer's folder.
+	Name string
+	// GeoJSON holds the layer's features.
+	GeoJSON *convert.GeoJSON
+	// FolderPath and FolderFor group the layer's placemarks as in KMLOptions.
+	FolderPath []string
+	FolderFor  func(feature *convert.GeoJSONFeature) string
+	// Fields defines the layer's attribute schema as in KMLOptions.
+	Fields []arcgis.Field
+	// TimeField and EndTimeField name the layer's time attributes as in KMLOptions.
+	TimeField    string
+	EndTimeField string
+	// NameFor, LabelColor and LabelSize name and label the layer's placemarks as in KMLOptions.
+	NameFor    func(feature *convert.GeoJSONFeature) string
+	LabelColor []int
+	LabelSize  float64
+}
+
 // ConvertGeoJSONToKML converts a GeoJSON FeatureCollection to a KML string.
 // The function handles:
-//   - Point, LineString, and Polygon geometries
-//   - Feature properties and attributes
+//   - Point, LineString, and Polygon geometries and their multi-part variants
+//   - Feature attributes as typed ExtendedData following a Schema of the layer fields
+//   - Placemark descriptions from an optional HTML balloon template
+//   - TimeStamp or TimeSpan elements from the layer's time fields
+//   - Placemark names from label expressions and label styles from text symbols
 //   - Symbol styling and icons
 //   - Embedded images and base64 data
 //
@@ -27,85 +89,191 @@ import (
 //   - string: KML document as a string
 //   - error: Any error that occurred during conversion
 func ConvertGeoJSONToKML(geoJSON *convert.GeoJSON, layerName string) (string, error) {
+	return ConvertGeoJSONToKMLWithOptions(geoJSON, layerName, KMLOptions{})
+}
+
+// ConvertGeoJSONToKMLWithOptions converts a GeoJSON FeatureCollection to a KML string
+// using the given options. See ConvertGeoJSONToKML for the handled content.
+//
+// Parameters:
+//   - geoJSON: Pointer to a GeoJSON FeatureCollection
+//   - layerName: Name of the layer to be used in the KML document
+//   - opts: Options controlling how symbol images are referenced and placemarks are grouped
+//
+// Returns:
+//   - string: KML document as a string
+//   - error: Any error that occurred during conversion
+func ConvertGeoJSONToKMLWithOptions(geoJSON *convert.GeoJSON, layerName string, opts KMLOptions) (string, error) {
+	layer := KMLLayer{
+		Name:         layerName,
+		GeoJSON:      geoJSON,
+		FolderPath:   opts.FolderPath,
+		FolderFor:    opts.FolderFor,
+		Fields:       opts.Fields,
+		TimeField:    opts.TimeField,
+		EndTimeField: opts.EndTimeField,
+		NameFor:      opts.NameFor,
+		LabelColor:   opts.LabelColor,
+		LabelSize:    opts.LabelSize,
+	}
+	return convertLayersToKML(layerName, []KMLLayer{layer}, opts, false)
+}
+
+// ConvertLayersToKML converts several layers to a single KML document.
+// The function handles:
+//   - A folder per layer, nested in the layer's folder path
+//   - Layers sharing a folder path, which share the enclosing folders
+//   - Class or attribute folders within each layer
+//   - A Schema per layer for the layer's ExtendedData
+//   - Styles shared by all layers
+//
+// Parameters:
+//   - documentName: Name of the KML document
+//   - layers: Layers to include, in document order
+//   - opts: Options controlling symbol images and the balloon template; folders,
+//     fields, time fields, names and labels are taken from each layer instead
+//
+// Returns:
+//   - string: KML document as a string
+//   - error: Any error that occurred during conversion
+func ConvertLayersToKML(documentName string, layers []KMLLayer, opts KMLOptions) (string, error) {
+	return convertLayersToKML(documentName, layers, opts, true)
+}
+
+// kmlFolder is a folder of a KML document being assembled, holding placemarks and subfolders.
+type kmlFolder struct {
+	name       string
+	placemarks []kmlPlacemark
+	children   []*kmlFolder
+	byName     map[string]*kmlFolder
+}
+
+// kmlPlacemark holds the rendered parts of a placemark until its folder depth is known.
+type kmlPlacemark struct {
+	name, description, styleRef, geometry string
+	timePrimitive                         string
+	schemaID                              string
+	data                                  []kmlSimpleData
+}
+
+// kmlSimpleData is a typed attribute value of a placemark.
+type kmlSimpleData struct {
+	name, value string
+}
+
+// folder returns the subfolder at path, creating missing folders in first-seen order.
+func (f *kmlFolder) folder(path []string) *kmlFolder {
+	current := f
+	for _, name := range path {
+		child, ok := current.byName[name]
+		if !ok {
+			child = &kmlFolder{name: name, byName: make(map[string]*kmlFolder)}
+			current.byName[name] = child
+			current.children = append(current.children, child)
+		}
+		current = child
+	}
+	return current
+}
+
+// write writes the folder's placemarks followed by its subfolders.
+func (f *kmlFolder) write(b *strings.Builder, indent string) {
+	for _, p := range f.placemarks {
+		fmt.Fprintf(b, "\n%s<Placemark>\n%s    <name>%s</name>", indent, indent, escapeXML(p.name))
+		if p.description != "" {
+			fmt.Fprintf(b, "\n%s    <description><![CDATA[%s]]></description>", indent, escapeCDATA(p.description))
+		}
+		for _, part := range []string{p.timePrimitive, p.styleRef} {
+			if part != "" {
+				fmt.Fprintf(b, "\n%s    %s", indent, part)
+			}
+		}
+		if len(p.data) > 0 {
+			fmt.Fprintf(b, "\n%[1]s    <ExtendedData>\n%[1]s        <SchemaData schemaUrl=\"#%[2]s\">", indent, p.schemaID)
+			for _, d := range p.data {
+				fmt.Fprintf(b, "\n%s            <SimpleData name=\"%s\">%s</SimpleData>", indent, escapeXML(d.name), escapeXML(d.value))
+			}
+			fmt.Fprintf(b, "\n%[1]s        </SchemaData>\n%[1]s    </ExtendedData>", indent)
+		}
+		fmt.Fprintf(b, "\n%s    %s\n%s</Placemark>", indent, p.geometry, indent)
+	}
+	for _, child := range f.children {
+		fmt.Fprintf(b, "\n%s<Folder>\n%s    <name>%s</name>", indent, indent, escapeXML(child.name))
+		child.write(b, indent+"    ")
+		fmt.Fprintf(b, "\n%s</Folder>", indent)
+	}
+}
+
+// convertLayersToKML writes the layers to one KML document, wrapping each layer in its own
+// folder when perLayerFolders is set.
+func convertLayersToKML(documentName string, layers []KMLLayer, opts KMLOptions, perLayerFolders bool) (string, error) {
 	var styles strings.Builder
-	var placemarks strings.Builder
 	styleMap := make(map[string]string) // Map to track unique styles
 	imageMap := make(map[string]string) // Map to track embedded images
 
-	// First pass: collect all unique styles and images
-	for _, feature := range geoJSON.Features {
-		// Try to get symbol from feature's Symbol field first
-		if feature.Symbol != nil {
-			styleID := generateStyleID(feature.Symbol)
-			if _, exists := styleMap[styleID]; !exists {
-				// Handle embedded image if present
-				if feature.Symbol.ImageData != "" {
-					feature.Symbol.URL = fmt.Sprintf("data:%s;base64,%s",
-						feature.Symbol.ContentType,
-						feature.Symbol.ImageData)
-				}
-				styleMap[styleID] = generateKMLStyle(feature.Symbol)
+	// registerStyle adds the style of a symbol and the layer's labels, returning its ID,
+	// or "" when the placemark has neither.
+	registerStyle := func(symbol *convert.Symbol, layer KMLLayer) string {
+		labelStyle := ""
+		if len(layer.LabelColor) >= 3 || layer.LabelSize > 0 {
+			labelStyle = kmlLabelStyle(layer.LabelColor, layer.LabelSize)
+		}
+		if symbol == nil && labelStyle == "" {
+			return ""
+		}
+		styleID := "style"
+		if symbol != nil {
+			styleID = generateStyleID(symbol)
+		}
+		if labelStyle != "" {
+			h := fnv.New32a()
+			h.Write([]byte(labelStyle))
+			styleID += fmt.Sprintf("_label_%08x", h.Sum32())
+		}
+		if _, exists := styleMap[styleID]; !exists {
+			switch {
+			case symbol == nil:
+				styleMap[styleID] = labelStyle
+			case labelStyle == "":
+				styleMap[styleID] = generateKMLStyle(kmlIconSymbol(symbol, opts))
+			default:
+				styleMap[styleID] = generateSymbolStyle(kmlIconSymbol(symbol, opts)) + labelStyle
 			}
-		} else if symbolData, ok := feature.Properties["symbol"]; ok {
-			if symbolMap, ok := symbolData.(map[string]interface{}); ok {
-				symbol := &convert.Symbol{
-					Type:        getString(symbolMap, "type"),
-					URL:         getString(symbolMap, "url"),
-					ImageData:   getString(symbolMap, "imageData"),
-					ContentType: getString(symbolMap, "contentType"),
-					Width:       getInt(symbolMap, "width"),
-					Height:      getInt(symbolMap, "height"),
-					XOffset:     getInt(symbolMap, "xoffset"),
-					YOffset:     getInt(symbolMap, "yoffset"),
-					Angle:       getFloat(symbolMap, "angle"),
-				}
-				feature.Symbol = symbol
-				styleID := generateStyleID(symbol)
-				if _, exists := styleMap[styleID]; !exists {
-					if symbol.ImageData != "" {
-						symbol.URL = fmt.Sprintf("data:%s;base64,%s",
-							symbol.ContentType,
-							symbol.ImageData)
-					}
-					styleMap[styleID] = generateKMLStyle(symbol)
+		}
+		return styleID
+	}
+
+	// First pass: collect all unique styles and images
+	for _, layer := range layers {
+		for _, feature := range layer.GeoJSON.Features {
+			// Try to get symbol from feature's Symbol field first
+			if feature.Symbol != nil {
+				registerStyle(feature.Symbol, layer)
+			} else if symbolData, ok := feature.Properties["symbol"]; ok {
+				if symbolMap, ok := symbolData.(map[string]interface{}); ok {
+					symbol := symbolFromMap(symbolMap)
+					feature.Symbol = symbol
+					registerStyle(symbol, layer)
 				}
-			}
-		} else if rendererData, ok := feature.Properties["renderer"]; ok {
-			if rendererMap, ok := rendererData.(map[string]interface{}); ok {
-				if rendererType, ok := rendererMap["type"].(string); ok && rendererType == "uniqueValue" {
-					if field1, ok := rendererMap["field1"].(string); ok {
-						if value, ok := feature.Properties[field1]; ok {
-							if groups, ok := rendererMap["uniqueValueGroups"].([]interface{}); ok {
-								for _, group := range groups {
-									if groupMap, ok := group.(map[string]interface{}); ok {
-										if classes, ok := groupMap["classes"].([]interface{}); ok {
-											for _, class := range classes {
-												if classMap, ok := class.(map[string]interface{}); ok {
-													if values, ok := classMap["values"].([]interface{}); ok {
-														for _, val := range values {
-															if valArray, ok := val.([]interface{}); ok && len(valArray) > 0 {
-																if valArray[0] == value {
-																	if symbolMap, ok := classMap["symbol"].(map[string]interface{}); ok {
-																		symbol := &convert.Symbol{
-																			Type:        getString(symbolMap, "type"),
-																			URL:         getString(symbolMap, "url"),
-																			ImageData:   getString(symbolMap, "imageData"),
-																			ContentType: getString(symbolMap, "contentType"),
-																			Width:       getInt(symbolMap, "width"),
-																			Height:      getInt(symbolMap, "height"),
-																			XOffset:     getInt(symbolMap, "xoffset"),
-																			YOffset:     getInt(symbolMap, "yoffset"),
-																			Angle:       getFloat(symbolMap, "angle"),
-																		}
-																		feature.Symbol = symbol
-																		styleID := generateStyleID(symbol)
-																		if _, exists := styleMap[styleID]; !exists {
-																			if symbol.ImageData != "" {
-																				symbol.URL = fmt.Sprintf("data:%s;base64,%s",
-																					symbol.ContentType,
-																					symbol.ImageData)
-																			}
-																			styleMap[styleID] = generateKMLStyle(symbol)
+			} else if rendererData, ok := feature.Properties["renderer"]; ok {
+				if rendererMap, ok := rendererData.(map[string]interface{}); ok {
+					if rendererType, ok := rendererMap["type"].(string); ok && rendererType == "uniqueValue" {
+						if field1, ok := rendererMap["field1"].(string); ok {
+							if value, ok := feature.Properties[field1]; ok {
+								if groups, ok := rendererMap["uniqueValueGroups"].([]interface{}); ok {
+									for _, group := range groups {
+										if groupMap, ok := group.(map[string]interface{}); ok {
+											if classes, ok := groupMap["classes"].([]interface{}); ok {
+												for _, class := range classes {
+													if classMap, ok := class.(map[string]interface{}); ok {
+														if values, ok := classMap["values"].([]interface{}); ok {
+															for _, val := range values {
+																if valArray, ok := val.([]interface{}); ok && len(valArray) > 0 {
+																	if valArray[0] == value {
+																		if symbolMap, ok := classMap["symbol"].(map[string]interface{}); ok {
+																			symbol := symbolFromMap(symbolMap)
+																			feature.Symbol = symbol
+																			registerStyle(symbol, layer)
 																		}
 																	}
 																}
@@ -122,19 +290,49 @@ func ConvertGeoJSONToKML(geoJSON *convert.GeoJSON, layerName string) (string, er
 					}
 				}
 			}
+			if feature.Symbol == nil {
+				registerStyle(nil, layer)
+			}
 		}
 	}
 
-	// Write all styles
-	for styleID, styleXML := range styleMap {
+	// Write a schema per layer describing the placemarks' typed attributes
+	var schemas strings.Builder
+	layerSchemas := make([]*kmlSchema, len(layers))
+	usedSchemaIDs := make(map[string]bool)
+	for i, layer := range layers {
+		schema := newKMLSchema(layer, usedSchemaIDs)
+		layerSchemas[i] = schema
+		if len(schema.fields) == 0 {
+			continue
+		}
+		schemas.WriteString(fmt.Sprintf(`
+        <Schema name="%s" id="%s">`, escapeXML(layer.Name), schema.id))
+		for _, field := range schema.fields {
+			schemas.WriteString(fmt.Sprintf(`
+            <SimpleField name="%s" type="%s">`, escapeXML(field.Name), kmlFieldTypes[field.Type]))
+			if field.Alias != "" {
+				schemas.WriteString(fmt.Sprintf(`
+                <displayName><![CDATA[%s]]></displayName>`, escapeCDATA(field.Alias)))
+			}
+			schemas.WriteString(`
+            </SimpleField>`)
+		}
+		schemas.WriteString(`
+        </Schema>`)
+	}
+
+	// Write all styles, sorted by ID so that the same input gives the same document
+	for _, styleID := range sortedKeys(styleMap) {
 		styles.WriteString(fmt.Sprintf(`
         <Style id="%s">
             %s
-        </Style>`, styleID, styleXML))
+        </Style>`, styleID, styleMap[styleID]))
 	}
 
 	// Write all embedded images
-	for imageID, imageData := range imageMap {
+	for _, imageID := range sortedKeys(imageMap) {
+		imageData := imageMap[imageID]
 		styles.WriteString(fmt.Sprintf(`
         <GroundOverlay id="%s">
             <Icon>
@@ -143,88 +341,192 @@ func ConvertGeoJSONToKML(geoJSON *convert.GeoJSON, layerName string) (string, er
         </GroundOverlay>`, imageID, getContentType(imageData), imageData))
 	}
 
-	// Second pass: write placemarks with style references
-	for _, feature := range geoJSON.Features {
-		if feature.Geometry == nil {
-			continue
+	// Second pass: write placemarks with style references into their folders
+	root := &kmlFolder{byName: make(map[string]*kmlFolder)}
+	for l, layer := range layers {
+		schema := layerSchemas[l]
+		layerPath := append([]string(nil), layer.FolderPath...)
+		if perLayerFolders {
+			layerPath = append(layerPath, layer.Name)
 		}
+		layerFolder := root.folder(layerPath)
 
-		name := getFeatureName(feature)
-		description := formatProperties(feature.Properties, "<br>")
-
-		geometryMap := feature.Geometry.(map[string]interface{})
-		geometryType := geometryMap["type"].(string)
-		coordinates := geometryMap["coordinates"]
+		for i := range layer.GeoJSON.Features {
+			feature := layer.GeoJSON.Features[i]
+			if feature.Geometry == nil {
+				continue
+			}
 
-		var geometryString string
-		switch geometryType {
-		case "Point":
-			coords, ok := coordinates.([]float64)
-			if ok && len(coords) >= 2 {
-				geometryString = fmt.Sprintf("<Point><coordinates>%.10f,%.10f,0</coordinates></Point>", coords[0], coords[1])
+			name := ""
+			if layer.NameFor != nil {
+				name = layer.NameFor(&layer.GeoJSON.Features[i])
 			}
-		case "LineString":
-			coords, ok := coordinates.([][]float64)
-			if ok && len(coords) > 0 {
-				coordStr := make([]string, len(coords))
-				for i, c := range coords {
-					coordStr[i] = fmt.Sprintf("%.10f,%.10f,0", c[0], c[1])
-				}
-				geometryString = fmt.Sprintf("<LineString><coordinates>%s</coordinates></LineString>", strings.Join(coordStr, " "))
+			if name == "" {
+				name = getFeatureName(feature)
 			}
-		case "Polygon":
-			coords, ok := coordinates.([][][]float64)
-			if ok && len(coords) > 0 {
-				var outerBoundary, innerBoundaries strings.Builder
-				outerRing := coords[0]
-				outerCoordStr := make([]string, len(outerRing))
-				for i, c := range outerRing {
-					outerCoordStr[i] = fmt.Sprintf("%.10f,%.10f,0", c[0], c[1])
+			description := ""
+			if opts.BalloonTemplate != "" {
+				description = renderBalloonTemplate(opts.BalloonTemplate, feature.Properties)
+			}
+
+			geometryMap := feature.Geometry.(map[string]interface{})
+			geometryType := geometryMap["type"].(string)
+			coordinates := geometryMap["coordinates"]
+
+			geometryString := kmlGeometry(geometryType, coordinates)
+
+			if geometryString != "" {
+				styleRef := ""
+				if styleID := registerStyle(feature.Symbol, layer); styleID != "" {
+					styleRef = fmt.Sprintf(`<styleUrl>#%s</styleUrl>`, styleID)
 				}
-				outerBoundary.WriteString(fmt.Sprintf("<outerBoundaryIs><LinearRing><coordinates>%s</coordinates></LinearRing></outerBoundaryIs>", strings.Join(outerCoordStr, " ")))
 
-				if len(coords) > 1 {
-					for _, innerRing := range coords[1:] {
-						innerCoordStr := make([]string, len(innerRing))
-						for i, c := range innerRing {
-							innerCoordStr[i] = fmt.Sprintf("%.10f,%.10f,0", c[0], c[1])
-						}
-						innerBoundaries.WriteString(fmt.Sprintf("<innerBoundaryIs><LinearRing><coordinates>%s</coordinates></LinearRing></innerBoundaryIs>", strings.Join(innerCoordStr, " ")))
+				folder := layerFolder
+				if layer.FolderFor != nil {
+					if folderName := layer.FolderFor(&layer.GeoJSON.Features[i]); folderName != "" {
+						folder = layerFolder.folder([]string{folderName})
 					}
 				}
-				geometryString = fmt.Sprintf("<Polygon>%s%s</Polygon>", outerBoundary.String(), innerBoundaries.String())
-			}
-		default:
-			fmt.Printf("  Warning: Unsupported geometry type for KML conversion: %s\n", geometryType)
-		}
-
-		if geometryString != "" {
-			styleRef := ""
-			if feature.Symbol != nil {
-				styleID := generateStyleID(feature.Symbol)
-				styleRef = fmt.Sprintf(`<styleUrl>#%s</styleUrl>`, styleID)
+				folder.placemarks = append(folder.placemarks, kmlPlacemark{
+					name:          name,
+					description:   description,
+					styleRef:      styleRef,
+					geometry:      geometryString,
+					timePrimitive: kmlTimePrimitive(feature.Properties, layer.TimeField, layer.EndTimeField),
+					schemaID:      schema.id,
+					data:          schema.data(feature.Properties),
+				})
 			}
-
-			placemarks.WriteString(fmt.Sprintf(`
-        <Placemark>
-            <name>%s</name>
-            <description><![CDATA[%s]]></description>
-            %s
-            %s
-        </Placemark>`, escapeXML(name), description, styleRef, geometryString))
 		}
 	}
 
+	var body strings.Builder
+	root.write(&body, "        ")
+
 	kml := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
 <kml xmlns="http://www.opengis.net/kml/2.2">
     <Document>
-        <name>%s</name>%s%s
+        <name>%s</name>%s%s%s
     </Document>
-</kml>`, escapeXML(layerName), styles.String(), placemarks.String())
+</kml>`, escapeXML(documentName), schemas.String(), styles.String(), body.String())
 
 	return kml, nil
 }
 
+// kmlTimePrimitive returns a TimeStamp for a placemark with a start time only, a TimeSpan
+// when it also has an end time, or "" when neither time is set.
+func kmlTimePrimitive(properties map[string]interface{}, startField, endField string) string {
+	var begin, end string
+	if t, ok := convert.ParseFeatureTime(properties[startField]); ok && startField != "" {
+		begin = t.Format(time.RFC3339Nano)
+	}
+	if t, ok := convert.ParseFeatureTime(properties[endField]); ok && endField != "" {
+		end = t.Format(time.RFC3339Nano)
+	}
+	switch {
+	case begin != "" && end == "":
+		return fmt.Sprintf("<TimeStamp><when>%s</when></TimeStamp>", begin)
+	case begin != "" || end != "":
+		var span strings.Builder
+		span.WriteString("<TimeSpan>")
+		if begin != "" {
+			span.WriteString(fmt.Sprintf("<begin>%s</begin>", begin))
+		}
+		span.WriteString(fmt.Sprintf("<end>%s</end></TimeSpan>", end))
+		return span.String()
+	}
+	return ""
+}
+
+// kmlSchema is the typed attribute schema of a layer in a KML document.
+type kmlSchema struct {
+	id     string
+	fields []arcgis.Field
+}
+
+// newKMLSchema selects the layer fields that can be written as KML SimpleFields, taking
+// them from the layer or inferring them from the attributes, and assigns a unique schema ID.
+func newKMLSchema(layer KMLLayer, usedIDs map[string]bool) *kmlSchema {
+	fields := layer.Fields
+	if len(fields) == 0 {
+		attributes := make([]map[string]interface{}, len(layer.GeoJSON.Features))
+		for i, feature := range layer.GeoJSON.Features {
+			attributes[i] = feature.Properties
+		}
+		fields = inferEsriFields(attributes)
+	}
+	schema := &kmlSchema{}
+	for _, field := range fields {
+		if _, ok := kmlFieldTypes[field.Type]; ok && field.Name != KeySymbol {
+			schema.fields = append(schema.fields, field)
+		}
+	}
+
+	// Schema IDs are XML IDs, so they follow the same naming rules as GML element names.
+	id := gmlName(layer.Name, "Layer") + KMLSchemaIDSuffix
+	for base, n := id, 2; usedIDs[id]; n++ {
+		id = fmt.Sprintf("%s_%d", base, n)
+	}
+	usedIDs[id] = true
+	schema.id = id
+	return schema
+}
+
+// data returns a placemark's values for the schema fields in field order, omitting nulls.
+func (s *kmlSchema) data(properties map[string]interface{}) []kmlSimpleData {
+	var data []kmlSimpleData
+	for _, field := range s.fields {
+		if value, ok := properties[field.Name]; ok && value != nil {
+			data = append(data, kmlSimpleData{name: field.Name, value: gmlValue(value, field.Type)})
+		}
+	}
+	return data
+}
+
+// renderBalloonTemplate replaces $[FIELD] placeholders in an HTML balloon template with
+// the HTML-escaped attribute values. Null values become empty, and placeholders that do
+// not name an attribute are kept for the KML viewer.
+func renderBalloonTemplate(template string, properties map[string]interface{}) string {
+	return balloonPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		name := placeholder[2 : len(placeholder)-1]
+		value, ok := properties[name]
+		if !ok || name == KeySymbol {
+			return placeholder
+		}
+		if value == nil {
+			return ""
+		}
+		return html.EscapeString(gmlValue(value, ""))
+	})
+}
+
+// escapeCDATA splits any "]]>" in text so that it can be placed inside a CDATA section.
+func escapeCDATA(text string) string {
+	return strings.ReplaceAll(text, "]]>", "]]]]><![CDATA[>")
+}
+
+// balloonPlaceholder matches $[FIELD] placeholders in balloon templates.
+var balloonPlaceholder = regexp.MustCompile(`\$\[[^\[\]]+\]`)
+
+// kmlFieldTypes maps Esri field types to KML SimpleField types.
+// Dates are written as ISO 8601 strings; shape, blob and raster fields are not written.
+var kmlFieldTypes = map[string]string{
+	EsriFieldTypeOID:               "int",
+	EsriFieldTypeInteger:           "int",
+	EsriFieldTypeSmallInteger:      "short",
+	"esriFieldTypeBigInteger":      "double",
+	EsriFieldTypeDouble:            "double",
+	"esriFieldTypeSingle":          "float",
+	EsriFieldTypeString:            "string",
+	EsriFieldTypeDate:              "string",
+	"esriFieldTypeDateOnly":        "string",
+	"esriFieldTypeTimeOnly":        "string",
+	"esriFieldTypeGUID":            "string",
+	"esriFieldTypeGlobalID":        "string",
+	"esriFieldTypeXML":             "string",
+	"esriFieldTypeTimestampOffset": "string",
+}
+
 // getContentType determines the content type from base64 data.
 // It examines the first few bytes of the decoded data to identify common image formats:
 //   - JPEG: Starts with 0xFF 0xD8
@@ -260,24 +562,72 @@ func getContentType(base64Data string) string {
 }
 
 // generateStyleID creates a unique style ID for a symbol.
-// The ID is based on the symbol's type, dimensions, offset, and angle.
+// The ID is based on the symbol's type, dimensions, offset, and angle, plus a hash
+// of its image, colors and styles so that different symbols of the same size get
+// separate styles.
 func generateStyleID(symbol *convert.Symbol) string {
-	return fmt.Sprintf("style_%s_%d_%d_%d_%d_%.2f",
+	id := fmt.Sprintf("style_%s_%g_%g_%g_%g_%.2f",
 		symbol.Type,
 		symbol.Width,
 		symbol.Height,
 		symbol.XOffset,
 		symbol.YOffset,
 		symbol.Angle)
+	if symbol.ImageData != "" || symbol.URL != "" || symbol.Color != nil || symbol.Style != "" || symbol.Outline != nil {
+		h := fnv.New32a()
+		h.Write([]byte(symbol.ImageData))
+		h.Write([]byte(symbol.URL))
+		h.Write([]byte(fmt.Sprintf("%v|%v|%s", symbol.Color, symbol.Size, symbol.Style)))
+		if outline := symbol.Outline; outline != nil {
+			h.Write([]byte(fmt.Sprintf("|%v|%v|%s", outline.Color, outline.Width, outline.Style)))
+		}
+		id += fmt.Sprintf("_%08x", h.Sum32())
+	}
+	return id
+}
+
+// kmlIconSymbol returns a copy of a symbol whose URL points at its image, leaving
+// the original untouched. Simple markers are first rendered to PNG; embedded images
+// are referenced through opts.ImageHref or written as data: URIs.
+func kmlIconSymbol(symbol *convert.Symbol, opts KMLOptions) *convert.Symbol {
+	iconSymbol := *symbol
+	if symbol.Type == "esriSMS" && symbol.ImageData == "" {
+		image, dim, err := renderMarkerPNG(symbol)
+		if err != nil {
+			fmt.Fprintf(Output, "  Warning: Failed to render marker symbol: %v\n", err)
+			return &iconSymbol
+		}
+		iconSymbol.ImageData = base64.StdEncoding.EncodeToString(image)
+		iconSymbol.ContentType = "image/png"
+		// Express the icon size in points, like picture marker dimensions.
+		iconSymbol.Width = float64(dim) * PointsPerInch / ScreenDPI
+		iconSymbol.Height = iconSymbol.Width
+	}
+	if iconSymbol.ImageData == "" {
+		return &iconSymbol
+	}
+	if opts.ImageHref != nil {
+		if href := opts.ImageHref(&iconSymbol); href != "" {
+			iconSymbol.URL = href
+			return &iconSymbol
+		}
+	}
+	iconSymbol.URL = fmt.Sprintf("data:%s;base64,%s", iconSymbol.ContentType, iconSymbol.ImageData)
+	return &iconSymbol
 }
 
 // generateKMLStyle creates a KML style based on the symbol type.
 // Supports the following symbol types:
 //   - esriPMS: Picture marker symbol
-//   - esriSMS: Simple marker symbol
+//   - esriSMS: Simple marker symbol, drawn as a rendered icon
 //   - esriSLS: Simple line symbol
 //   - esriSFS: Simple fill symbol
 func generateKMLStyle(symbol *convert.Symbol) string {
+	return generateSymbolStyle(symbol) + kmlLabelStyle(nil, 0)
+}
+
+// generateSymbolStyle creates the icon, line and polygon styles for a symbol.
+func generateSymbolStyle(symbol *convert.Symbol) string {
 	switch symbol.Type {
 	case "esriPMS", "esriSMS":
 		return generatePictureMarkerStyle(symbol)
@@ -291,11 +641,16 @@ func generateKMLStyle(symbol *convert.Symbol) string {
 }
 
 // generatePictureMarkerStyle creates a KML style for picture markers.
-// Handles icon scaling, rotation, and hotspot positioning.
+// Handles icon scaling, rotation, and hotspot positioning. Esri offsets move the
+// symbol away from the point and angles turn counter-clockwise, whereas KML anchors
+// a hotspot inside the icon and turns clockwise.
 func generatePictureMarkerStyle(symbol *convert.Symbol) string {
 	scale := 1.0
+	hotSpotX, hotSpotY := 0.5, 0.5
 	if symbol.Width > 0 && symbol.Height > 0 {
-		scale = float64(symbol.Width) / 32.0 // Normalize to a reasonable size
+		scale = pointsToPixels(symbol.Width) / KMLIconSize
+		hotSpotX -= symbol.XOffset / symbol.Width
+		hotSpotY -= symbol.YOffset / symbol.Height
 	}
 
 	return fmt.Sprintf(`
@@ -306,51 +661,83 @@ func generatePictureMarkerStyle(symbol *convert.Symbol) string {
                     <href>%s</href>
                 </Icon>
                 <hotSpot x="%.2f" y="%.2f" xunits="fraction" yunits="fraction"/>
-            </IconStyle>
-            <LabelStyle>
-                <scale>1.0</scale>
-            </LabelStyle>`,
+            </IconStyle>`,
 		scale,
-		symbol.Angle,
-		symbol.URL,
-		float64(symbol.XOffset)/float64(symbol.Width),
-		float64(symbol.YOffset)/float64(symbol.Height))
+		math.Mod(360-symbol.Angle, 360),
+		html.EscapeString(symbol.URL),
+		hotSpotX,
+		hotSpotY)
 }
 
 // generateSimpleLineStyle creates a KML style for simple lines.
-// Sets line width and color.
+// Sets line width in pixels and the symbol's color; esriSLSNull lines are invisible.
 func generateSimpleLineStyle(symbol *convert.Symbol) string {
-	width := 2
-	if symbol.Width > 0 {
-		width = symbol.Width
-	}
-
 	return fmt.Sprintf(`
-            <LineStyle>
-                <width>%d</width>
-                <color>ff0000ff</color>
-            </LineStyle>
-            <LabelStyle>
-                <scale>1.0</scale>
-            </LabelStyle>`, width)
+            %s`, kmlLineStyle(symbol))
 }
 
 // generateSimpleFillStyle creates a KML style for simple fills.
-// Sets polygon fill color, outline, and label style.
+// Sets polygon fill color, outline color and width.
+// Null and hollow fills, or fills without a color, are not filled.
 func generateSimpleFillStyle(symbol *convert.Symbol) string {
-	return `
+	fillColor := kmlColor(symbol.Color, KMLTransparent)
+	fill := 1
+	if symbol.Style == "esriSFSNull" || symbol.Style == "esriSFSHollow" || fillColor[:2] == "00" {
+		fill = 0
+	}
+	outline := 0
+	if symbol.Outline != nil && symbol.Outline.Style != "esriSLSNull" && kmlColor(symbol.Outline.Color, KMLTransparent)[:2] != "00" {
+		outline = 1
+	}
+
+	return fmt.Sprintf(`
             <PolyStyle>
-                <color>7f0000ff</color>
-                <fill>1</fill>
-                <outline>1</outline>
+                <color>%s</color>
+                <fill>%d</fill>
+                <outline>%d</outline>
             </PolyStyle>
-            <LineStyle>
-                <width>2</width>
-                <color>ff0000ff</color>
-            </LineStyle>
-            <LabelStyle>
-                <scale>1.0</scale>
-            </LabelStyle>`
+            %s`, fillColor, fill, outline, kmlLineStyle(symbol.Outline))
+}
+
+// kmlLabelStyle creates a KML LabelStyle from a text symbol's color and font size in
+// points. Without a color, labels keep the viewer's default color; without a size, the
+// default scale.
+func kmlLabelStyle(color []int, size float64) string {
+	scale := 1.0
+	if size > 0 {
+		scale = size / KMLLabelBaseSize
+	}
+	colorElement := ""
+	if len(color) >= 3 {
+		colorElement = fmt.Sprintf(`
+                <color>%s</color>`, kmlColor(color, KMLDefaultLabelColor))
+	}
+	scaleText := strconv.FormatFloat(math.Round(scale*100)/100, 'f', -1, 64)
+	if !strings.Contains(scaleText, ".") {
+		scaleText += ".0"
+	}
+	return fmt.Sprintf(`
+            <LabelStyle>%s
+                <scale>%s</scale>
+            </LabelStyle>`, colorElement, scaleText)
+}
+
+// kmlLineStyle creates a KML LineStyle element from an esriSLS symbol.
+func kmlLineStyle(symbol *convert.Symbol) string {
+	if symbol == nil || symbol.Style == "esriSLSNull" {
+		return `<LineStyle>
+                <width>0</width>
+                <color>00000000</color>
+            </LineStyle>`
+	}
+	width := pointsToPixels(symbol.Width)
+	if width <= 0 {
+		width = pointsToPixels(DefaultLineWidth)
+	}
+	return fmt.Sprintf(`<LineStyle>
+                <width>%s</width>
+                <color>%s</color>
+            </LineStyle>`, strconv.FormatFloat(math.Round(width*100)/100, 'f', -1, 64), kmlColor(symbol.Color, KMLDefaultLineColor))
 }
 
 // generateDefaultStyle creates a default KML style.
@@ -362,39 +749,128 @@ func generateDefaultStyle() string {
                 <Icon>
                     <href>http://maps.google.com/mapfiles/kml/shapes/placemark_circle.png</href>
                 </Icon>
-            </IconStyle>
-            <LabelStyle>
-                <scale>1.0</scale>
-            </LabelStyle>`
+            </IconStyle>`
+}
+
+// kmlGeometry converts GeoJSON coordinates to a KML geometry element.
+// Multi-part geometries become a MultiGeometry; unsupported types yield an empty string.
+func kmlGeometry(geometryType string, coordinates interface{}) string {
+	switch geometryType {
+	case "Point":
+		coords, ok := coordinates.([]float64)
+		if ok && len(coords) >= 2 {
+			return fmt.Sprintf("<Point><coordinates>%s</coordinates></Point>", kmlCoordinates([][]float64{coords}))
+		}
+	case "LineString":
+		coords, ok := coordinates.([][]float64)
+		if ok && len(coords) > 0 {
+			return fmt.Sprintf("<LineString><coordinates>%s</coordinates></LineString>", kmlCoordinates(coords))
+		}
+	case "Polygon":
+		coords, ok := coordinates.([][][]float64)
+		if ok && len(coords) > 0 {
+			var polygon strings.Builder
+			polygon.WriteString("<Polygon>")
+			polygon.WriteString(fmt.Sprintf("<outerBoundaryIs><LinearRing><coordinates>%s</coordinates></LinearRing></outerBoundaryIs>", kmlCoordinates(coords[0])))
+			for _, innerRing := range coords[1:] {
+				polygon.WriteString(fmt.Sprintf("<innerBoundaryIs><LinearRing><coordinates>%s</coordinates></LinearRing></innerBoundaryIs>", kmlCoordinates(innerRing)))
+			}
+			polygon.WriteString("</Polygon>")
+			return polygon.String()
+		}
+	case "MultiPoint":
+		if coords, ok := coordinates.([][]float64); ok && len(coords) > 0 {
+			parts := make([]interface{}, len(coords))
+			for i, c := range coords {
+				parts[i] = c
+			}
+			return kmlMultiGeometry("Point", parts)
+		}
+	case "MultiLineString":
+		if coords, ok := coordinates.([][][]float64); ok && len(coords) > 0 {
+			parts := make([]interface{}, len(coords))
+			for i, c := range coords {
+				parts[i] = c
+			}
+			return kmlMultiGeometry("LineString", parts)
+		}
+	case "MultiPolygon":
+		if coords, ok := coordinates.([][][][]float64); ok && len(coords) > 0 {
+			parts := make([]interface{}, len(coords))
+			for i, c := range coords {
+				parts[i] = c
+			}
+			return kmlMultiGeometry("Polygon", parts)
+		}
+	default:
+		fmt.Fprintf(Output, "  Warning: Unsupported geometry type for KML conversion: %s\n", geometryType)
+	}
+	return ""
+}
+
+// kmlMultiGeometry wraps the KML geometries of several parts of the same type in a MultiGeometry.
+func kmlMultiGeometry(partType string, parts []interface{}) string {
+	var multi strings.Builder
+	multi.WriteString("<MultiGeometry>")
+	for _, part := range parts {
+		multi.WriteString(kmlGeometry(partType, part))
+	}
+	multi.WriteString("</MultiGeometry>")
+	return multi.String()
+}
+
+// kmlCoordinates formats positions as a KML coordinate tuple list.
+func kmlCoordinates(coords [][]float64) string {
+	coordStr := make([]string, len(coords))
+	for i, c := range coords {
+		coordStr[i] = fmt.Sprintf("%.10f,%.10f,0", c[0], c[1])
+	}
+	return strings.Join(coordStr, " ")
 }
 
 // getFeatureName extracts a suitable name from a GeoJSON feature's properties.
 // Checks common property names in order: name, Name, NAME, title, Title, TITLE, OBJECTID, FID.
+// Values are formatted as attribute text, so that large IDs keep their plain digits.
 // Returns "Feature" if no suitable name is found.
 func getFeatureName(feature convert.GeoJSONFeature) string {
 	props := feature.Properties
 	for _, key := range []string{"name", "Name", "NAME", "title", "Title", "TITLE", "OBJECTID", "FID"} {
 		if val, ok := props[key]; ok && val != nil {
-			return fmt.Sprintf("%v", val)
+			return convert.FormatValue(val)
 		}
 	}
 	return "Feature"
 }
 
+// sortedKeys returns the keys of a string map in sorted order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // formatProperties formats a map of properties into a string.
-// Excludes geometry and symbol properties.
+// Excludes geometry and symbol properties and null values and lists the rest sorted by name.
 // Uses HTML formatting for better readability in KML.
 func formatProperties(props map[string]interface{}, separator ...string) string {
 	sep := "<br>"
 	if len(separator) > 0 {
 		sep = separator[0]
 	}
-	var parts []string
-	for k, v := range props {
-		if k == "geometry" || k == "symbol" {
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		if k == "geometry" || k == "symbol" || props[k] == nil {
 			continue
 		}
-		parts = append(parts, fmt.Sprintf("<strong>%s</strong>: %v", escapeXML(k), escapeXML(fmt.Sprintf("%v", v))))
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("<strong>%s</strong>: %s", escapeXML(k), escapeXML(convert.FormatValue(props[k]))))
 	}
 	return strings.Join(parts, sep)
 }
@@ -423,17 +899,6 @@ func getString(m map[string]interface{}, key string) string {
 	return ""
 }
 
-// getInt extracts an integer value from a map.
-// Returns 0 if key doesn't exist or value is not a number.
-func getInt(m map[string]interface{}, key string) int {
-	if val, ok := m[key]; ok {
-		if num, ok := val.(float64); ok {
-			return int(num)
-		}
-	}
-	return 0
-}
-
 // getFloat extracts a float64 value from a map.
 // Returns 0 if key doesn't exist or value is not a number.
 func getFloat(m map[string]interface{}, key string) float64 {
@@ -0,0 +1,142 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package export provides functions for converting GeoJSON data to various export formats.
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"path"
+
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/convert"
+)
+
+// ConvertGeoJSONToKMZ converts a GeoJSON FeatureCollection to a KMZ archive.
+// The function handles:
+//   - doc.kml as the first entry, generated as by ConvertGeoJSONToKML
+//   - Decoding every embedded symbol image into the files/ folder, stored once per image
+//   - Icon hrefs rewritten to the relative paths of those files
+//
+// Symbols whose image data cannot be decoded keep a data: URI.
+//
+// Parameters:
+//   - geoJSON: Pointer to a GeoJSON FeatureCollection
+//   - layerName: Name of the layer to be used in the KML document
+//
+// Returns:
+//   - []byte: KMZ archive contents
+//   - error: Any error that occurred during conversion or compression
+func ConvertGeoJSONToKMZ(geoJSON *convert.GeoJSON, layerName string) ([]byte, error) {
+	return ConvertGeoJSONToKMZWithOptions(geoJSON, layerName, KMLOptions{})
+}
+
+// ConvertGeoJSONToKMZWithOptions converts a GeoJSON FeatureCollection to a KMZ archive,
+// grouping placemarks as configured in opts. Image hrefs are always set by the archive.
+//
+// Parameters:
+//   - geoJSON: Pointer to a GeoJSON FeatureCollection
+//   - layerName: Name of the layer to be used in the KML document
+//   - opts: Options controlling how placemarks are grouped into folders
+//
+// Returns:
+//   - []byte: KMZ archive contents
+//   - error: Any error that occurred during conversion or compression
+func ConvertGeoJSONToKMZWithOptions(geoJSON *convert.GeoJSON, layerName string, opts KMLOptions) ([]byte, error) {
+	return buildKMZ(opts, func(opts KMLOptions) (string, error) {
+		return ConvertGeoJSONToKMLWithOptions(geoJSON, layerName, opts)
+	})
+}
+
+// ConvertLayersToKMZ converts several layers to a single KMZ archive with a folder per
+// layer, as ConvertLayersToKML does, and symbol images stored as by ConvertGeoJSONToKMZ.
+//
+// Parameters:
+//   - documentName: Name of the KML document
+//   - layers: Layers to include, in document order
+//   - opts: Options for the document; image hrefs are always set by the archive
+//
+// Returns:
+//   - []byte: KMZ archive contents
+//   - error: Any error that occurred during conversion or compression
+func ConvertLayersToKMZ(documentName string, layers []KMLLayer, opts KMLOptions) ([]byte, error) {
+	return buildKMZ(opts, func(opts KMLOptions) (string, error) {
+		return ConvertLayersToKML(documentName, layers, opts)
+	})
+}
+
+// buildKMZ renders a KML document with image hrefs pointing into the archive and
+// packages it together with the decoded images.
+func buildKMZ(opts KMLOptions, render func(opts KMLOptions) (string, error)) ([]byte, error) {
+	var fileNames []string
+	files := make(map[string][]byte)
+	hrefs := make(map[string]string) // Base64 image data -> relative path
+
+	opts.ImageHref = func(symbol *convert.Symbol) string {
+		if href, ok := hrefs[symbol.ImageData]; ok {
+			return href
+		}
+		data, err := base64.StdEncoding.DecodeString(symbol.ImageData)
+		if err != nil {
+			fmt.Fprintf(Output, "  Warning: Failed to decode symbol image for KMZ: %v\n", err)
+			hrefs[symbol.ImageData] = ""
+			return ""
+		}
+		contentType := symbol.ContentType
+		if contentType == "" {
+			contentType = getContentType(symbol.ImageData)
+		}
+		href := path.Join(KMZFilesDir, fmt.Sprintf("symbol_%d%s", len(fileNames)+1, imageExtension(contentType)))
+		fileNames = append(fileNames, href)
+		files[href] = data
+		hrefs[symbol.ImageData] = href
+		return href
+	}
+
+	kml, err := render(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create(KMZDocument)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add %s to KMZ: %v", KMZDocument, err)
+	}
+	if _, err := w.Write([]byte(kml)); err != nil {
+		return nil, fmt.Errorf("failed to write %s to KMZ: %v", KMZDocument, err)
+	}
+	for _, name := range fileNames {
+		// Images are already compressed, so they are stored as-is.
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s to KMZ: %v", name, err)
+		}
+		if _, err := w.Write(files[name]); err != nil {
+			return nil, fmt.Errorf("failed to write %s to KMZ: %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize KMZ: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// imageExtension returns the file extension for an image content type.
+func imageExtension(contentType string) string {
+	switch contentType {
+	case "image/jpeg", "image/jpg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	case "image/svg+xml":
+		return ".svg"
+	case "image/bmp":
+		return ".bmp"
+	default:
+		return ".png"
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package export provides functions for converting GeoJSON data to various export formats.
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/convert"
+)
+
+// vectorLayer describes a tile layer in MBTiles and PMTiles metadata.
+type vectorLayer struct {
+	ID      string            `json:"id"`
+	Fields  map[string]string `json:"fields"`
+	MinZoom int               `json:"minzoom"`
+	MaxZoom int               `json:"maxzoom"`
+}
+
+// ConvertGeoJSONToMBTiles converts a GeoJSON FeatureCollection to an MBTiles 1.3 database
+// containing gzip-compressed Mapbox Vector Tiles.
+//
+// Parameters:
+//   - geoJSON: Pointer to a GeoJSON FeatureCollection in WGS84
+//   - layerName: Name of the tileset and of its vector tile layer
+//   - opts: Tile generation options
+//
+// Returns:
+//   - []byte: SQLite database file contents
+//   - error: Any error that occurred during tiling or encoding
+func ConvertGeoJSONToMBTiles(geoJSON *convert.GeoJSON, layerName string, opts TileOptions) ([]byte, error) {
+	tileSet, err := GenerateVectorTiles(geoJSON, layerName, opts)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeMBTiles(tileSet)
+}
+
+// EncodeMBTiles writes a TileSet as an MBTiles 1.3 SQLite database.
+// The function handles:
+//   - The metadata table, including the vector_layers "json" entry
+//   - The tiles table with TMS row numbering and gzip-compressed tile data
+//   - A unique index on zoom level, column and row for fast lookups
+//
+// Parameters:
+//   - tileSet: Tiles and metadata to write
+//
+// Returns:
+//   - []byte: SQLite database file contents
+//   - error: Any error that occurred during encoding
+func EncodeMBTiles(tileSet *TileSet) ([]byte, error) {
+	layersJSON, err := json.Marshal(map[string]interface{}{
+		"vector_layers": vectorLayers(tileSet),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal vector layer metadata: %v", err)
+	}
+
+	metadata := [][]interface{}{
+		{"name", tileSet.LayerName},
+		{"format", "pbf"},
+		{"type", "overlay"},
+		{"version", "1"},
+		{"description", fmt.Sprintf("%s exported by arcgis-utils", tileSet.LayerName)},
+		{"minzoom", strconv.Itoa(tileSet.MinZoom)},
+		{"maxzoom", strconv.Itoa(tileSet.MaxZoom)},
+		{"bounds", formatFloatList(tileSet.Bounds[:])},
+		{"center", formatFloatList([]float64{
+			(tileSet.Bounds[0] + tileSet.Bounds[2]) / 2,
+			(tileSet.Bounds[1] + tileSet.Bounds[3]) / 2,
+			float64(tileSet.MinZoom),
+		})},
+		{"json", string(layersJSON)},
+	}
+
+	ids := sortedTileIDs(tileSet)
+	tiles := make([][]interface{}, 0, len(ids))
+	for _, id := range ids {
+		data, err := gzipBytes(tileSet.Tiles[id])
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress tile %d/%d/%d: %v", id.Z, id.X, id.Y, err)
+		}
+		tmsRow := (1 << id.Z) - 1 - id.Y
+		tiles = append(tiles, []interface{}{int64(id.Z), int64(id.X), int64(tmsRow), data})
+	}
+	// The index requires rows sorted by zoom, column and TMS row.
+	sort.SliceStable(tiles, func(i, j int) bool {
+		for c := 0; c < 3; c++ {
+			a, b := tiles[i][c].(int64), tiles[j][c].(int64)
+			if a != b {
+				return a < b
+			}
+		}
+		return false
+	})
+
+	return writeSQLite([]sqliteTable{
+		{
+			Name: "metadata",
+			SQL:  "CREATE TABLE metadata (name text, value text)",
+			Rows: metadata,
+		},
+		{
+			Name: "tiles",
+			SQL:  "CREATE TABLE tiles (zoom_level integer, tile_column integer, tile_row integer, tile_data blob)",
+			Rows: tiles,
+			Index: &sqliteIndex{
+				Name:    "tile_index",
+				SQL:     "CREATE UNIQUE INDEX tile_index ON tiles (zoom_level, tile_column, tile_row)",
+				Columns: []int{0, 1, 2},
+			},
+		},
+	}, MBTilesApplicationID)
+}
+
+// vectorLayers describes the layer of a TileSet for tileset metadata.
+func vectorLayers(tileSet *TileSet) []vectorLayer {
+	return []vectorLayer{{
+		ID:      tileSet.LayerName,
+		Fields:  tileSet.Fields,
+		MinZoom: tileSet.MinZoom,
+		MaxZoom: tileSet.MaxZoom,
+	}}
+}
+
+// sortedTileIDs returns the IDs of all tiles in a TileSet ordered by zoom, column and row.
+func sortedTileIDs(tileSet *TileSet) []TileID {
+	ids := make([]TileID, 0, len(tileSet.Tiles))
+	for id := range tileSet.Tiles {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if ids[i].Z != ids[j].Z {
+			return ids[i].Z < ids[j].Z
+		}
+		if ids[i].X != ids[j].X {
+			return ids[i].X < ids[j].X
+		}
+		return ids[i].Y < ids[j].Y
+	})
+	return ids
+}
+
+// formatFloatList formats numbers as a comma-separated list without trailing zeros.
+func formatFloatList(values []float64) string {
+	var buf bytes.Buffer
+	for i, v := range values {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	return buf.String()
+}
+
+// gzipWriters reuses gzip writers, which are expensive to allocate, across tiles.
+var gzipWriters = sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}
+
+// gzipBytes compresses data with gzip.
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(zw)
+	zw.Reset(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
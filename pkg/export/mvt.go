@@ -0,0 +1,781 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package export provides functions for converting GeoJSON data to various export formats.
+package export
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/convert"
+)
+
+// TileOptions configures vector tile generation.
+// Zero values for Extent, Buffer and Tolerance select DefaultTileExtent, DefaultTileBuffer
+// and DefaultSimplifyTolerance. An empty Attributes list keeps all attributes.
+type TileOptions struct {
+	MinZoom    int
+	MaxZoom    int
+	Extent     int
+	Buffer     int
+	Tolerance  float64
+	Attributes []string
+}
+
+// TileID identifies a tile in the XYZ tiling scheme.
+type TileID struct {
+	Z int
+	X int
+	Y int
+}
+
+// TileSet holds the encoded Mapbox Vector Tiles generated for a layer.
+type TileSet struct {
+	LayerName string
+	MinZoom   int
+	MaxZoom   int
+	Bounds    [4]float64
+	Fields    map[string]string
+	Tiles     map[TileID][]byte
+}
+
+// tileFeature is a feature projected to normalized Web Mercator coordinates in [0,1].
+type tileFeature struct {
+	id           uint64
+	hasID        bool
+	geometryType int
+	// parts holds points, lines, or polygon rings; polygons lists the ring count of each polygon.
+	parts    [][][2]float64
+	polygons []int
+	props    []tileProperty
+	bbox     [4]float64
+}
+
+// tileProperty is a feature attribute with its value encoded as an MVT Value message.
+type tileProperty struct {
+	key   string
+	value []byte
+}
+
+// tileEntry is a feature clipped to a single tile.
+type tileEntry struct {
+	feature  *tileFeature
+	geometry []uint32
+}
+
+// tileLayerBuilder collects the keys and values of one MVT layer.
+type tileLayerBuilder struct {
+	keys       []string
+	keyIndex   map[string]uint32
+	values     [][]byte
+	valueIndex map[string]uint32
+}
+
+// GenerateVectorTiles cuts a GeoJSON FeatureCollection into Mapbox Vector Tiles.
+// The function handles:
+//   - Projection from WGS84 longitude/latitude to Web Mercator
+//   - Per-zoom Douglas-Peucker simplification in tile pixel units
+//   - Clipping of lines and polygons to each tile plus a buffer
+//   - MVT 2.1 protobuf encoding with a single layer per tile
+//   - Attribute filtering; symbol information is always dropped
+//
+// Parameters:
+//   - geoJSON: Pointer to a GeoJSON FeatureCollection in WGS84
+//   - layerName: Name of the vector tile layer
+//   - opts: Zoom range, tile extent, buffer, tolerance and attribute filter
+//
+// Returns:
+//   - *TileSet: The generated tiles and tileset metadata
+//   - error: Any error that occurred during tiling
+func GenerateVectorTiles(geoJSON *convert.GeoJSON, layerName string, opts TileOptions) (*TileSet, error) {
+	opts = normalizeTileOptions(opts)
+	if opts.MinZoom < 0 || opts.MaxZoom > MaxTileZoom || opts.MinZoom > opts.MaxZoom {
+		return nil, fmt.Errorf("invalid zoom range %d-%d: must be within 0-%d", opts.MinZoom, opts.MaxZoom, MaxTileZoom)
+	}
+
+	allowed := make(map[string]bool, len(opts.Attributes))
+	for _, name := range opts.Attributes {
+		allowed[name] = true
+	}
+
+	tileSet := &TileSet{
+		LayerName: layerName,
+		MinZoom:   opts.MinZoom,
+		MaxZoom:   opts.MaxZoom,
+		Bounds:    [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)},
+		Fields:    make(map[string]string),
+		Tiles:     make(map[TileID][]byte),
+	}
+
+	var features []tileFeature
+	for _, feature := range geoJSON.Features {
+		tf, ok := projectTileFeature(feature, tileSet)
+		if !ok {
+			continue
+		}
+		keys := make([]string, 0, len(feature.Properties))
+		for k := range feature.Properties {
+			if k == KeySymbol || (len(allowed) > 0 && !allowed[k]) {
+				continue
+			}
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			encoded, fieldType, ok := encodeTileValue(feature.Properties[k])
+			if !ok {
+				continue
+			}
+			tileSet.Fields[k] = fieldType
+			tf.props = append(tf.props, tileProperty{key: k, value: encoded})
+		}
+		if id, ok := tileFeatureID(feature.Properties); ok {
+			tf.id, tf.hasID = id, true
+		}
+		features = append(features, tf)
+	}
+	if len(features) == 0 {
+		tileSet.Bounds = [4]float64{-180, -MaxMercatorLatitude, 180, MaxMercatorLatitude}
+		return tileSet, nil
+	}
+	if estimate := estimateTileCount(features, opts); estimate > TileCountWarning {
+		fmt.Fprintf(Output, "  Warning: Up to %d vector tiles cover %s at zooms %d-%d and are all held in memory; consider a lower maximum zoom.\n", estimate, layerName, opts.MinZoom, opts.MaxZoom)
+	}
+
+	for z := opts.MinZoom; z <= opts.MaxZoom; z++ {
+		scale := float64(int(1) << z)
+		tolerance := opts.Tolerance / (float64(opts.Extent) * scale)
+		buffer := float64(opts.Buffer) / (float64(opts.Extent) * scale)
+		tileFeatures := make(map[TileID][]tileEntry)
+
+		for fi := range features {
+			feature := &features[fi]
+			simplified := feature.simplify(tolerance)
+			minX := clampTile(math.Floor((feature.bbox[0]-buffer)*scale), scale)
+			minY := clampTile(math.Floor((feature.bbox[1]-buffer)*scale), scale)
+			maxX := clampTile(math.Floor((feature.bbox[2]+buffer)*scale), scale)
+			maxY := clampTile(math.Floor((feature.bbox[3]+buffer)*scale), scale)
+
+			for x := minX; x <= maxX; x++ {
+				for y := minY; y <= maxY; y++ {
+					geometry := simplified.tileGeometry(z, x, y, opts)
+					if len(geometry) == 0 {
+						continue
+					}
+					id := TileID{Z: z, X: x, Y: y}
+					tileFeatures[id] = append(tileFeatures[id], tileEntry{feature: feature, geometry: geometry})
+				}
+			}
+		}
+
+		for id, entries := range tileFeatures {
+			tileSet.Tiles[id] = encodeTile(layerName, entries, opts.Extent)
+		}
+	}
+
+	return tileSet, nil
+}
+
+// estimateTileCount returns the number of tiles covering the bounds of the features over
+// the zoom range, an upper bound of the tiles generated.
+func estimateTileCount(features []tileFeature, opts TileOptions) int64 {
+	bbox := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, feature := range features {
+		bbox[0] = math.Min(bbox[0], feature.bbox[0])
+		bbox[1] = math.Min(bbox[1], feature.bbox[1])
+		bbox[2] = math.Max(bbox[2], feature.bbox[2])
+		bbox[3] = math.Max(bbox[3], feature.bbox[3])
+	}
+	var count int64
+	for z := opts.MinZoom; z <= opts.MaxZoom; z++ {
+		scale := float64(int(1) << z)
+		columns := clampTile(math.Floor(bbox[2]*scale), scale) - clampTile(math.Floor(bbox[0]*scale), scale) + 1
+		rows := clampTile(math.Floor(bbox[3]*scale), scale) - clampTile(math.Floor(bbox[1]*scale), scale) + 1
+		count += int64(columns) * int64(rows)
+	}
+	return count
+}
+
+// normalizeTileOptions fills in defaults for unset tile options.
+func normalizeTileOptions(opts TileOptions) TileOptions {
+	if opts.Extent <= 0 {
+		opts.Extent = DefaultTileExtent
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultTileBuffer
+	}
+	if opts.Tolerance <= 0 {
+		opts.Tolerance = DefaultSimplifyTolerance
+	}
+	return opts
+}
+
+// clampTile converts a tile coordinate to an int clamped to the valid range for the zoom scale.
+func clampTile(v, scale float64) int {
+	return int(math.Max(0, math.Min(scale-1, v)))
+}
+
+// projectTileFeature projects a GeoJSON feature to normalized Web Mercator coordinates
+// and extends the tileset bounds with its longitude/latitude extent.
+func projectTileFeature(feature convert.GeoJSONFeature, tileSet *TileSet) (tileFeature, bool) {
+	geometryMap, ok := feature.Geometry.(map[string]interface{})
+	if !ok {
+		return tileFeature{}, false
+	}
+	tf := tileFeature{bbox: [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}}
+	project := func(coords [][]float64) [][2]float64 {
+		part := make([][2]float64, 0, len(coords))
+		for _, c := range coords {
+			if len(c) < 2 {
+				continue
+			}
+			tileSet.Bounds[0] = math.Min(tileSet.Bounds[0], c[0])
+			tileSet.Bounds[1] = math.Min(tileSet.Bounds[1], c[1])
+			tileSet.Bounds[2] = math.Max(tileSet.Bounds[2], c[0])
+			tileSet.Bounds[3] = math.Max(tileSet.Bounds[3], c[1])
+			p := lonLatToMercator(c[0], c[1])
+			tf.bbox[0] = math.Min(tf.bbox[0], p[0])
+			tf.bbox[1] = math.Min(tf.bbox[1], p[1])
+			tf.bbox[2] = math.Max(tf.bbox[2], p[0])
+			tf.bbox[3] = math.Max(tf.bbox[3], p[1])
+			part = append(part, p)
+		}
+		return part
+	}
+
+	geometryType, _ := geometryMap[KeyType].(string)
+	switch coordinates := geometryMap["coordinates"].(type) {
+	case []float64:
+		if geometryType != "Point" {
+			return tileFeature{}, false
+		}
+		tf.geometryType = MVTGeomPoint
+		tf.parts = [][][2]float64{project([][]float64{coordinates})}
+	case [][]float64:
+		if geometryType == "MultiPoint" {
+			tf.geometryType = MVTGeomPoint
+		} else {
+			tf.geometryType = MVTGeomLineString
+		}
+		tf.parts = [][][2]float64{project(coordinates)}
+	case [][][]float64:
+		if geometryType == "Polygon" {
+			tf.geometryType = MVTGeomPolygon
+			tf.polygons = []int{len(coordinates)}
+		} else {
+			tf.geometryType = MVTGeomLineString
+		}
+		for _, part := range coordinates {
+			tf.parts = append(tf.parts, project(part))
+		}
+	case [][][][]float64:
+		tf.geometryType = MVTGeomPolygon
+		for _, polygon := range coordinates {
+			tf.polygons = append(tf.polygons, len(polygon))
+			for _, ring := range polygon {
+				tf.parts = append(tf.parts, project(ring))
+			}
+		}
+	default:
+		fmt.Fprintf(Output, "  Warning: Unsupported geometry type for vector tile conversion: %s\n", geometryType)
+		return tileFeature{}, false
+	}
+	if math.IsInf(tf.bbox[0], 1) {
+		return tileFeature{}, false
+	}
+	return tf, true
+}
+
+// lonLatToMercator projects WGS84 longitude/latitude to Web Mercator normalized to [0,1],
+// with y increasing southwards like tile rows.
+func lonLatToMercator(lon, lat float64) [2]float64 {
+	lat = math.Max(-MaxMercatorLatitude, math.Min(MaxMercatorLatitude, lat))
+	sin := math.Sin(lat * math.Pi / 180)
+	x := lon/360 + 0.5
+	y := 0.5 - 0.25*math.Log((1+sin)/(1-sin))/math.Pi
+	return [2]float64{x, y}
+}
+
+// tileFeatureID returns the OBJECTID of a feature as an MVT feature ID, if it has a non-negative one.
+func tileFeatureID(props map[string]interface{}) (uint64, bool) {
+	switch v := props[KeyOBJECTID].(type) {
+	case float64:
+		if v >= 0 && v == math.Trunc(v) {
+			return uint64(v), true
+		}
+	case int:
+		if v >= 0 {
+			return uint64(v), true
+		}
+	case int64:
+		if v >= 0 {
+			return uint64(v), true
+		}
+	}
+	return 0, false
+}
+
+// simplify returns a copy of the feature with its lines and rings simplified
+// using the Douglas-Peucker algorithm with the given tolerance.
+func (f tileFeature) simplify(tolerance float64) tileFeature {
+	if f.geometryType == MVTGeomPoint {
+		return f
+	}
+	out := f
+	out.parts = make([][][2]float64, len(f.parts))
+	for i, part := range f.parts {
+		out.parts[i] = simplifyLine(part, tolerance)
+	}
+	return out
+}
+
+// simplifyLine simplifies a line with the Douglas-Peucker algorithm, always keeping its endpoints.
+func simplifyLine(points [][2]float64, tolerance float64) [][2]float64 {
+	if len(points) <= 2 {
+		return points
+	}
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+	sqTolerance := tolerance * tolerance
+
+	stack := [][2]int{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		span := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		maxDist, index := 0.0, -1
+		for i := span[0] + 1; i < span[1]; i++ {
+			if d := sqSegmentDistance(points[i], points[span[0]], points[span[1]]); d > maxDist {
+				maxDist, index = d, i
+			}
+		}
+		if index != -1 && maxDist > sqTolerance {
+			keep[index] = true
+			stack = append(stack, [2]int{span[0], index}, [2]int{index, span[1]})
+		}
+	}
+
+	simplified := make([][2]float64, 0, len(points))
+	for i, p := range points {
+		if keep[i] {
+			simplified = append(simplified, p)
+		}
+	}
+	return simplified
+}
+
+// sqSegmentDistance returns the squared distance from p to the segment a-b.
+func sqSegmentDistance(p, a, b [2]float64) float64 {
+	x, y := a[0], a[1]
+	dx, dy := b[0]-x, b[1]-y
+	if dx != 0 || dy != 0 {
+		t := ((p[0]-x)*dx + (p[1]-y)*dy) / (dx*dx + dy*dy)
+		if t > 1 {
+			x, y = b[0], b[1]
+		} else if t > 0 {
+			x += dx * t
+			y += dy * t
+		}
+	}
+	dx, dy = p[0]-x, p[1]-y
+	return dx*dx + dy*dy
+}
+
+// tileGeometry clips the feature to a tile and encodes it as MVT geometry commands.
+// It returns nil if nothing of the feature remains inside the buffered tile.
+func (f tileFeature) tileGeometry(z, x, y int, opts TileOptions) []uint32 {
+	scale := float64(int(1) << z)
+	extent := float64(opts.Extent)
+	toTile := func(part [][2]float64) [][2]float64 {
+		out := make([][2]float64, len(part))
+		for i, p := range part {
+			out[i] = [2]float64{(p[0]*scale - float64(x)) * extent, (p[1]*scale - float64(y)) * extent}
+		}
+		return out
+	}
+	box := [4]float64{-float64(opts.Buffer), -float64(opts.Buffer), extent + float64(opts.Buffer), extent + float64(opts.Buffer)}
+
+	enc := &geometryEncoder{}
+	switch f.geometryType {
+	case MVTGeomPoint:
+		var points [][2]int
+		for _, p := range toTile(f.parts[0]) {
+			if p[0] >= box[0] && p[0] <= box[2] && p[1] >= box[1] && p[1] <= box[3] {
+				points = append(points, [2]int{int(math.Round(p[0])), int(math.Round(p[1]))})
+			}
+		}
+		if len(points) == 0 {
+			return nil
+		}
+		enc.moveTo(points)
+	case MVTGeomLineString:
+		for _, part := range f.parts {
+			for _, clipped := range clipLine(toTile(part), box) {
+				line := roundPoints(clipped)
+				if len(line) < 2 {
+					continue
+				}
+				enc.moveTo(line[:1])
+				enc.lineTo(line[1:])
+			}
+		}
+	case MVTGeomPolygon:
+		ringIdx := 0
+		for _, ringCount := range f.polygons {
+			rings := f.parts[ringIdx : ringIdx+ringCount]
+			ringIdx += ringCount
+			for i, ring := range rings {
+				points := roundPoints(clipRing(toTile(ring), box))
+				if len(points) > 1 && points[0] == points[len(points)-1] {
+					points = points[:len(points)-1]
+				}
+				if len(points) < 3 {
+					if i == 0 {
+						break // Exterior ring vanished, so its holes go too
+					}
+					continue
+				}
+				area := ringArea(points)
+				if area == 0 {
+					if i == 0 {
+						break
+					}
+					continue
+				}
+				// Exterior rings must have positive area in tile coordinates, holes negative.
+				if (i == 0) != (area > 0) {
+					for l, r := 0, len(points)-1; l < r; l, r = l+1, r-1 {
+						points[l], points[r] = points[r], points[l]
+					}
+				}
+				enc.moveTo(points[:1])
+				enc.lineTo(points[1:])
+				enc.closePath()
+			}
+		}
+	}
+	return enc.commands
+}
+
+// clipLine clips a line to a box, returning the parts that lie inside it.
+func clipLine(points [][2]float64, box [4]float64) [][][2]float64 {
+	var parts [][][2]float64
+	var current [][2]float64
+	for i := 0; i+1 < len(points); i++ {
+		a, b, ok := clipSegment(points[i], points[i+1], box)
+		if !ok {
+			if len(current) > 0 {
+				parts = append(parts, current)
+				current = nil
+			}
+			continue
+		}
+		if len(current) == 0 {
+			current = append(current, a)
+		} else if current[len(current)-1] != a {
+			parts = append(parts, current)
+			current = [][2]float64{a}
+		}
+		current = append(current, b)
+		if b != points[i+1] {
+			parts = append(parts, current)
+			current = nil
+		}
+	}
+	if len(current) > 0 {
+		parts = append(parts, current)
+	}
+	return parts
+}
+
+// clipSegment clips the segment a-b to a box using the Liang-Barsky algorithm.
+func clipSegment(a, b [2]float64, box [4]float64) ([2]float64, [2]float64, bool) {
+	t0, t1 := 0.0, 1.0
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	checks := [4][2]float64{
+		{-dx, a[0] - box[0]},
+		{dx, box[2] - a[0]},
+		{-dy, a[1] - box[1]},
+		{dy, box[3] - a[1]},
+	}
+	for _, c := range checks {
+		p, q := c[0], c[1]
+		if p == 0 {
+			if q < 0 {
+				return a, b, false
+			}
+			continue
+		}
+		r := q / p
+		if p < 0 {
+			if r > t1 {
+				return a, b, false
+			}
+			if r > t0 {
+				t0 = r
+			}
+		} else {
+			if r < t0 {
+				return a, b, false
+			}
+			if r < t1 {
+				t1 = r
+			}
+		}
+	}
+	start, end := a, b
+	if t0 > 0 {
+		start = [2]float64{a[0] + t0*dx, a[1] + t0*dy}
+	}
+	if t1 < 1 {
+		end = [2]float64{a[0] + t1*dx, a[1] + t1*dy}
+	}
+	return start, end, true
+}
+
+// clipRing clips a polygon ring to a box using the Sutherland-Hodgman algorithm.
+func clipRing(points [][2]float64, box [4]float64) [][2]float64 {
+	type edge struct {
+		inside    func(p [2]float64) bool
+		intersect func(a, b [2]float64) [2]float64
+	}
+	atX := func(a, b [2]float64, x float64) [2]float64 {
+		return [2]float64{x, a[1] + (b[1]-a[1])*(x-a[0])/(b[0]-a[0])}
+	}
+	atY := func(a, b [2]float64, y float64) [2]float64 {
+		return [2]float64{a[0] + (b[0]-a[0])*(y-a[1])/(b[1]-a[1]), y}
+	}
+	edges := []edge{
+		{func(p [2]float64) bool { return p[0] >= box[0] }, func(a, b [2]float64) [2]float64 { return atX(a, b, box[0]) }},
+		{func(p [2]float64) bool { return p[0] <= box[2] }, func(a, b [2]float64) [2]float64 { return atX(a, b, box[2]) }},
+		{func(p [2]float64) bool { return p[1] >= box[1] }, func(a, b [2]float64) [2]float64 { return atY(a, b, box[1]) }},
+		{func(p [2]float64) bool { return p[1] <= box[3] }, func(a, b [2]float64) [2]float64 { return atY(a, b, box[3]) }},
+	}
+
+	out := points
+	for _, e := range edges {
+		if len(out) == 0 {
+			break
+		}
+		in := out
+		out = nil
+		prev := in[len(in)-1]
+		for _, p := range in {
+			if e.inside(p) {
+				if !e.inside(prev) {
+					out = append(out, e.intersect(prev, p))
+				}
+				out = append(out, p)
+			} else if e.inside(prev) {
+				out = append(out, e.intersect(prev, p))
+			}
+			prev = p
+		}
+	}
+	return out
+}
+
+// roundPoints rounds points to the integer tile grid and removes consecutive duplicates.
+func roundPoints(points [][2]float64) [][2]int {
+	out := make([][2]int, 0, len(points))
+	for _, p := range points {
+		q := [2]int{int(math.Round(p[0])), int(math.Round(p[1]))}
+		if len(out) > 0 && out[len(out)-1] == q {
+			continue
+		}
+		out = append(out, q)
+	}
+	return out
+}
+
+// ringArea returns twice the signed area of a ring using the surveyor's formula.
+func ringArea(points [][2]int) int {
+	area := 0
+	for i := range points {
+		j := (i + 1) % len(points)
+		area += points[i][0]*points[j][1] - points[j][0]*points[i][1]
+	}
+	return area
+}
+
+// geometryEncoder builds an MVT geometry command stream.
+type geometryEncoder struct {
+	commands []uint32
+	cursor   [2]int
+}
+
+// moveTo emits a MoveTo command for the given points.
+func (e *geometryEncoder) moveTo(points [][2]int) {
+	e.emit(MVTCmdMoveTo, points)
+}
+
+// lineTo emits a LineTo command for the given points.
+func (e *geometryEncoder) lineTo(points [][2]int) {
+	e.emit(MVTCmdLineTo, points)
+}
+
+// closePath emits a ClosePath command.
+func (e *geometryEncoder) closePath() {
+	e.commands = append(e.commands, MVTCmdClosePath|1<<3)
+}
+
+// emit appends a command with delta-encoded, zigzagged parameters.
+func (e *geometryEncoder) emit(command uint32, points [][2]int) {
+	if len(points) == 0 {
+		return
+	}
+	e.commands = append(e.commands, command|uint32(len(points))<<3)
+	for _, p := range points {
+		dx, dy := p[0]-e.cursor[0], p[1]-e.cursor[1]
+		e.commands = append(e.commands, zigzag(int64(dx)), zigzag(int64(dy)))
+		e.cursor = p
+	}
+}
+
+// zigzag encodes a signed integer for MVT geometry parameters.
+func zigzag(v int64) uint32 {
+	return uint32((v << 1) ^ (v >> 63))
+}
+
+// encodeTileValue encodes an attribute value as an MVT Value message.
+// It returns the encoded message, the field type for tileset metadata, and false for null values.
+func encodeTileValue(value interface{}) ([]byte, string, bool) {
+	var pb protoBuffer
+	switch v := value.(type) {
+	case nil:
+		return nil, "", false
+	case string:
+		pb.bytesField(MVTValueString, []byte(v))
+		return pb.buf, TileFieldString, true
+	case bool:
+		b := uint64(0)
+		if v {
+			b = 1
+		}
+		pb.uintField(MVTValueBool, b)
+		return pb.buf, TileFieldBoolean, true
+	case int:
+		pb.sintField(MVTValueSint, int64(v))
+		return pb.buf, TileFieldNumber, true
+	case int64:
+		pb.sintField(MVTValueSint, v)
+		return pb.buf, TileFieldNumber, true
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			pb.sintField(MVTValueSint, int64(v))
+		} else {
+			pb.doubleField(MVTValueDouble, v)
+		}
+		return pb.buf, TileFieldNumber, true
+	default:
+		pb.bytesField(MVTValueString, []byte(fmt.Sprintf("%v", v)))
+		return pb.buf, TileFieldString, true
+	}
+}
+
+// key returns the index of a key in the layer's key table, adding it if needed.
+func (l *tileLayerBuilder) key(k string) uint32 {
+	if idx, ok := l.keyIndex[k]; ok {
+		return idx
+	}
+	idx := uint32(len(l.keys))
+	l.keys = append(l.keys, k)
+	l.keyIndex[k] = idx
+	return idx
+}
+
+// value returns the index of an encoded value in the layer's value table, adding it if needed.
+func (l *tileLayerBuilder) value(encoded []byte) uint32 {
+	if idx, ok := l.valueIndex[string(encoded)]; ok {
+		return idx
+	}
+	idx := uint32(len(l.values))
+	l.values = append(l.values, encoded)
+	l.valueIndex[string(encoded)] = idx
+	return idx
+}
+
+// encodeTile encodes the features clipped to one tile as a single-layer MVT tile.
+// Each tile carries its own key and value tables, holding only what its features use.
+func encodeTile(name string, entries []tileEntry, extent int) []byte {
+	l := &tileLayerBuilder{keyIndex: make(map[string]uint32), valueIndex: make(map[string]uint32)}
+
+	var layer protoBuffer
+	layer.uintField(MVTLayerVersion, MVTVersion)
+	layer.bytesField(MVTLayerName, []byte(name))
+	for _, entry := range entries {
+		tags := make([]uint32, 0, len(entry.feature.props)*2)
+		for _, prop := range entry.feature.props {
+			tags = append(tags, l.key(prop.key), l.value(prop.value))
+		}
+
+		var feature protoBuffer
+		if entry.feature.hasID {
+			feature.uintField(MVTFeatureID, entry.feature.id)
+		}
+		feature.packedField(MVTFeatureTags, tags)
+		feature.uintField(MVTFeatureType, uint64(entry.feature.geometryType))
+		feature.packedField(MVTFeatureGeometry, entry.geometry)
+		layer.bytesField(MVTLayerFeatures, feature.buf)
+	}
+	for _, k := range l.keys {
+		layer.bytesField(MVTLayerKeys, []byte(k))
+	}
+	for _, v := range l.values {
+		layer.bytesField(MVTLayerValues, v)
+	}
+	layer.uintField(MVTLayerExtent, uint64(extent))
+
+	var tile protoBuffer
+	tile.bytesField(MVTTileLayers, layer.buf)
+	return tile.buf
+}
+
+// protoBuffer is a minimal protocol buffers encoder.
+type protoBuffer struct {
+	buf []byte
+}
+
+// tag writes a field key.
+func (p *protoBuffer) tag(field int, wireType int) {
+	p.buf = binary.AppendUvarint(p.buf, uint64(field<<3|wireType))
+}
+
+// uintField writes a varint field.
+func (p *protoBuffer) uintField(field int, v uint64) {
+	p.tag(field, ProtoWireVarint)
+	p.buf = binary.AppendUvarint(p.buf, v)
+}
+
+// sintField writes a zigzag-encoded sint64 field.
+func (p *protoBuffer) sintField(field int, v int64) {
+	p.tag(field, ProtoWireVarint)
+	p.buf = binary.AppendUvarint(p.buf, uint64((v<<1)^(v>>63)))
+}
+
+// doubleField writes a 64-bit floating point field.
+func (p *protoBuffer) doubleField(field int, v float64) {
+	p.tag(field, ProtoWireFixed64)
+	p.buf = binary.LittleEndian.AppendUint64(p.buf, math.Float64bits(v))
+}
+
+// bytesField writes a length-delimited field.
+func (p *protoBuffer) bytesField(field int, b []byte) {
+	p.tag(field, ProtoWireBytes)
+	p.buf = binary.AppendUvarint(p.buf, uint64(len(b)))
+	p.buf = append(p.buf, b...)
+}
+
+// packedField writes a packed repeated uint32 field; empty slices are omitted.
+func (p *protoBuffer) packedField(field int, values []uint32) {
+	if len(values) == 0 {
+		return
+	}
+	var packed []byte
+	for _, v := range values {
+		packed = binary.AppendUvarint(packed, uint64(v))
+	}
+	p.bytesField(field, packed)
+}
@@ -0,0 +1,14 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package export provides functions for converting GeoJSON data to various export formats.
+package export
+
+import (
+	"io"
+	"os"
+)
+
+// Output receives the warnings printed during conversions. Commands writing their result
+// to stdout point it at stderr.
+var Output io.Writer = os.Stdout
@@ -0,0 +1,274 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package export provides functions for converting GeoJSON data to various export formats.
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/convert"
+)
+
+// pmtilesEntry is a PMTiles directory entry.
+// A RunLength of 0 marks a pointer to a leaf directory instead of tile data.
+type pmtilesEntry struct {
+	TileID    uint64
+	Offset    uint64
+	Length    uint32
+	RunLength uint32
+}
+
+// ConvertGeoJSONToPMTiles converts a GeoJSON FeatureCollection to a PMTiles v3 archive
+// containing gzip-compressed Mapbox Vector Tiles.
+//
+// Parameters:
+//   - geoJSON: Pointer to a GeoJSON FeatureCollection in WGS84
+//   - layerName: Name of the tileset and of its vector tile layer
+//   - opts: Tile generation options
+//
+// Returns:
+//   - []byte: PMTiles archive contents
+//   - error: Any error that occurred during tiling or encoding
+func ConvertGeoJSONToPMTiles(geoJSON *convert.GeoJSON, layerName string, opts TileOptions) ([]byte, error) {
+	tileSet, err := GenerateVectorTiles(geoJSON, layerName, opts)
+	if err != nil {
+		return nil, err
+	}
+	return EncodePMTiles(tileSet)
+}
+
+// EncodePMTiles writes a TileSet as a single-file PMTiles v3 archive.
+// The function handles:
+//   - The fixed-size header with bounds, center and zoom range
+//   - A root directory with delta-encoded Hilbert tile IDs and run lengths,
+//     split into leaf directories when it would not fit in the first 16 KiB
+//   - Clustered tile data ordered by tile ID, with identical tiles stored once
+//   - Gzip-compressed directories, metadata and tiles
+//
+// Parameters:
+//   - tileSet: Tiles and metadata to write
+//
+// Returns:
+//   - []byte: PMTiles archive contents
+//   - error: Any error that occurred during encoding
+func EncodePMTiles(tileSet *TileSet) ([]byte, error) {
+	ids := sortedTileIDs(tileSet)
+	type hilbertTile struct {
+		tileID uint64
+		data   []byte
+	}
+	tiles := make([]hilbertTile, 0, len(ids))
+	for _, id := range ids {
+		tiles = append(tiles, hilbertTile{tileID: zxyToTileID(id.Z, id.X, id.Y), data: tileSet.Tiles[id]})
+	}
+	sort.Slice(tiles, func(i, j int) bool { return tiles[i].tileID < tiles[j].tileID })
+
+	var tileData bytes.Buffer
+	var entries []pmtilesEntry
+	offsets := make(map[string]uint64) // Compressed tile contents -> offset, to store duplicates once
+	contents := uint64(0)
+	for _, tile := range tiles {
+		compressed, err := gzipBytes(tile.data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress tile %d: %v", tile.tileID, err)
+		}
+
+		offset, seen := offsets[string(compressed)]
+		if seen && len(entries) > 0 {
+			last := &entries[len(entries)-1]
+			if last.Offset == offset && last.TileID+uint64(last.RunLength) == tile.tileID {
+				last.RunLength++
+				continue
+			}
+		}
+		if !seen {
+			offset = uint64(tileData.Len())
+			offsets[string(compressed)] = offset
+			tileData.Write(compressed)
+			contents++
+		}
+		entries = append(entries, pmtilesEntry{TileID: tile.tileID, Offset: offset, Length: uint32(len(compressed)), RunLength: 1})
+	}
+
+	rootDir, leafDirs, err := buildPMTilesDirectories(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	layersJSON, err := json.Marshal(map[string]interface{}{
+		"name":          tileSet.LayerName,
+		"description":   fmt.Sprintf("%s exported by arcgis-utils", tileSet.LayerName),
+		"type":          "overlay",
+		"vector_layers": vectorLayers(tileSet),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal PMTiles metadata: %v", err)
+	}
+	metadata, err := gzipBytes(layersJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress PMTiles metadata: %v", err)
+	}
+
+	rootOffset := uint64(PMTilesHeaderSize)
+	metadataOffset := rootOffset + uint64(len(rootDir))
+	leafOffset := metadataOffset + uint64(len(metadata))
+	dataOffset := leafOffset + uint64(len(leafDirs))
+
+	addressed := uint64(0)
+	for _, entry := range entries {
+		addressed += uint64(entry.RunLength)
+	}
+
+	header := make([]byte, PMTilesHeaderSize)
+	copy(header, "PMTiles")
+	header[7] = PMTilesVersion
+	binary.LittleEndian.PutUint64(header[8:], rootOffset)
+	binary.LittleEndian.PutUint64(header[16:], uint64(len(rootDir)))
+	binary.LittleEndian.PutUint64(header[24:], metadataOffset)
+	binary.LittleEndian.PutUint64(header[32:], uint64(len(metadata)))
+	binary.LittleEndian.PutUint64(header[40:], leafOffset)
+	binary.LittleEndian.PutUint64(header[48:], uint64(len(leafDirs)))
+	binary.LittleEndian.PutUint64(header[56:], dataOffset)
+	binary.LittleEndian.PutUint64(header[64:], uint64(tileData.Len()))
+	binary.LittleEndian.PutUint64(header[72:], addressed)
+	binary.LittleEndian.PutUint64(header[80:], uint64(len(entries)))
+	binary.LittleEndian.PutUint64(header[88:], contents)
+	header[96] = 1 // Clustered
+	header[97] = PMTilesCompressionGzip
+	header[98] = PMTilesCompressionGzip
+	header[99] = PMTilesTileTypeMVT
+	header[100] = byte(tileSet.MinZoom)
+	header[101] = byte(tileSet.MaxZoom)
+	binary.LittleEndian.PutUint32(header[102:], uint32(e7(tileSet.Bounds[0])))
+	binary.LittleEndian.PutUint32(header[106:], uint32(e7(tileSet.Bounds[1])))
+	binary.LittleEndian.PutUint32(header[110:], uint32(e7(tileSet.Bounds[2])))
+	binary.LittleEndian.PutUint32(header[114:], uint32(e7(tileSet.Bounds[3])))
+	header[118] = byte(tileSet.MinZoom)
+	binary.LittleEndian.PutUint32(header[119:], uint32(e7((tileSet.Bounds[0]+tileSet.Bounds[2])/2)))
+	binary.LittleEndian.PutUint32(header[123:], uint32(e7((tileSet.Bounds[1]+tileSet.Bounds[3])/2)))
+
+	out := make([]byte, 0, int(dataOffset)+tileData.Len())
+	out = append(out, header...)
+	out = append(out, rootDir...)
+	out = append(out, metadata...)
+	out = append(out, leafDirs...)
+	out = append(out, tileData.Bytes()...)
+	return out, nil
+}
+
+// buildPMTilesDirectories encodes the root directory and, if needed, the leaf directories.
+// Entries are moved into leaf directories of growing size until the root directory
+// fits in the first PMTilesRootSize bytes of the archive together with the header.
+func buildPMTilesDirectories(entries []pmtilesEntry) ([]byte, []byte, error) {
+	root, err := encodePMTilesDirectory(entries)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(root) <= PMTilesRootSize-PMTilesHeaderSize {
+		return root, nil, nil
+	}
+
+	leafSize := float64(PMTilesLeafEntries)
+	for {
+		var leaves bytes.Buffer
+		var rootEntries []pmtilesEntry
+		size := int(leafSize)
+		for start := 0; start < len(entries); start += size {
+			end := start + size
+			if end > len(entries) {
+				end = len(entries)
+			}
+			leaf, err := encodePMTilesDirectory(entries[start:end])
+			if err != nil {
+				return nil, nil, err
+			}
+			rootEntries = append(rootEntries, pmtilesEntry{
+				TileID: entries[start].TileID,
+				Offset: uint64(leaves.Len()),
+				Length: uint32(len(leaf)),
+			})
+			leaves.Write(leaf)
+		}
+
+		root, err := encodePMTilesDirectory(rootEntries)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(root) <= PMTilesRootSize-PMTilesHeaderSize {
+			return root, leaves.Bytes(), nil
+		}
+		leafSize *= PMTilesLeafGrowth
+	}
+}
+
+// encodePMTilesDirectory serializes and gzip-compresses a directory.
+// Columns are written one after another: delta-encoded tile IDs, run lengths,
+// lengths, and offsets, where 0 means "directly after the previous entry".
+func encodePMTilesDirectory(entries []pmtilesEntry) ([]byte, error) {
+	var buf []byte
+	buf = binary.AppendUvarint(buf, uint64(len(entries)))
+	lastID := uint64(0)
+	for _, entry := range entries {
+		buf = binary.AppendUvarint(buf, entry.TileID-lastID)
+		lastID = entry.TileID
+	}
+	for _, entry := range entries {
+		buf = binary.AppendUvarint(buf, uint64(entry.RunLength))
+	}
+	for _, entry := range entries {
+		buf = binary.AppendUvarint(buf, uint64(entry.Length))
+	}
+	for i, entry := range entries {
+		if i > 0 && entry.Offset == entries[i-1].Offset+uint64(entries[i-1].Length) {
+			buf = binary.AppendUvarint(buf, 0)
+		} else {
+			buf = binary.AppendUvarint(buf, entry.Offset+1)
+		}
+	}
+	compressed, err := gzipBytes(buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress PMTiles directory: %v", err)
+	}
+	return compressed, nil
+}
+
+// zxyToTileID converts a tile to its PMTiles tile ID: the number of tiles on all
+// lower zoom levels plus the tile's position along a Hilbert curve on its own level.
+func zxyToTileID(z, x, y int) uint64 {
+	var acc uint64
+	for t := 0; t < z; t++ {
+		acc += uint64(1) << (2 * t)
+	}
+	n := uint64(1) << z
+	tx, ty := uint64(x), uint64(y)
+	var d uint64
+	for s := n / 2; s > 0; s /= 2 {
+		var rx, ry uint64
+		if tx&s > 0 {
+			rx = 1
+		}
+		if ty&s > 0 {
+			ry = 1
+		}
+		d += s * s * ((3 * rx) ^ ry)
+		if ry == 0 {
+			if rx == 1 {
+				tx = n - 1 - tx
+				ty = n - 1 - ty
+			}
+			tx, ty = ty, tx
+		}
+	}
+	return acc + d
+}
+
+// e7 converts a coordinate in degrees to the fixed-point integer used by PMTiles headers.
+func e7(v float64) int32 {
+	return int32(math.Round(v * 1e7))
+}
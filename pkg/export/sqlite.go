@@ -0,0 +1,441 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package export provides functions for converting GeoJSON data to various export formats.
+package export
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// sqliteTable describes a table written by writeSQLite.
+// Rows hold nil, int64, float64, string or []byte values and receive rowids 1..n.
+type sqliteTable struct {
+	Name  string
+	SQL   string
+	Rows  [][]interface{}
+	Index *sqliteIndex
+}
+
+// sqliteIndex describes an index over a sqliteTable.
+// The rows of the table must already be sorted by the indexed columns, and
+// index entries must be small enough to be stored without overflow pages.
+type sqliteIndex struct {
+	Name    string
+	SQL     string
+	Columns []int
+}
+
+// sqliteCell is an encoded b-tree cell together with the key it carries up to parent pages.
+type sqliteCell struct {
+	data   []byte
+	rowid  int64
+	record []byte
+}
+
+// sqliteWriter builds an SQLite database file page by page.
+type sqliteWriter struct {
+	pages [][]byte
+}
+
+// writeSQLite encodes the given tables and indexes as a complete SQLite 3 database file.
+// It only supports writing a fresh database: every table becomes a packed b-tree
+// with no free pages, which is all an export format such as MBTiles needs.
+//
+// Parameters:
+//   - tables: Tables to write, in schema order
+//   - applicationID: Value for the application_id header field
+//
+// Returns:
+//   - []byte: The database file contents
+//   - error: Any error that occurred while encoding
+func writeSQLite(tables []sqliteTable, applicationID uint32) ([]byte, error) {
+	w := &sqliteWriter{pages: [][]byte{nil}} // Page 1 is reserved for the schema
+
+	var schemaRows [][]interface{}
+	for _, table := range tables {
+		root := w.writeTable(table.Rows)
+		schemaRows = append(schemaRows, []interface{}{"table", table.Name, table.Name, int64(root), table.SQL})
+
+		if table.Index != nil {
+			root, err := w.writeIndex(table.Rows, table.Index.Columns)
+			if err != nil {
+				return nil, fmt.Errorf("failed to write index %s: %v", table.Index.Name, err)
+			}
+			schemaRows = append(schemaRows, []interface{}{"index", table.Index.Name, table.Name, int64(root), table.Index.SQL})
+		}
+	}
+
+	var schemaCells []sqliteCell
+	for i, row := range schemaRows {
+		schemaCells = append(schemaCells, w.tableLeafCell(int64(i+1), encodeSQLiteRecord(row)))
+	}
+	if !w.fits(schemaCells, SQLitePage1HeaderSize+SQLiteLeafHeaderSize) {
+		return nil, fmt.Errorf("schema does not fit on the first page")
+	}
+	w.pages[0] = w.buildPage(SQLitePageTableLeaf, schemaCells, 0, SQLitePage1HeaderSize)
+	w.writeFileHeader(applicationID)
+
+	out := make([]byte, 0, len(w.pages)*SQLitePageSize)
+	for _, page := range w.pages {
+		out = append(out, page...)
+	}
+	return out, nil
+}
+
+// writeFileHeader fills in the 100-byte database header on page 1.
+func (w *sqliteWriter) writeFileHeader(applicationID uint32) {
+	h := w.pages[0][:SQLitePage1HeaderSize]
+	copy(h, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(h[16:], SQLitePageSize)
+	h[18] = 1                                                // File format write version (legacy)
+	h[19] = 1                                                // File format read version (legacy)
+	h[20] = 0                                                // Reserved space per page
+	h[21] = 64                                               // Maximum embedded payload fraction
+	h[22] = 32                                               // Minimum embedded payload fraction
+	h[23] = 32                                               // Leaf payload fraction
+	binary.BigEndian.PutUint32(h[24:], 1)                    // File change counter
+	binary.BigEndian.PutUint32(h[28:], uint32(len(w.pages))) // Database size in pages
+	binary.BigEndian.PutUint32(h[40:], 1)                    // Schema cookie
+	binary.BigEndian.PutUint32(h[44:], 4)                    // Schema format number
+	binary.BigEndian.PutUint32(h[56:], 1)                    // Text encoding: UTF-8
+	binary.BigEndian.PutUint32(h[68:], applicationID)
+	binary.BigEndian.PutUint32(h[92:], 1) // Version-valid-for number
+	binary.BigEndian.PutUint32(h[96:], SQLiteVersionNumber)
+}
+
+// writeTable writes the rows of a table as a b-tree and returns its root page number.
+func (w *sqliteWriter) writeTable(rows [][]interface{}) int {
+	cells := make([]sqliteCell, 0, len(rows))
+	for i, row := range rows {
+		cells = append(cells, w.tableLeafCell(int64(i+1), encodeSQLiteRecord(row)))
+	}
+
+	var children []sqliteCell
+	for _, group := range w.packCells(cells, SQLiteLeafHeaderSize) {
+		page := w.addPage(w.buildPage(SQLitePageTableLeaf, group, 0, 0))
+		children = append(children, sqliteCell{rowid: group[len(group)-1].rowid, data: pageRef(page)})
+	}
+	if len(children) == 0 {
+		return w.addPage(w.buildPage(SQLitePageTableLeaf, nil, 0, 0))
+	}
+
+	for len(children) > 1 {
+		var parents []sqliteCell
+		for _, group := range w.packChildren(children) {
+			last := group[len(group)-1]
+			interior := make([]sqliteCell, 0, len(group)-1)
+			for _, child := range group[:len(group)-1] {
+				interior = append(interior, sqliteCell{data: appendSQLiteVarint(child.data, uint64(child.rowid))})
+			}
+			page := w.addPage(w.buildPage(SQLitePageTableInterior, interior, pageNumber(last.data), 0))
+			parents = append(parents, sqliteCell{rowid: last.rowid, data: pageRef(page)})
+		}
+		children = parents
+	}
+	return pageNumber(children[0].data)
+}
+
+// writeIndex writes an index b-tree over the given columns and returns its root page number.
+// Each index entry stores the indexed values followed by the rowid of the row.
+func (w *sqliteWriter) writeIndex(rows [][]interface{}, columns []int) (int, error) {
+	entries := make([][]byte, len(rows))
+	for i, row := range rows {
+		values := make([]interface{}, 0, len(columns)+1)
+		for _, col := range columns {
+			values = append(values, row[col])
+		}
+		values = append(values, int64(i+1))
+		entries[i] = encodeSQLiteRecord(values)
+		if len(entries[i]) > SQLiteMaxIndexPayload {
+			return 0, fmt.Errorf("index entry for row %d is too large (%d bytes)", i+1, len(entries[i]))
+		}
+	}
+
+	// Unlike table b-trees, every index entry is stored exactly once: entries
+	// separating two child pages live in the parent page instead of a leaf.
+	children, separators := w.packIndexLevel(entries, nil, SQLitePageIndexLeaf)
+	for len(children) > 1 {
+		children, separators = w.packIndexLevel(separators, children, SQLitePageIndexInterior)
+	}
+	return children[0], nil
+}
+
+// packIndexLevel packs one level of an index b-tree.
+// For leaf levels children is nil; for interior levels children has one more element than entries.
+// It returns the pages written and the entries separating them, to be stored one level up.
+func (w *sqliteWriter) packIndexLevel(entries [][]byte, children []int, pageType byte) ([]int, [][]byte) {
+	headerSize := SQLiteLeafHeaderSize
+	if pageType == SQLitePageIndexInterior {
+		headerSize = SQLiteInteriorHeaderSize
+	}
+
+	cells := make([]sqliteCell, len(entries))
+	for i, entry := range entries {
+		cell := w.indexCell(entry)
+		if children != nil {
+			cell.data = append(pageRef(children[i]), cell.data...)
+		}
+		cells[i] = cell
+	}
+
+	var pages []int
+	var separators [][]byte
+	flush := func(group []sqliteCell, right int) {
+		pages = append(pages, w.addPage(w.buildPage(pageType, group, right, 0)))
+	}
+
+	start := 0
+	used := 0
+	capacity := SQLitePageSize - headerSize
+	for i := 0; i < len(cells); i++ {
+		size := len(cells[i].data) + SQLiteCellPointerSize
+		if used+size <= capacity || i == start {
+			used += size
+			continue
+		}
+		// cells[i] becomes a separator; make sure at least one cell is left for the next page.
+		sep := i
+		if sep == len(cells)-1 && sep-start > 1 {
+			sep--
+		}
+		flush(cells[start:sep], rightChild(children, sep))
+		separators = append(separators, cells[sep].record)
+		start = sep + 1
+		used = 0
+		i = sep
+	}
+	if start < len(cells) || len(pages) == 0 {
+		flush(cells[start:], rightChild(children, len(cells)))
+	}
+	return pages, separators
+}
+
+// rightChild returns the child page to the right of the given cell position, or 0 for leaf levels.
+func rightChild(children []int, pos int) int {
+	if children == nil {
+		return 0
+	}
+	return children[pos]
+}
+
+// packCells splits cells into groups that each fit on a single page.
+func (w *sqliteWriter) packCells(cells []sqliteCell, headerSize int) [][]sqliteCell {
+	var groups [][]sqliteCell
+	start := 0
+	used := 0
+	capacity := SQLitePageSize - headerSize
+	for i, cell := range cells {
+		size := len(cell.data) + SQLiteCellPointerSize
+		if used+size > capacity && i > start {
+			groups = append(groups, cells[start:i])
+			start = i
+			used = 0
+		}
+		used += size
+	}
+	if start < len(cells) {
+		groups = append(groups, cells[start:])
+	}
+	return groups
+}
+
+// packChildren splits the children of a table interior level into evenly sized groups.
+// Every group holds at least two children so that each interior page has at least one cell.
+func (w *sqliteWriter) packChildren(children []sqliteCell) [][]sqliteCell {
+	perPage := (SQLitePageSize-SQLiteInteriorHeaderSize)/(SQLiteMaxInteriorCellSize+SQLiteCellPointerSize) + 1
+	pageCount := (len(children) + perPage - 1) / perPage
+	var groups [][]sqliteCell
+	for i := 0; i < pageCount; i++ {
+		start := i * len(children) / pageCount
+		end := (i + 1) * len(children) / pageCount
+		groups = append(groups, children[start:end])
+	}
+	return groups
+}
+
+// fits reports whether the cells fit on one page after the given header size.
+func (w *sqliteWriter) fits(cells []sqliteCell, headerSize int) bool {
+	used := headerSize
+	for _, cell := range cells {
+		used += len(cell.data) + SQLiteCellPointerSize
+	}
+	return used <= SQLitePageSize
+}
+
+// addPage appends a page to the database and returns its 1-based page number.
+func (w *sqliteWriter) addPage(page []byte) int {
+	w.pages = append(w.pages, page)
+	return len(w.pages)
+}
+
+// buildPage lays out a b-tree page: header, cell pointer array and cell content.
+// offset is the position of the b-tree header within the page (100 on page 1).
+func (w *sqliteWriter) buildPage(pageType byte, cells []sqliteCell, rightMost int, offset int) []byte {
+	page := make([]byte, SQLitePageSize)
+	headerSize := SQLiteLeafHeaderSize
+	if pageType == SQLitePageTableInterior || pageType == SQLitePageIndexInterior {
+		headerSize = SQLiteInteriorHeaderSize
+		binary.BigEndian.PutUint32(page[offset+8:], uint32(rightMost))
+	}
+
+	page[offset] = pageType
+	binary.BigEndian.PutUint16(page[offset+3:], uint16(len(cells)))
+
+	content := SQLitePageSize
+	pointer := offset + headerSize
+	for _, cell := range cells {
+		content -= len(cell.data)
+		copy(page[content:], cell.data)
+		binary.BigEndian.PutUint16(page[pointer:], uint16(content))
+		pointer += SQLiteCellPointerSize
+	}
+	binary.BigEndian.PutUint16(page[offset+5:], uint16(content))
+	return page
+}
+
+// tableLeafCell encodes a table leaf cell, spilling large payloads to overflow pages.
+func (w *sqliteWriter) tableLeafCell(rowid int64, record []byte) sqliteCell {
+	maxLocal := SQLitePageSize - SQLiteTableLeafOverhead
+	cell := appendSQLiteVarint(nil, uint64(len(record)))
+	cell = appendSQLiteVarint(cell, uint64(rowid))
+	cell = w.appendPayload(cell, record, maxLocal)
+	return sqliteCell{data: cell, rowid: rowid, record: record}
+}
+
+// indexCell encodes the payload part of an index cell.
+// Entries are checked against SQLiteMaxIndexPayload, so they are always stored locally.
+func (w *sqliteWriter) indexCell(record []byte) sqliteCell {
+	cell := appendSQLiteVarint(nil, uint64(len(record)))
+	return sqliteCell{data: append(cell, record...), record: record}
+}
+
+// appendPayload appends the locally stored part of a payload to a cell and
+// writes the remainder to a chain of overflow pages.
+func (w *sqliteWriter) appendPayload(cell, payload []byte, maxLocal int) []byte {
+	if len(payload) <= maxLocal {
+		return append(cell, payload...)
+	}
+
+	usable := SQLitePageSize
+	minLocal := (usable-12)*32/255 - 23
+	local := minLocal + (len(payload)-minLocal)%(usable-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	cell = append(cell, payload[:local]...)
+
+	rest := payload[local:]
+	first := len(w.pages) + 1
+	for len(rest) > 0 {
+		page := make([]byte, SQLitePageSize)
+		n := copy(page[4:], rest)
+		rest = rest[n:]
+		if len(rest) > 0 {
+			binary.BigEndian.PutUint32(page, uint32(len(w.pages)+2))
+		}
+		w.addPage(page)
+	}
+	return append(cell, pageRef(first)...)
+}
+
+// encodeSQLiteRecord encodes values in the SQLite record format.
+func encodeSQLiteRecord(values []interface{}) []byte {
+	var header, body []byte
+	for _, value := range values {
+		switch v := value.(type) {
+		case nil:
+			header = appendSQLiteVarint(header, 0)
+		case int:
+			header, body = appendSQLiteInt(header, body, int64(v))
+		case int64:
+			header, body = appendSQLiteInt(header, body, v)
+		case float64:
+			header = appendSQLiteVarint(header, 7)
+			body = binary.BigEndian.AppendUint64(body, math.Float64bits(v))
+		case string:
+			header = appendSQLiteVarint(header, uint64(len(v))*2+13)
+			body = append(body, v...)
+		case []byte:
+			header = appendSQLiteVarint(header, uint64(len(v))*2+12)
+			body = append(body, v...)
+		default:
+			s := fmt.Sprintf("%v", v)
+			header = appendSQLiteVarint(header, uint64(len(s))*2+13)
+			body = append(body, s...)
+		}
+	}
+
+	// The header size includes the varint that encodes it.
+	size := len(header) + 1
+	if len(appendSQLiteVarint(nil, uint64(size))) > 1 {
+		size = len(header) + len(appendSQLiteVarint(nil, uint64(size+1)))
+	}
+	record := appendSQLiteVarint(nil, uint64(size))
+	record = append(record, header...)
+	return append(record, body...)
+}
+
+// appendSQLiteInt appends an integer using the smallest serial type that holds it.
+func appendSQLiteInt(header, body []byte, v int64) ([]byte, []byte) {
+	switch {
+	case v == 0:
+		return appendSQLiteVarint(header, 8), body
+	case v == 1:
+		return appendSQLiteVarint(header, 9), body
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		return appendSQLiteVarint(header, 1), append(body, byte(v))
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		return appendSQLiteVarint(header, 2), binary.BigEndian.AppendUint16(body, uint16(v))
+	case v >= -1<<23 && v < 1<<23:
+		return appendSQLiteVarint(header, 3), append(body, byte(v>>16), byte(v>>8), byte(v))
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		return appendSQLiteVarint(header, 4), binary.BigEndian.AppendUint32(body, uint32(v))
+	case v >= -1<<47 && v < 1<<47:
+		return appendSQLiteVarint(header, 5), append(body, byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	default:
+		return appendSQLiteVarint(header, 6), binary.BigEndian.AppendUint64(body, uint64(v))
+	}
+}
+
+// appendSQLiteVarint appends v in SQLite's big-endian variable-length integer encoding.
+func appendSQLiteVarint(buf []byte, v uint64) []byte {
+	if v > 0x00ffffffffffffff {
+		var b [9]byte
+		b[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			b[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return append(buf, b[:]...)
+	}
+
+	var b [9]byte
+	n := 0
+	for {
+		b[n] = byte(v&0x7f) | 0x80
+		n++
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	b[0] &= 0x7f
+	for i := n - 1; i >= 0; i-- {
+		buf = append(buf, b[i])
+	}
+	return buf
+}
+
+// pageRef encodes a page number as a 4-byte big-endian child or overflow pointer.
+func pageRef(page int) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(page))
+}
+
+// pageNumber decodes a page number encoded by pageRef.
+func pageNumber(ref []byte) int {
+	return int(binary.BigEndian.Uint32(ref))
+}
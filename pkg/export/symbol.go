@@ -0,0 +1,213 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package export provides functions for converting GeoJSON data to various export formats.
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/convert"
+)
+
+// symbolFromMap builds a symbol from its decoded Esri JSON representation.
+func symbolFromMap(symbolMap map[string]interface{}) *convert.Symbol {
+	symbol := &convert.Symbol{
+		Type:        getString(symbolMap, "type"),
+		URL:         getString(symbolMap, "url"),
+		ImageData:   getString(symbolMap, "imageData"),
+		ContentType: getString(symbolMap, "contentType"),
+		Width:       getFloat(symbolMap, "width"),
+		Height:      getFloat(symbolMap, "height"),
+		XOffset:     getFloat(symbolMap, "xoffset"),
+		YOffset:     getFloat(symbolMap, "yoffset"),
+		Angle:       getFloat(symbolMap, "angle"),
+		Color:       getColor(symbolMap, "color"),
+		Size:        getFloat(symbolMap, "size"),
+		Style:       getString(symbolMap, "style"),
+	}
+	if outline, ok := symbolMap["outline"].(map[string]interface{}); ok {
+		symbol.Outline = symbolFromMap(outline)
+	}
+	return symbol
+}
+
+// kmlColor converts an Esri [r, g, b, a] color to a KML aabbggrr color string.
+// A nil or malformed color yields the fallback; a missing alpha is treated as opaque.
+func kmlColor(rgba []int, fallback string) string {
+	if len(rgba) < 3 {
+		return fallback
+	}
+	alpha := 255
+	if len(rgba) > 3 {
+		alpha = rgba[3]
+	}
+	return fmt.Sprintf("%02x%02x%02x%02x", clampByte(alpha), clampByte(rgba[2]), clampByte(rgba[1]), clampByte(rgba[0]))
+}
+
+// symbolNRGBA converts an Esri [r, g, b, a] color to an image color; nil is transparent.
+func symbolNRGBA(rgba []int) color.NRGBA {
+	if len(rgba) < 3 {
+		return color.NRGBA{}
+	}
+	alpha := 255
+	if len(rgba) > 3 {
+		alpha = rgba[3]
+	}
+	return color.NRGBA{R: uint8(clampByte(rgba[0])), G: uint8(clampByte(rgba[1])), B: uint8(clampByte(rgba[2])), A: uint8(clampByte(alpha))}
+}
+
+// clampByte limits a color component to the 0-255 range.
+func clampByte(v int) int {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return v
+}
+
+// pointsToPixels converts a size in points, as used by Esri symbols, to screen pixels.
+func pointsToPixels(points float64) float64 {
+	return points * ScreenDPI / PointsPerInch
+}
+
+// renderMarkerPNG draws an esriSMS marker (circle, square, diamond, triangle, cross or x)
+// with its fill color and outline to a PNG image.
+//
+// Returns:
+//   - []byte: PNG image data
+//   - int: Width and height of the square image in pixels
+//   - error: Any error that occurred while encoding the image
+func renderMarkerPNG(symbol *convert.Symbol) ([]byte, int, error) {
+	size := pointsToPixels(symbol.Size)
+	if size <= 0 {
+		size = pointsToPixels(DefaultMarkerSize)
+	}
+	fill := symbolNRGBA(symbol.Color)
+	stroke := color.NRGBA{}
+	strokeWidth := 0.0
+	if symbol.Outline != nil && symbol.Outline.Style != "esriSLSNull" {
+		stroke = symbolNRGBA(symbol.Outline.Color)
+		strokeWidth = pointsToPixels(symbol.Outline.Width)
+	}
+
+	half := size / 2
+	var shape func(x, y float64) float64 // Signed distance to the shape's edge, negative inside
+	switch symbol.Style {
+	case "esriSMSSquare":
+		shape = func(x, y float64) float64 { return math.Max(math.Abs(x), math.Abs(y)) - half }
+	case "esriSMSDiamond":
+		shape = func(x, y float64) float64 { return (math.Abs(x) + math.Abs(y) - half) / math.Sqrt2 }
+	case "esriSMSTriangle":
+		shape = convexPolygonDistance([][2]float64{{0, -half}, {half, half}, {-half, half}})
+	case "esriSMSCross", "esriSMSX":
+		// Crosses are strokes only, drawn with the outline color or, without an outline, the fill color.
+		if stroke.A == 0 {
+			stroke = fill
+		}
+		if strokeWidth < size/MarkerStrokeRatio {
+			strokeWidth = size / MarkerStrokeRatio
+		}
+		fill = color.NRGBA{}
+		diagonal := symbol.Style == "esriSMSX"
+		shape = func(x, y float64) float64 {
+			if diagonal {
+				x, y = (x-y)/math.Sqrt2, (x+y)/math.Sqrt2
+			}
+			horizontal := math.Hypot(math.Max(math.Abs(x)-half, 0), y)
+			vertical := math.Hypot(x, math.Max(math.Abs(y)-half, 0))
+			// Distance to the centerlines; the outline band around them forms the stroke.
+			return math.Min(horizontal, vertical)
+		}
+	default: // esriSMSCircle
+		shape = func(x, y float64) float64 { return math.Hypot(x, y) - half }
+	}
+
+	dim := int(math.Ceil(size+strokeWidth)) + 2
+	center := float64(dim) / 2
+	img := image.NewNRGBA(image.Rect(0, 0, dim, dim))
+	const samples = MarkerSupersampling
+	for py := 0; py < dim; py++ {
+		for px := 0; px < dim; px++ {
+			var r, g, b, a float64
+			for sy := 0; sy < samples; sy++ {
+				for sx := 0; sx < samples; sx++ {
+					x := float64(px) + (float64(sx)+0.5)/samples - center
+					y := float64(py) + (float64(sy)+0.5)/samples - center
+					d := shape(x, y)
+					var c color.NRGBA
+					switch {
+					case strokeWidth > 0 && math.Abs(d) <= strokeWidth/2:
+						c = stroke
+					case d < 0:
+						c = fill
+					default:
+						continue
+					}
+					alpha := float64(c.A) / 255
+					r += float64(c.R) * alpha
+					g += float64(c.G) * alpha
+					b += float64(c.B) * alpha
+					a += alpha
+				}
+			}
+			if a == 0 {
+				continue
+			}
+			img.SetNRGBA(px, py, color.NRGBA{
+				R: uint8(math.Round(r / a)),
+				G: uint8(math.Round(g / a)),
+				B: uint8(math.Round(b / a)),
+				A: uint8(math.Round(a / (samples * samples) * 255)),
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, 0, fmt.Errorf("failed to encode marker image: %v", err)
+	}
+	return buf.Bytes(), dim, nil
+}
+
+// convexPolygonDistance returns an approximate signed distance function for a convex
+// polygon with vertices in clockwise screen order: the largest distance to any edge line.
+func convexPolygonDistance(vertices [][2]float64) func(x, y float64) float64 {
+	return func(x, y float64) float64 {
+		d := math.Inf(-1)
+		for i := range vertices {
+			a, b := vertices[i], vertices[(i+1)%len(vertices)]
+			ex, ey := b[0]-a[0], b[1]-a[1]
+			length := math.Hypot(ex, ey)
+			// Outward normal of a clockwise edge in screen coordinates (y down).
+			nx, ny := ey/length, -ex/length
+			d = math.Max(d, (x-a[0])*nx+(y-a[1])*ny)
+		}
+		return d
+	}
+}
+
+// getColor extracts an Esri [r, g, b, a] color array from a map.
+// Returns nil if the key doesn't exist or the value is not an array of numbers.
+func getColor(m map[string]interface{}, key string) []int {
+	values, ok := m[key].([]interface{})
+	if !ok {
+		return nil
+	}
+	rgba := make([]int, 0, len(values))
+	for _, v := range values {
+		num, ok := v.(float64)
+		if !ok {
+			return nil
+		}
+		rgba = append(rgba, int(num))
+	}
+	return rgba
+}
@@ -0,0 +1,42 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<au:FeatureCollection xmlns:au="https://github.com/Sudo-Ivan/arcgis-utils" xmlns:gml="http://www.opengis.net/gml/3.2" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" gml:id="Stations.collection">
+  <au:featureMember>
+    <au:Stations gml:id="Stations.1">
+      <au:geometry><gml:Point gml:id="Stations.1.geom" srsName="http://www.opengis.net/def/crs/EPSG/0/4326" srsDimension="2"><gml:pos>37.75 -122.5</gml:pos></gml:Point></au:geometry>
+      <au:OBJECTID>1</au:OBJECTID>
+      <au:NAME>North Station</au:NAME>
+      <au:CAPACITY>1234567</au:CAPACITY>
+      <au:RATIO>0.25</au:RATIO>
+      <au:OPENED>2024-03-01T12:00:00Z</au:OPENED>
+    </au:Stations>
+  </au:featureMember>
+  <au:featureMember>
+    <au:Stations gml:id="Stations.2">
+      <au:geometry><gml:MultiCurve gml:id="Stations.2.geom" srsName="http://www.opengis.net/def/crs/EPSG/0/4326" srsDimension="2"><gml:curveMember><gml:LineString gml:id="Stations.2.geom.1"><gml:posList>37.75 -122.5 37.8 -122.45 37.8 -122.4</gml:posList></gml:LineString></gml:curveMember><gml:curveMember><gml:LineString gml:id="Stations.2.geom.2"><gml:posList>37.7 -122.3 37.72 -122.25</gml:posList></gml:LineString></gml:curveMember></gml:MultiCurve></au:geometry>
+      <au:OBJECTID>2</au:OBJECTID>
+      <au:NAME>Ferry &amp; Bus</au:NAME>
+      <au:CAPACITY>40</au:CAPACITY>
+      <au:RATIO>0.0000001</au:RATIO>
+      <au:OPENED>2000-01-01T00:00:00Z</au:OPENED>
+      <au:NOTE>Seasonal &lt;summer&gt;</au:NOTE>
+    </au:Stations>
+  </au:featureMember>
+  <au:featureMember>
+    <au:Stations gml:id="Stations.3">
+      <au:geometry><gml:Polygon gml:id="Stations.3.geom" srsName="http://www.opengis.net/def/crs/EPSG/0/4326" srsDimension="2"><gml:exterior><gml:LinearRing><gml:posList>37.7 -122.5 37.7 -122.48 37.72 -122.48 37.72 -122.5 37.7 -122.5</gml:posList></gml:LinearRing></gml:exterior></gml:Polygon></au:geometry>
+      <au:OBJECTID>3</au:OBJECTID>
+      <au:NAME>Park</au:NAME>
+      <au:CAPACITY>0</au:CAPACITY>
+      <au:RATIO>3.5</au:RATIO>
+      <au:NOTE>Dogs allowed</au:NOTE>
+    </au:Stations>
+  </au:featureMember>
+  <au:featureMember>
+    <au:Stations gml:id="Stations.4">
+      <au:geometry><gml:Point gml:id="Stations.4.geom" srsName="http://www.opengis.net/def/crs/EPSG/0/4326" srsDimension="2"><gml:pos>37.71 -122.45</gml:pos></gml:Point></au:geometry>
+      <au:OBJECTID>1234567</au:OBJECTID>
+      <au:CAPACITY>12</au:CAPACITY>
+      <au:RATIO>2</au:RATIO>
+    </au:Stations>
+  </au:featureMember>
+</au:FeatureCollection>
@@ -0,0 +1,527 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package export provides functions for converting GeoJSON data to various export formats.
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/Sudo-Ivan/arcgis-utils/pkg/convert"
+)
+
+// TopoJSONLayer pairs a GeoJSON FeatureCollection with the name of the
+// TopoJSON object it is stored under.
+type TopoJSONLayer struct {
+	Name    string
+	GeoJSON *convert.GeoJSON
+}
+
+// topology represents a TopoJSON Topology object.
+type topology struct {
+	Type      string                    `json:"type"`
+	BBox      []float64                 `json:"bbox,omitempty"`
+	Transform *topologyTransform        `json:"transform,omitempty"`
+	Objects   map[string]topoCollection `json:"objects"`
+	Arcs      [][][2]int                `json:"arcs"`
+}
+
+// topologyTransform holds the quantization transform of a topology.
+type topologyTransform struct {
+	Scale     [2]float64 `json:"scale"`
+	Translate [2]float64 `json:"translate"`
+}
+
+// topoCollection represents a named GeometryCollection in a topology.
+type topoCollection struct {
+	Type       string         `json:"type"`
+	Geometries []topoGeometry `json:"geometries"`
+}
+
+// topoGeometry represents a single TopoJSON geometry object.
+type topoGeometry struct {
+	Type        string                 `json:"type"`
+	Arcs        interface{}            `json:"arcs,omitempty"`
+	Coordinates interface{}            `json:"coordinates,omitempty"`
+	Properties  map[string]interface{} `json:"properties,omitempty"`
+}
+
+// quantPoint is a coordinate on the quantized integer grid.
+type quantPoint [2]int
+
+// topoBuilder accumulates quantized lines and rings and cuts them into shared arcs.
+type topoBuilder struct {
+	lines     [][]quantPoint
+	rings     [][]quantPoint
+	junctions map[quantPoint]bool
+	arcs      [][]quantPoint
+	arcIndex  map[string]int
+}
+
+// ConvertGeoJSONToTopoJSON converts a GeoJSON FeatureCollection to a TopoJSON string.
+// The features are stored in a single object named after the layer and
+// coordinates are quantized with DefaultTopoJSONQuantization.
+//
+// Parameters:
+//   - geoJSON: Pointer to a GeoJSON FeatureCollection
+//   - layerName: Name of the TopoJSON object holding the features
+//
+// Returns:
+//   - string: TopoJSON document as a string
+//   - error: Any error that occurred during conversion
+func ConvertGeoJSONToTopoJSON(geoJSON *convert.GeoJSON, layerName string) (string, error) {
+	return ConvertLayersToTopoJSON([]TopoJSONLayer{{Name: layerName, GeoJSON: geoJSON}}, DefaultTopoJSONQuantization)
+}
+
+// ConvertLayersToTopoJSON converts one or more GeoJSON FeatureCollections to a single TopoJSON topology.
+// The function handles:
+//   - One named GeometryCollection object per layer
+//   - Quantization of all coordinates to a shared integer grid
+//   - Shared arcs between adjacent lines and polygons, across all layers
+//   - Delta encoding of the arc coordinates
+//
+// Parameters:
+//   - layers: Layers to include, each stored under its own object name
+//   - quantization: Number of grid steps per axis, at least MinTopoJSONQuantization
+//
+// Returns:
+//   - string: TopoJSON document as a string
+//   - error: Any error that occurred during conversion
+func ConvertLayersToTopoJSON(layers []TopoJSONLayer, quantization int) (string, error) {
+	if quantization < MinTopoJSONQuantization {
+		return "", fmt.Errorf("unsupported TopoJSON quantization %d: must be at least %d", quantization, MinTopoJSONQuantization)
+	}
+
+	bbox, hasCoords := topoBBox(layers)
+	kx, ky := 1.0, 1.0
+	if hasCoords {
+		if bbox[2] > bbox[0] {
+			kx = (bbox[2] - bbox[0]) / float64(quantization-1)
+		}
+		if bbox[3] > bbox[1] {
+			ky = (bbox[3] - bbox[1]) / float64(quantization-1)
+		}
+	}
+	quantize := func(c []float64) quantPoint {
+		return quantPoint{int(math.Round((c[0] - bbox[0]) / kx)), int(math.Round((c[1] - bbox[1]) / ky))}
+	}
+
+	builder := &topoBuilder{
+		junctions: make(map[quantPoint]bool),
+		arcIndex:  make(map[string]int),
+	}
+
+	// First pass: quantize every line and ring so junctions can be found across all layers.
+	type pendingGeometry struct {
+		geometryType string
+		points       []quantPoint // Point, MultiPoint
+		lines        []int        // LineString, MultiLineString: indices into builder.lines
+		polygons     [][]int      // Polygon, MultiPolygon: ring indices into builder.rings
+		properties   map[string]interface{}
+	}
+	pending := make([][]pendingGeometry, len(layers))
+
+	for li, layer := range layers {
+		if layer.GeoJSON == nil {
+			continue
+		}
+		for _, feature := range layer.GeoJSON.Features {
+			geometryMap, ok := feature.Geometry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			geometryType, _ := geometryMap[KeyType].(string)
+			coordinates := geometryMap["coordinates"]
+			geom := pendingGeometry{geometryType: geometryType, properties: topoProperties(feature.Properties)}
+
+			switch geometryType {
+			case "Point":
+				if c, ok := coordinates.([]float64); ok && len(c) >= 2 {
+					geom.points = []quantPoint{quantize(c)}
+				}
+			case "MultiPoint":
+				if coords, ok := coordinates.([][]float64); ok {
+					for _, c := range coords {
+						if len(c) >= 2 {
+							geom.points = append(geom.points, quantize(c))
+						}
+					}
+				}
+			case "LineString":
+				if coords, ok := coordinates.([][]float64); ok && len(coords) > 0 {
+					geom.lines = []int{builder.addLine(quantizeLine(coords, quantize))}
+				}
+			case "MultiLineString":
+				if parts, ok := coordinates.([][][]float64); ok {
+					for _, coords := range parts {
+						if len(coords) > 0 {
+							geom.lines = append(geom.lines, builder.addLine(quantizeLine(coords, quantize)))
+						}
+					}
+				}
+			case "Polygon":
+				if rings, ok := coordinates.([][][]float64); ok && len(rings) > 0 {
+					geom.polygons = [][]int{builder.addRings(rings, quantize)}
+				}
+			case "MultiPolygon":
+				if polygons, ok := coordinates.([][][][]float64); ok {
+					for _, rings := range polygons {
+						if len(rings) > 0 {
+							geom.polygons = append(geom.polygons, builder.addRings(rings, quantize))
+						}
+					}
+				}
+			default:
+				fmt.Fprintf(Output, "  Warning: Unsupported geometry type for TopoJSON conversion: %s\n", geometryType)
+				continue
+			}
+			pending[li] = append(pending[li], geom)
+		}
+	}
+
+	// Second pass: cut lines and rings at junctions into deduplicated arcs.
+	builder.findJunctions()
+	lineArcs := make([][]int, len(builder.lines))
+	for i, line := range builder.lines {
+		lineArcs[i] = builder.cutLine(line)
+	}
+	ringArcs := make([][]int, len(builder.rings))
+	for i, ring := range builder.rings {
+		ringArcs[i] = builder.cutRing(ring)
+	}
+
+	topo := topology{
+		Type:    "Topology",
+		Objects: make(map[string]topoCollection),
+		Arcs:    make([][][2]int, len(builder.arcs)),
+	}
+	if hasCoords {
+		topo.BBox = bbox[:]
+		topo.Transform = &topologyTransform{
+			Scale:     [2]float64{kx, ky},
+			Translate: [2]float64{bbox[0], bbox[1]},
+		}
+	}
+
+	for i, arc := range builder.arcs {
+		encoded := make([][2]int, len(arc))
+		prev := quantPoint{}
+		for j, p := range arc {
+			encoded[j] = [2]int{p[0] - prev[0], p[1] - prev[1]}
+			prev = p
+		}
+		topo.Arcs[i] = encoded
+	}
+
+	for li, layer := range layers {
+		name := layer.Name
+		if name == "" {
+			name = fmt.Sprintf(LayerObjectNameFormat, li+1)
+		}
+		collection := topoCollection{Type: "GeometryCollection", Geometries: []topoGeometry{}}
+		for _, geom := range pending[li] {
+			out := topoGeometry{Type: geom.geometryType, Properties: geom.properties}
+			switch geom.geometryType {
+			case "Point":
+				if len(geom.points) == 0 {
+					continue
+				}
+				out.Coordinates = geom.points[0]
+			case "MultiPoint":
+				if len(geom.points) == 0 {
+					continue
+				}
+				out.Coordinates = geom.points
+			case "LineString":
+				if len(geom.lines) == 0 {
+					continue
+				}
+				out.Arcs = lineArcs[geom.lines[0]]
+			case "MultiLineString":
+				if len(geom.lines) == 0 {
+					continue
+				}
+				parts := make([][]int, len(geom.lines))
+				for i, lineIdx := range geom.lines {
+					parts[i] = lineArcs[lineIdx]
+				}
+				out.Arcs = parts
+			case "Polygon":
+				if len(geom.polygons) == 0 {
+					continue
+				}
+				out.Arcs = polygonArcs(geom.polygons[0], ringArcs)
+			case "MultiPolygon":
+				if len(geom.polygons) == 0 {
+					continue
+				}
+				polygons := make([][][]int, len(geom.polygons))
+				for i, ringIndices := range geom.polygons {
+					polygons[i] = polygonArcs(ringIndices, ringArcs)
+				}
+				out.Arcs = polygons
+			}
+			collection.Geometries = append(collection.Geometries, out)
+		}
+		topo.Objects[name] = collection
+	}
+
+	data, err := json.Marshal(topo)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal TopoJSON: %v", err)
+	}
+	return string(data), nil
+}
+
+// topoBBox computes the bounding box [minX, minY, maxX, maxY] of all layers.
+// The second return value is false if no coordinates were found.
+func topoBBox(layers []TopoJSONLayer) ([4]float64, bool) {
+	bbox := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	found := false
+	extend := func(c []float64) {
+		if len(c) < 2 {
+			return
+		}
+		found = true
+		bbox[0] = math.Min(bbox[0], c[0])
+		bbox[1] = math.Min(bbox[1], c[1])
+		bbox[2] = math.Max(bbox[2], c[0])
+		bbox[3] = math.Max(bbox[3], c[1])
+	}
+	for _, layer := range layers {
+		if layer.GeoJSON == nil {
+			continue
+		}
+		for _, feature := range layer.GeoJSON.Features {
+			geometryMap, ok := feature.Geometry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch coords := geometryMap["coordinates"].(type) {
+			case []float64:
+				extend(coords)
+			case [][]float64:
+				for _, c := range coords {
+					extend(c)
+				}
+			case [][][]float64:
+				for _, part := range coords {
+					for _, c := range part {
+						extend(c)
+					}
+				}
+			case [][][][]float64:
+				for _, polygon := range coords {
+					for _, ring := range polygon {
+						for _, c := range ring {
+							extend(c)
+						}
+					}
+				}
+			}
+		}
+	}
+	if !found {
+		return [4]float64{}, false
+	}
+	return bbox, true
+}
+
+// topoProperties copies feature properties, excluding symbol information.
+func topoProperties(props map[string]interface{}) map[string]interface{} {
+	if len(props) == 0 {
+		return nil
+	}
+	out := make(map[string]interface{}, len(props))
+	for k, v := range props {
+		if k == KeySymbol {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// quantizeLine quantizes a coordinate sequence and removes consecutive duplicate points.
+func quantizeLine(coords [][]float64, quantize func([]float64) quantPoint) []quantPoint {
+	line := make([]quantPoint, 0, len(coords))
+	for _, c := range coords {
+		if len(c) < 2 {
+			continue
+		}
+		p := quantize(c)
+		if len(line) > 0 && line[len(line)-1] == p {
+			continue
+		}
+		line = append(line, p)
+	}
+	return line
+}
+
+// polygonArcs maps the ring indices of a polygon to their arc index lists.
+func polygonArcs(ringIndices []int, ringArcs [][]int) [][]int {
+	rings := make([][]int, len(ringIndices))
+	for i, ringIdx := range ringIndices {
+		rings[i] = ringArcs[ringIdx]
+	}
+	return rings
+}
+
+// addLine stores a quantized line and returns its index.
+func (b *topoBuilder) addLine(line []quantPoint) int {
+	b.lines = append(b.lines, line)
+	return len(b.lines) - 1
+}
+
+// addRings quantizes and stores the rings of a polygon and returns their indices.
+// The closing point of each ring is dropped; rings are treated as cyclic.
+func (b *topoBuilder) addRings(rings [][][]float64, quantize func([]float64) quantPoint) []int {
+	indices := make([]int, 0, len(rings))
+	for _, coords := range rings {
+		ring := quantizeLine(coords, quantize)
+		if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+			ring = ring[:len(ring)-1]
+		}
+		if len(ring) == 0 {
+			continue
+		}
+		b.rings = append(b.rings, ring)
+		indices = append(indices, len(b.rings)-1)
+	}
+	return indices
+}
+
+// findJunctions marks every point where lines or rings meet or diverge.
+// A point is a junction if it is the endpoint of a line, or if it is visited
+// more than once with a different pair of neighbouring points.
+func (b *topoBuilder) findJunctions() {
+	type neighbours struct {
+		prev, next quantPoint
+		hasPrev    bool
+		hasNext    bool
+	}
+	seen := make(map[quantPoint]neighbours)
+	visit := func(p quantPoint, n neighbours) {
+		if b.junctions[p] {
+			return
+		}
+		first, ok := seen[p]
+		if !ok {
+			seen[p] = n
+			return
+		}
+		same := first.hasPrev == n.hasPrev && first.hasNext == n.hasNext && first.prev == n.prev && first.next == n.next
+		reversed := first.hasPrev == n.hasNext && first.hasNext == n.hasPrev && first.prev == n.next && first.next == n.prev
+		if !same && !reversed {
+			b.junctions[p] = true
+		}
+	}
+
+	for _, line := range b.lines {
+		for i, p := range line {
+			if i == 0 || i == len(line)-1 {
+				b.junctions[p] = true
+				continue
+			}
+			visit(p, neighbours{prev: line[i-1], next: line[i+1], hasPrev: true, hasNext: true})
+		}
+	}
+	for _, ring := range b.rings {
+		n := len(ring)
+		for i, p := range ring {
+			visit(p, neighbours{prev: ring[(i-1+n)%n], next: ring[(i+1)%n], hasPrev: true, hasNext: true})
+		}
+	}
+}
+
+// cutLine splits a line at its junctions and returns the resulting arc indices.
+func (b *topoBuilder) cutLine(line []quantPoint) []int {
+	if len(line) == 0 {
+		return nil
+	}
+	if len(line) < 2 {
+		return []int{b.addArc([]quantPoint{line[0], line[0]})}
+	}
+	var arcs []int
+	start := 0
+	for i := 1; i < len(line); i++ {
+		if i == len(line)-1 || b.junctions[line[i]] {
+			arcs = append(arcs, b.addArc(line[start:i+1]))
+			start = i
+		}
+	}
+	return arcs
+}
+
+// cutRing splits a cyclic ring at its junctions and returns the resulting arc indices.
+// Rings without junctions are rotated to start at their smallest point so that
+// identical rings shared by two polygons produce the same arc.
+func (b *topoBuilder) cutRing(ring []quantPoint) []int {
+	n := len(ring)
+	start := -1
+	for i, p := range ring {
+		if b.junctions[p] {
+			start = i
+			break
+		}
+	}
+
+	if start == -1 {
+		start = 0
+		for i, p := range ring {
+			if p[0] < ring[start][0] || (p[0] == ring[start][0] && p[1] < ring[start][1]) {
+				start = i
+			}
+		}
+		closed := make([]quantPoint, 0, n+1)
+		for i := 0; i <= n; i++ {
+			closed = append(closed, ring[(start+i)%n])
+		}
+		return []int{b.addArc(closed)}
+	}
+
+	var arcs []int
+	segment := []quantPoint{ring[start]}
+	for i := 1; i <= n; i++ {
+		p := ring[(start+i)%n]
+		segment = append(segment, p)
+		if i == n || b.junctions[p] {
+			arcs = append(arcs, b.addArc(segment))
+			segment = []quantPoint{p}
+		}
+	}
+	return arcs
+}
+
+// addArc stores an arc unless it, or its reverse, already exists.
+// It returns the arc index, or its one's complement if the reversed arc is reused.
+func (b *topoBuilder) addArc(points []quantPoint) int {
+	key := arcKey(points, false)
+	if idx, ok := b.arcIndex[key]; ok {
+		return idx
+	}
+	if idx, ok := b.arcIndex[arcKey(points, true)]; ok {
+		return ^idx
+	}
+	arc := make([]quantPoint, len(points))
+	copy(arc, points)
+	b.arcs = append(b.arcs, arc)
+	b.arcIndex[key] = len(b.arcs) - 1
+	return len(b.arcs) - 1
+}
+
+// arcKey builds a lookup key for an arc, optionally in reverse order.
+func arcKey(points []quantPoint, reverse bool) string {
+	var sb strings.Builder
+	for i := range points {
+		p := points[i]
+		if reverse {
+			p = points[len(points)-1-i]
+		}
+		sb.WriteString(fmt.Sprintf("%d,%d;", p[0], p[1]))
+	}
+	return sb.String()
+}